@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/analysis"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// runAnalyze prints a summary of each run found in the given output files
+func runAnalyze(args []string) error {
+	fs := flag.NewFlagSet(analyzeCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <output file>...\n", os.Args[0], analyzeCommand)
+		fs.PrintDefaults()
+	}
+
+	format := fs.String("format", formatText, fmt.Sprintf("output format - one of %s | %s", formatText, formatJSON))
+	merge := fs.Bool("merge", false, "merge the blocks reported in all output files into one network-wide timeline instead of summarizing each run")
+	skewThreshold := fs.Duration("skew-threshold", time.Second, "clock skew between instances above which an instance is flagged in the merged timeline")
+	correctSkew := fs.Bool("correct-skew", false, "correct the timestamps of each instance by its estimated clock skew in the merged timeline")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no output files given")
+	}
+
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("given format %s not valid - has to be either %s or %s", *format, formatText, formatJSON)
+	}
+
+	runs, err := readAllRuns(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if *merge {
+		merged := analysis.Merge(runs, *skewThreshold, *correctSkew)
+		if *format == formatJSON {
+			return writeJSON(os.Stdout, merged)
+		}
+
+		return analysis.WriteMergedText(os.Stdout, merged)
+	}
+
+	summaries := make([]analysis.Summary, 0, len(runs))
+	for _, run := range runs {
+		summaries = append(summaries, analysis.Summarize(run))
+	}
+
+	if *format == formatJSON {
+		return writeJSON(os.Stdout, summaries)
+	}
+
+	return analysis.WriteText(os.Stdout, summaries)
+}
+
+// readAllRuns reads the runs of all given output files
+func readAllRuns(paths []string) ([]analysis.Run, error) {
+	runs := make([]analysis.Run, 0)
+	for _, path := range paths {
+		fileRuns, err := analysis.ReadRuns(path)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, fileRuns...)
+	}
+
+	return runs, nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/boecklim/node-analysis/pkg/analysis"
+)
+
+// runCompare compares the runs of a candidate output file to the runs of a baseline output file
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet(compareCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <baseline output file> <candidate output file>\n", os.Args[0], compareCommand)
+		fs.PrintDefaults()
+	}
+
+	format := fs.String("format", formatText, fmt.Sprintf("output format - one of %s | %s", formatText, formatJSON))
+	failOnRegression := fs.Bool("fail-on-regression", false, "exit with an error if a statistically significant regression is found")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("exactly two output files have to be given")
+	}
+
+	if *format != formatText && *format != formatJSON {
+		return fmt.Errorf("given format %s not valid - has to be either %s or %s", *format, formatText, formatJSON)
+	}
+
+	baseline, err := analysis.ReadRuns(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	candidate, err := analysis.ReadRuns(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	comparison := analysis.Compare(fs.Arg(0), baseline, fs.Arg(1), candidate)
+
+	if *format == formatJSON {
+		err = writeJSON(os.Stdout, comparison)
+	} else {
+		err = analysis.WriteComparisonText(os.Stdout, comparison)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *failOnRegression {
+		for _, metric := range comparison.Metrics {
+			if metric.Regression {
+				return fmt.Errorf("regression found in %s", metric.Name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/boecklim/node-analysis/pkg/coordinator"
+	"github.com/boecklim/node-analysis/pkg/scenario"
+)
+
+// runCoordinate distributes a scenario to the agents of its nodes, starts the run once all agents are ready and
+// collects their output files
+func runCoordinate(args []string) error {
+	fs := flag.NewFlagSet(coordinateCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <scenario file>\n", os.Args[0], coordinateCommand)
+		fs.PrintDefaults()
+	}
+
+	addr := fs.String("addr", ":8090", "address at which the agents connect to the coordinator")
+	resultsDir := fs.String("results", "", "directory to which the output files of the agents are written as <node name>.log - default is the output directory of the scenario or ./results")
+	startDelay := fs.Duration("start-delay", 10*time.Second, "time between all agents being ready and the start of the run")
+	collectTimeout := fs.Duration("collect-timeout", 10*time.Minute, "maximum time to wait for the results of the agents after the end of the scenario including wait and drain timeout")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one scenario file has to be given")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s, err := scenario.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	if *resultsDir == "" {
+		*resultsDir = s.Output.Dir
+	}
+	if *resultsDir == "" {
+		*resultsDir = "./results"
+	}
+
+	err = os.MkdirAll(*resultsDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create results directory: %v", err)
+	}
+
+	nodes := make([]string, len(s.Nodes))
+	for i, node := range s.Nodes {
+		nodes[i] = node.Name
+	}
+
+	writeResults := func(node string, r io.Reader) error {
+		f, err := os.Create(filepath.Join(*resultsDir, node+".log"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(f, r)
+		return err
+	}
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSignal(cancel)
+
+	c := coordinator.New(data, nodes, writeResults, logger, coordinator.WithStartDelay(*startDelay))
+	c.Serve(ctx, *addr)
+
+	logger.Info("Waiting for agents", "scenario", s.Name, "nodes", len(nodes))
+
+	started := c.Started()
+	var timeout <-chan time.Time
+	for {
+		select {
+		case <-started:
+			started = nil
+			timeout = time.After(time.Until(c.StartAt().Add(s.Wait+s.Duration+s.Drain.Timeout)) + *collectTimeout)
+		case <-c.Done():
+			logger.Info("Results of all agents collected", "dir", *resultsDir)
+
+			failed := c.Failed()
+			if len(failed) > 0 {
+				return fmt.Errorf("runs of nodes failed: %v", failed)
+			}
+
+			return nil
+		case <-timeout:
+			return fmt.Errorf("results of nodes %v not collected within %s after the end of the scenario", c.Missing(), *collectTimeout)
+		case <-ctx.Done():
+			return errors.New("coordinator stopped before the results of all agents were collected")
+		}
+	}
+}
+
+// runAgent registers at a coordinator, runs the node of the scenario distributed by the coordinator and uploads the
+// output file at the end
+func runAgent(args []string) error {
+	fs := flag.NewFlagSet(agentCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]\n", os.Args[0], agentCommand)
+		fs.PrintDefaults()
+	}
+
+	coordinatorURL := fs.String("coordinator", "", "URL of the coordinator e.g. http://10.0.0.4:8090")
+	nodeName := fs.String("node", "", "name of the node of the scenario which this agent runs")
+	host := fs.String("host", "", "host of the node overriding the host given in the scenario e.g. localhost on remote instances")
+	output := fs.String("output", "", "path to the output file - default is ./results/<node name>.log")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *coordinatorURL == "" || *nodeName == "" {
+		fs.Usage()
+		return errors.New("coordinator and node have to be given")
+	}
+
+	if *output == "" {
+		*output = filepath.Join("results", *nodeName+".log")
+	}
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSignal(cancel)
+
+	agent := coordinator.NewAgent(*coordinatorURL, *nodeName)
+
+	data, err := agent.Register(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = runAgentNode(ctx, agent, data, *nodeName, *host, *output, logger)
+	if err != nil {
+		// The output written until the failure is reported as well so that the coordinator doesn't wait for the node
+		output, _ := os.ReadFile(*output)
+		return errors.Join(err, agent.Failed(context.Background(), err, output))
+	}
+
+	f, err := os.Open(*output)
+	if err != nil {
+		return errors.Join(err, agent.Failed(context.Background(), err, nil))
+	}
+	defer f.Close()
+
+	// The results are uploaded even if the run has been interrupted
+	return agent.UploadResults(context.Background(), f)
+}
+
+// runAgentNode runs the node of the scenario distributed by the coordinator
+func runAgentNode(ctx context.Context, agent *coordinator.Agent, data []byte, nodeName string, host string, output string, logger *slog.Logger) error {
+	s, err := scenario.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	index, _, err := s.Node(nodeName)
+	if err != nil {
+		return err
+	}
+
+	cfg := scenarioNodeConfigs(s, time.Time{})[index]
+	if host != "" {
+		cfg.host = host
+	}
+	cfg.outputPath = output
+	cfg.awaitStart = func(ctx context.Context) (time.Time, error) {
+		err := agent.Ready(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return agent.AwaitStart(ctx)
+	}
+
+	logger.Info("Running scenario", "name", s.Name, "node", nodeName, "coordinator", agent.URL())
+
+	return runNode(ctx, cfg, logger)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/boecklim/node-analysis/pkg/analysis"
+)
+
+// runExport exports the blocks, submissions and stats samples of the given output files as typed tables
+func runExport(args []string) error {
+	fs := flag.NewFlagSet(exportCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <output file>...\n", os.Args[0], exportCommand)
+		fs.PrintDefaults()
+	}
+
+	dir := fs.String("dir", "./results/export", "directory to which the tables are written")
+	formats := fs.String("format", fmt.Sprintf("%s,%s", analysis.ExportCSV, analysis.ExportParquet), fmt.Sprintf("comma separated list of formats - %s | %s", analysis.ExportCSV, analysis.ExportParquet))
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no output files given")
+	}
+
+	runs, err := readAllRuns(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(*dir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create path: %v", err)
+	}
+
+	tables := analysis.NewTables(runs)
+
+	for _, format := range strings.Split(*formats, ",") {
+		switch strings.TrimSpace(format) {
+		case analysis.ExportCSV:
+			err = tables.WriteCSV(*dir)
+		case analysis.ExportParquet:
+			err = tables.WriteParquet(*dir)
+		default:
+			return fmt.Errorf("given format %s not valid - has to be either %s or %s", format, analysis.ExportCSV, analysis.ExportParquet)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %v", format, err)
+		}
+	}
+
+	fmt.Printf("Exported %d blocks, %d submissions and %d stats samples to %s\n", len(tables.Blocks), len(tables.Submissions), len(tables.Stats), *dir)
+
+	return nil
+}
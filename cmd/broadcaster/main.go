@@ -5,25 +5,63 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"log/slog"
+	"math/rand"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/lmittmann/tint"
 	slogmulti "github.com/samber/slog-multi"
 
 	"github.com/boecklim/node-analysis/pkg/broadcaster"
-	"github.com/boecklim/node-analysis/pkg/listener"
+	"github.com/boecklim/node-analysis/pkg/clock"
+	"github.com/boecklim/node-analysis/pkg/dashboard"
 	"github.com/boecklim/node-analysis/pkg/miner"
 	"github.com/boecklim/node-analysis/pkg/node_client"
-	"github.com/boecklim/node-analysis/pkg/zmq"
+	"github.com/boecklim/node-analysis/pkg/ratecontrol"
+	"github.com/boecklim/node-analysis/pkg/router"
 )
 
 func main() {
-	err := run()
+	var err error
+
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case schedulerCommand:
+		err = runScheduler(os.Args[2:])
+	case analyzeCommand:
+		err = runAnalyze(os.Args[2:])
+	case exportCommand:
+		err = runExport(os.Args[2:])
+	case reportCommand:
+		err = runReport(os.Args[2:])
+	case compareCommand:
+		err = runCompare(os.Args[2:])
+	case scenarioCommand:
+		err = runScenario(os.Args[2:])
+	case topologyCommand:
+		err = runTopology(os.Args[2:])
+	case orchestrateCommand:
+		err = runOrchestrate(os.Args[2:])
+	case coordinateCommand:
+		err = runCoordinate(os.Args[2:])
+	case agentCommand:
+		err = runAgent(os.Args[2:])
+	default:
+		err = run()
+	}
 	if err != nil {
 		log.Fatalf("failed to run: %v", err)
 	}
@@ -41,6 +79,18 @@ const (
 
 	pubhashblockTopic = "hashblock"
 	zmqPortDefault    = 29000
+	p2pPortDefault    = 18444
+
+	schedulerCommand   = "scheduler"
+	analyzeCommand     = "analyze"
+	exportCommand      = "export"
+	reportCommand      = "report"
+	compareCommand     = "compare"
+	scenarioCommand    = "scenario"
+	topologyCommand    = "topology"
+	orchestrateCommand = "orchestrate"
+	coordinateCommand  = "coordinate"
+	agentCommand       = "agent"
 )
 
 func run() error {
@@ -96,155 +146,407 @@ func run() error {
 		return errors.New("startAt not given")
 	}
 
-	flag.Parse()
+	minerStrategy := flag.String("miner-strategy", miner.StrategyHonest, fmt.Sprintf("strategy of the miner - one of %s | %s | %s", miner.StrategyHonest, miner.StrategySelfish, miner.StrategyWithhold))
+	if minerStrategy == nil {
+		return errors.New("miner strategy not given")
+	}
 
-	if *generateBlocks == 0 {
-		generateBlocks = nil
+	withholdDelay := flag.Duration("withhold-delay", 30*time.Second, "delay after which blocks are published by the withhold miner strategy")
+	if withholdDelay == nil {
+		return errors.New("withhold delay not given")
 	}
 
-	now := time.Now()
-	var startBroadcastingAt time.Time
-	if *startAt == "" {
-		startBroadcastingAt = now.Round(5 * time.Second).Add(60 * time.Second)
-	} else {
-		startBroadcastingAt, err = time.Parse(time.RFC3339, *startAt)
-		if err != nil {
-			return err
-		}
+	selfishLead := flag.Int("selfish-lead", 2, "number of privately mined blocks after which the selfish miner strategy publishes its chain")
+	if selfishLead == nil {
+		return errors.New("selfish lead not given")
 	}
 
-	if startBroadcastingAt.Before(now) {
-		return errors.New("start time is earlier than now")
+	selfishMaxWithhold := flag.Duration("selfish-max-withhold", 0, "maximum time the selfish miner strategy withholds its chain - for value 0 the chain is only published once the lead is reached")
+	if selfishMaxWithhold == nil {
+		return errors.New("selfish max withhold not given")
 	}
 
-	startBroadcastingAt = startBroadcastingAt.In(time.UTC)
+	blockEmpty := flag.Bool("block-empty", false, "generate empty blocks which only contain the coinbase tx")
+	if blockEmpty == nil {
+		return errors.New("block empty not given")
+	}
 
-	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
+	blockMaxSize := flag.Uint64("block-max-size", 0, "maximum size in bytes of generated blocks - for value 0 the size is not capped")
+	if blockMaxSize == nil {
+		return errors.New("block max size not given")
+	}
 
-	btcClient, err := node_client.New(*host, *rpcPort, rpcUser, rpcPassword, slog.Default())
-	if err != nil {
-		return err
+	blockTxs := flag.String("block-txs", node_client.TxsAll, fmt.Sprintf("which txs to include in generated blocks - one of %s | %s | %s", node_client.TxsAll, node_client.TxsOwn, node_client.TxsForeign))
+	if blockTxs == nil {
+		return errors.New("block txs not given")
 	}
-	var proc *node_client.Processor
 
-	switch *blockchain {
-	case btcBlockchain:
-		proc, err = node_client.NewProcessor(btcClient, logger, false)
-	case bsvBlockchain:
-		proc, err = node_client.NewProcessor(btcClient, logger, true)
-	default:
-		return fmt.Errorf("given blockchain %s not valid - has to be either %s or %s", *blockchain, bsvBlockchain, btcBlockchain)
+	retargetMode := flag.String("retarget", miner.RetargetNone, fmt.Sprintf("difficulty adjustment of the miner - one of %s | %s | %s", miner.RetargetNone, miner.RetargetBTC, miner.RetargetBSV))
+	if retargetMode == nil {
+		return errors.New("retarget not given")
 	}
-	if err != nil {
-		return err
+
+	retargetWindow := flag.Int("retarget-window", 0, fmt.Sprintf("number of blocks over which the difficulty adjustment is calculated - for value 0 the default of %d for %s and %d for %s is used", miner.DefaultWindowBTC, miner.RetargetBTC, miner.DefaultWindowBSV, miner.RetargetBSV))
+	if retargetWindow == nil {
+		return errors.New("retarget window not given")
 	}
 
-	info, err := btcClient.GetMiningInfo()
-	if err != nil {
-		return fmt.Errorf("failed to get info: %v", err)
+	retargetTarget := flag.Duration("retarget-target", 0, "block interval of the network which the difficulty adjustment targets - for value 0 the gen-blocks interval is used")
+	if retargetTarget == nil {
+		return errors.New("retarget target not given")
 	}
-	logger.Info("mining info", "blocks", info.Blocks, "errors", info.Errors)
 
-	networkInfo, err := btcClient.GetNetworkInfo()
-	if err != nil {
-		return err
+	seed := flag.Int64("seed", 0, "seed of the random number generators of miner, broadcaster and utxo selection - for value 0 a random seed is used")
+	if seed == nil {
+		return errors.New("seed not given")
 	}
 
-	logger.Info("network info", "version", networkInfo.Version)
+	arrival := flag.String("arrival", broadcaster.ArrivalConstant, fmt.Sprintf("arrival process of the submitted txs - one of %s | %s", broadcaster.ArrivalConstant, broadcaster.ArrivalPoisson))
+	if arrival == nil {
+		return errors.New("arrival not given")
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to create rpc client: %v", err)
+	logSubmissions := flag.Bool("log-submissions", false, "log each submitted tx to the output e.g. for exporting submissions")
+	if logSubmissions == nil {
+		return errors.New("log submissions not given")
 	}
-	var broadcasterLogger *slog.Logger
-	if *outputPath != "" {
-		path := filepath.Dir(*outputPath)
-		err = os.MkdirAll(path, os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("failed to create path: %v", err)
-		}
 
-		logFile, err := os.OpenFile(*outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to open file: %v", err)
-		}
-		defer logFile.Close()
-		broadcasterLogger = slog.New(
-			slogmulti.Fanout(
-				slog.NewJSONHandler(logFile, &slog.HandlerOptions{Level: slog.LevelInfo}),
-				tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}),
-			),
-		)
-	} else {
-		broadcasterLogger = logger
+	metricsAddr := flag.String("metrics-addr", "", "address at which prometheus metrics are served at /metrics e.g. :9100 - if empty no metrics are served")
+	if metricsAddr == nil {
+		return errors.New("metrics address not given")
 	}
 
-	messageChan := make(chan []string, 1000)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	apiAddr := flag.String("api-addr", "", "address at which the HTTP status and control API is served e.g. :8080 - if empty the API is not served")
+	if apiAddr == nil {
+		return errors.New("api address not given")
+	}
 
-	zmqSubscriber, err := zmq.New(ctx, *host, *zmqPort, logger)
-	if err != nil {
-		return err
+	tui := flag.Bool("tui", false, "render a live dashboard in the terminal instead of printing logs")
+	if tui == nil {
+		return errors.New("tui not given")
 	}
 
-	err = zmqSubscriber.Subscribe(pubhashblockTopic, messageChan)
-	if err != nil {
-		return err
+	maxSkew := flag.Duration("max-skew", 2*time.Second, "maximum offset of the local clock against the node and the network - for value 0 the offset is not measured")
+	if maxSkew == nil {
+		return errors.New("max skew not given")
+	}
+
+	skewAction := flag.String("skew-action", clock.ActionWarn, fmt.Sprintf("action if the clock skew exceeds the maximum skew - one of %s | %s", clock.ActionWarn, clock.ActionRefuse))
+	if skewAction == nil {
+		return errors.New("skew action not given")
+	}
+
+	drainBlocks := flag.Int64("drain-blocks", 0, "number of blocks to wait for after submission has stopped so that in-flight txs are confirmed - for value 0 the run ends immediately")
+	if drainBlocks == nil {
+		return errors.New("drain blocks not given")
+	}
+
+	drainTimeout := flag.Duration("drain-timeout", time.Minute, "maximum time to wait for the drain blocks")
+	if drainTimeout == nil {
+		return errors.New("drain timeout not given")
+	}
+
+	rateControl := flag.String("rate-control", ratecontrol.ModeNone, fmt.Sprintf("adjustment of the rate based on the mempool of the node and rejected txs - one of %s | %s | %s", ratecontrol.ModeNone, ratecontrol.ModeTarget, ratecontrol.ModeSearch))
+	if rateControl == nil {
+		return errors.New("rate control not given")
+	}
+
+	rateControlInterval := flag.Duration("rate-control-interval", 30*time.Second, "interval in which the rate is adjusted by the rate control")
+	if rateControlInterval == nil {
+		return errors.New("rate control interval not given")
+	}
+
+	targetMempoolTxs := flag.Int64("target-mempool-txs", 0, "number of txs in the mempool which the rate control targets - for value 0 the target mempool usage is used")
+	if targetMempoolTxs == nil {
+		return errors.New("target mempool txs not given")
+	}
+
+	targetMempoolUsage := flag.Float64("target-mempool-usage", 0.5, "memory usage of the mempool as fraction of its maximum size which the rate control targets")
+	if targetMempoolUsage == nil {
+		return errors.New("target mempool usage not given")
+	}
+
+	maxRejection := flag.Float64("max-rejection", 0.05, "ratio of rejected txs above which the rate control decreases the rate")
+	if maxRejection == nil {
+		return errors.New("max rejection not given")
+	}
+
+	minRate := flag.Int64("min-rate", 1, "minimum rate in txs per second set by the rate control")
+	if minRate == nil {
+		return errors.New("min rate not given")
+	}
+
+	maxRate := flag.Int64("max-rate", 0, "maximum rate in txs per second set by the rate control - for value 0 the rate is not capped")
+	if maxRate == nil {
+		return errors.New("max rate not given")
+	}
+
+	submit := flag.String("submit", submitRPC, fmt.Sprintf("how the txs are submitted - one of %s | %s", submitRPC, submitP2P))
+	if submit == nil {
+		return errors.New("submit not given")
+	}
+
+	p2pAddr := flag.String("p2p-addr", "", "P2P address of the node to which the txs are submitted with submit p2p - default is <host>:18444")
+	if p2pAddr == nil {
+		return errors.New("p2p address not given")
+	}
+
+	blockSource := flag.String("block-source", blockSourceZMQ, fmt.Sprintf("source of the blocks seen by the node - one of %s | %s", blockSourceZMQ, blockSourceP2P))
+	if blockSource == nil {
+		return errors.New("block source not given")
+	}
+
+	observe := flag.String("observe", "", "comma separated P2P addresses of further nodes whose block announcements are logged with block source p2p e.g. node2:18444,node3:18444")
+	if observe == nil {
+		return errors.New("observe not given")
+	}
+
+	targets := flag.String("targets", "", "comma separated nodes to which the txs are submitted instead of the node given by host and rpc-port e.g. node1:18443,node2:18443=2 - the optional number after = is the weight of the node. With submit p2p the P2P addresses of the nodes are given.")
+	if targets == nil {
+		return errors.New("targets not given")
+	}
+
+	route := flag.String("route", router.PolicyRoundRobin, fmt.Sprintf("policy by which the txs are routed to the targets - one of %s | %s | %s | %s", router.PolicyRoundRobin, router.PolicyRandom, router.PolicyWeighted, router.PolicyAll))
+	if route == nil {
+		return errors.New("route not given")
+	}
+
+	routeSticky := flag.Bool("route-sticky", true, "submit a tx to the same target as the tx whose output it spends so that chains of unconfirmed txs are not rejected")
+	if routeSticky == nil {
+		return errors.New("route sticky not given")
+	}
+
+	doubleSpendRatio := flag.Float64("double-spend-ratio", 0, "ratio of the utxos which are double spent by two txs sent to two different targets at the same time - requires at least two targets, for value 0 no double spends are sent")
+	if doubleSpendRatio == nil {
+		return errors.New("double spend ratio not given")
+	}
+
+	flag.Parse()
+
+	if *skewAction != clock.ActionWarn && *skewAction != clock.ActionRefuse {
+		return fmt.Errorf("given skew action %s not valid - has to be either %s or %s", *skewAction, clock.ActionWarn, clock.ActionRefuse)
 	}
 
-	err = zmqSubscriber.Start(ctx)
+	switch *blockTxs {
+	case node_client.TxsAll, node_client.TxsOwn, node_client.TxsForeign:
+	default:
+		return fmt.Errorf("given block txs %s not valid", *blockTxs)
+	}
+
+	startBroadcastingAt, err := parseStartAt(*startAt)
 	if err != nil {
 		return err
 	}
 
-	newBroadcaster, err := broadcaster.NewBroadcaster(proc)
+	routeTargets, err := parseTargets(*targets)
 	if err != nil {
 		return err
 	}
 
-	prepareUtxosAt := startBroadcastingAt.Add(-1 * *wait)
-	timer := prepareUtxosAt.Sub(time.Now().UTC())
+	if *doubleSpendRatio < 0 || *doubleSpendRatio > 1 {
+		return fmt.Errorf("given double spend ratio %.2f not valid - has to be within [0, 1]", *doubleSpendRatio)
+	}
 
-	logger.Info("Time", "prepare utxos at", prepareUtxosAt.String(), "timer", timer.String()) // Todo: Remove
+	if *p2pAddr == "" {
+		*p2pAddr = net.JoinHostPort(*host, strconv.Itoa(p2pPortDefault))
+	}
 
-	startTimer := time.NewTimer(timer)
-	logger.Info("Waiting to prepare utxos", "until", prepareUtxosAt.String(), "now", time.Now().In(time.UTC).String())
-	<-startTimer.C
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
 
-	logger.Info("Preparing utxos")
-	err = newBroadcaster.PrepareUtxos(10000)
-	if err != nil {
-		return err
+	var dash *dashboard.Dashboard
+	if *tui {
+		dash = dashboard.New()
+		logger = slog.New(dash.Handler())
 	}
-	newBlockCh := make(chan string, 100)
 
-	newMiner := miner.New(proc)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	newListener := listener.New(proc)
+	cancelOnSignal(cancel)
 
-	newListener.Start(ctx, messageChan, newBlockCh, broadcasterLogger, startBroadcastingAt)
-	newMiner.Start(ctx, *generateBlocks, newBlockCh, broadcasterLogger, startBroadcastingAt)
+	if dash != nil {
+		go dash.Run(ctx, os.Stdout, time.Second)
+	}
 
-	doneChan := make(chan error)
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt) // Listen for Ctrl+C
+	return runNode(ctx, nodeConfig{
+		blockchain:          *blockchain,
+		host:                *host,
+		rpcPort:             *rpcPort,
+		zmqPort:             *zmqPort,
+		outputPath:          *outputPath,
+		rate:                *txsRate,
+		limit:               *limit,
+		wait:                *wait,
+		startAt:             startBroadcastingAt,
+		seed:                *seed,
+		arrival:             *arrival,
+		logSubmissions:      *logSubmissions,
+		genBlocks:           *generateBlocks,
+		minerStrategy:       *minerStrategy,
+		withholdDelay:       *withholdDelay,
+		selfishLead:         *selfishLead,
+		selfishMaxWithhold:  *selfishMaxWithhold,
+		blockEmpty:          *blockEmpty,
+		blockMaxSize:        *blockMaxSize,
+		blockTxs:            *blockTxs,
+		retargetMode:        *retargetMode,
+		retargetWindow:      *retargetWindow,
+		retargetTarget:      *retargetTarget,
+		maxSkew:             *maxSkew,
+		skewAction:          *skewAction,
+		drainBlocks:         *drainBlocks,
+		drainTimeout:        *drainTimeout,
+		rateControl:         *rateControl,
+		rateControlInterval: *rateControlInterval,
+		targetMempoolTxs:    *targetMempoolTxs,
+		targetMempoolUsage:  *targetMempoolUsage,
+		maxRejection:        *maxRejection,
+		minRate:             *minRate,
+		maxRate:             *maxRate,
+		blockSource:         *blockSource,
+		observe:             splitList(*observe),
+		submit:              *submit,
+		p2pAddr:             *p2pAddr,
+		route:               *route,
+		routeTargets:        routeTargets,
+		routeSticky:         *routeSticky,
+		doubleSpendRatio:    *doubleSpendRatio,
+		metricsAddr:         *metricsAddr,
+		apiAddr:             *apiAddr,
+	}, logger)
+}
+
+// cancelOnSignal cancels the context once an interrupt or terminate signal is received. A second signal exits
+// immediately without waiting for the run to be drained.
+func cancelOnSignal(cancel context.CancelFunc) {
+	signalChan := make(chan os.Signal, 2)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM) // Listen for Ctrl+C and docker stop
 
 	go func() {
-		err = newBroadcaster.Start(*txsRate, *limit, broadcasterLogger, startBroadcastingAt)
-		doneChan <- err
+		<-signalChan
+		cancel()
+
+		sig := <-signalChan
+		log.Fatalf("received second signal %s - exiting without drain", sig)
 	}()
+}
+
+// splitList splits a comma separated list and drops empty elements
+func splitList(list string) []string {
+	var result []string
+	for _, element := range strings.Split(list, ",") {
+		element = strings.TrimSpace(element)
+		if element != "" {
+			result = append(result, element)
+		}
+	}
 
-	select {
-	case <-signalChan:
-		logger.Info("Shutdown signal received. Shutting down the rate broadcaster.")
-		break
-	case err = <-doneChan:
+	return result
+}
+
+// parseTargets parses comma separated targets of the form host:port or host:port=weight. The weight defaults to 1.
+func parseTargets(targets string) ([]routeTarget, error) {
+	if targets == "" {
+		return nil, nil
+	}
+
+	var result []routeTarget
+	for _, target := range strings.Split(targets, ",") {
+		addr, weightString, hasWeight := strings.Cut(strings.TrimSpace(target), "=")
+
+		weight := 1.0
+		if hasWeight {
+			var err error
+			weight, err = strconv.ParseFloat(weightString, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse weight of target %s: %v", target, err)
+			}
+		}
+
+		host, portString, err := net.SplitHostPort(addr)
 		if err != nil {
-			logger.Error("Error during broadcasting", slog.String("err", err.Error()))
+			return nil, fmt.Errorf("failed to parse target %s: %v", target, err)
 		}
+
+		port, err := strconv.Atoi(portString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse port of target %s: %v", target, err)
+		}
+
+		result = append(result, routeTarget{name: addr, host: host, rpcPort: port, p2pAddr: addr, weight: weight})
+	}
+
+	return result, nil
+}
+
+// parseStartAt parses the given RFC3339 start time. If no start time is given, the start time is set to one minute from now
+func parseStartAt(startAt string) (time.Time, error) {
+	var err error
+
+	now := time.Now()
+	var startTime time.Time
+	if startAt == "" {
+		startTime = now.Round(5 * time.Second).Add(60 * time.Second)
+	} else {
+		startTime, err = time.Parse(time.RFC3339, startAt)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if startTime.Before(now) {
+		return time.Time{}, errors.New("start time is earlier than now")
+	}
+
+	return startTime.In(time.UTC), nil
+}
+
+// newOutputLogger returns a logger which additionally writes JSON logs to the output file if an output path is given
+func newOutputLogger(outputPath string, logger *slog.Logger) (*slog.Logger, func(), error) {
+	if outputPath == "" {
+		return logger, func() {}, nil
 	}
 
-	newBroadcaster.Shutdown()
-	logger.Info("Broadcasting shutdown complete")
-	return nil
+	path := filepath.Dir(outputPath)
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create path: %v", err)
+	}
+
+	logFile, err := os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %v", err)
+	}
+
+	outputLogger := slog.New(
+		slogmulti.Fanout(
+			slog.NewJSONHandler(logFile, &slog.HandlerOptions{Level: slog.LevelInfo}),
+			logger.Handler(),
+		),
+	)
+
+	closeOutput := func() {
+		_ = logFile.Sync()
+		_ = logFile.Close()
+	}
+
+	return outputLogger, closeOutput, nil
+}
+
+// getSeed returns the given seed or a random seed if the given seed is 0
+func getSeed(seed int64) int64 {
+	if seed != 0 {
+		return seed
+	}
+
+	return time.Now().UnixNano()
+}
+
+// newRand creates a source of randomness for a component. Each component gets its own source derived from the seed so
+// that its random sequence does not depend on how often other components draw random numbers.
+func newRand(seed int64, component string) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(component))
+
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
 }
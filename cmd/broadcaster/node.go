@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/api"
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/clock"
+	"github.com/boecklim/node-analysis/pkg/doublespend"
+	"github.com/boecklim/node-analysis/pkg/listener"
+	"github.com/boecklim/node-analysis/pkg/metrics"
+	"github.com/boecklim/node-analysis/pkg/miner"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+	"github.com/boecklim/node-analysis/pkg/p2p"
+	"github.com/boecklim/node-analysis/pkg/ratecontrol"
+	"github.com/boecklim/node-analysis/pkg/scenario"
+	"github.com/boecklim/node-analysis/pkg/zmq"
+)
+
+// nodeConfig contains the settings with which broadcaster, listener and miner are run against one node
+type nodeConfig struct {
+	name       string
+	blockchain string
+	host       string
+	rpcPort    int
+	zmqPort    int
+	outputPath string
+
+	rate           int64
+	events         []scenario.Event
+	limit          time.Duration
+	wait           time.Duration
+	startAt        time.Time
+	seed           int64
+	arrival        string
+	logSubmissions bool
+
+	genBlocks          time.Duration
+	minerStrategy      string
+	withholdDelay      time.Duration
+	selfishLead        int
+	selfishMaxWithhold time.Duration
+	blockEmpty         bool
+	blockMaxSize       uint64
+	blockTxs           string
+	retargetMode       string
+	retargetWindow     int
+	retargetTarget     time.Duration
+
+	maxSkew    time.Duration
+	skewAction string
+
+	drainBlocks  int64
+	drainTimeout time.Duration
+
+	rateControl         string
+	rateControlInterval time.Duration
+	targetMempoolTxs    int64
+	targetMempoolUsage  float64
+	maxRejection        float64
+	minRate             int64
+	maxRate             int64
+
+	blockSource  string
+	observe      []string
+	submit       string
+	p2pAddr      string
+	route        string
+	routeTargets []routeTarget
+	routeSticky  bool
+
+	doubleSpendRatio float64
+
+	metricsAddr string
+	apiAddr     string
+
+	// output and metrics are shared by all nodes of an orchestrated run. If set, outputPath and metricsAddr are not used.
+	output  *slog.Logger
+	metrics *metrics.Metrics
+
+	// awaitStart is called after the utxos have been prepared and returns the start time. If set, startAt and wait are
+	// not used and the utxos are prepared immediately.
+	awaitStart func(ctx context.Context) (time.Time, error)
+}
+
+// runNode prepares the utxos and runs broadcaster, listener and miner against the node until the time limit is
+// reached, a stop is requested via the API or the context is canceled. Submission is stopped first, then listener and
+// miner keep running until the drain blocks have been seen or the drain timeout is reached. At the end a run summary is
+// written to the output.
+func runNode(ctx context.Context, cfg nodeConfig, logger *slog.Logger) error {
+	var err error
+
+	runSeed := getSeed(cfg.seed)
+
+	runMetrics := cfg.metrics
+	if runMetrics == nil && cfg.metricsAddr != "" {
+		runMetrics = metrics.New()
+	}
+
+	btcClient, err := node_client.New(cfg.host, cfg.rpcPort, rpcUser, rpcPassword, logger, node_client.WithMetrics(runMetrics))
+	if err != nil {
+		return err
+	}
+	var proc *node_client.Processor
+
+	procOpts := []node_client.Option{
+		node_client.WithBlockTemplate(node_client.BlockTemplateOptions{
+			Empty:        cfg.blockEmpty,
+			MaxSizeBytes: cfg.blockMaxSize,
+			Txs:          cfg.blockTxs,
+		}),
+		node_client.WithRand(newRand(runSeed, "processor")),
+	}
+
+	if cfg.doubleSpendRatio > 0 && len(cfg.routeTargets) < 2 {
+		return fmt.Errorf("double spends require at least two route targets - %d given", len(cfg.routeTargets))
+	}
+
+	var submission *txSubmission
+	if len(cfg.routeTargets) > 0 || cfg.submit == submitP2P {
+		submission, err = newTxSubmission(cfg, runSeed, runMetrics, logger)
+		if err != nil {
+			return err
+		}
+		defer submission.close()
+
+		procOpts = append(procOpts, node_client.WithSubmitter(submission.submitter()))
+	}
+
+	switch cfg.blockchain {
+	case btcBlockchain:
+		proc, err = node_client.NewProcessor(btcClient, logger, false, procOpts...)
+	case bsvBlockchain:
+		proc, err = node_client.NewProcessor(btcClient, logger, true, procOpts...)
+	default:
+		return fmt.Errorf("given blockchain %s not valid - has to be either %s or %s", cfg.blockchain, bsvBlockchain, btcBlockchain)
+	}
+	if err != nil {
+		return err
+	}
+
+	var strategy miner.Strategy
+	switch cfg.minerStrategy {
+	case miner.StrategyHonest:
+		strategy = miner.NewHonestStrategy(proc)
+	case miner.StrategySelfish:
+		strategy = miner.NewSelfishStrategy(proc, cfg.selfishLead, cfg.selfishMaxWithhold)
+	case miner.StrategyWithhold:
+		strategy = miner.NewWithholdingStrategy(proc, cfg.withholdDelay)
+	default:
+		return fmt.Errorf("given miner strategy %s not valid", cfg.minerStrategy)
+	}
+
+	minerOpts := []miner.Option{miner.WithStrategy(strategy), miner.WithRand(newRand(runSeed, "miner")), miner.WithMetrics(runMetrics)}
+	if cfg.retargetMode != miner.RetargetNone {
+		retargetTarget := cfg.retargetTarget
+		if retargetTarget == 0 {
+			retargetTarget = cfg.genBlocks
+		}
+
+		retarget, err := miner.NewRetarget(cfg.retargetMode, cfg.retargetWindow, retargetTarget)
+		if err != nil {
+			return err
+		}
+
+		minerOpts = append(minerOpts, miner.WithRetarget(retarget))
+	}
+
+	info, err := btcClient.GetMiningInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get info: %v", err)
+	}
+	logger.Info("mining info", "blocks", info.Blocks, "errors", info.Errors)
+
+	networkInfo, err := btcClient.GetNetworkInfo()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("network info", "version", networkInfo.Version)
+
+	var clockOffset *clock.Measurement
+	if cfg.maxSkew > 0 {
+		measurement, err := clock.New(btcClient).Measure()
+		switch {
+		case err != nil && cfg.skewAction == clock.ActionRefuse:
+			return fmt.Errorf("failed to measure clock offset: %w", err)
+		case err != nil:
+			// The run is not aborted as the offset is only recorded for the analysis
+			logger.Warn("Failed to measure clock offset", slog.String("err", err.Error()))
+		default:
+			logger.Info("Clock offset measured", measurement.Attrs()...)
+
+			err = clock.Check(measurement, cfg.maxSkew, cfg.skewAction, logger)
+			if err != nil {
+				return err
+			}
+			clockOffset = &measurement
+		}
+	}
+
+	broadcasterLogger := cfg.output
+	if broadcasterLogger == nil {
+		var closeOutput func()
+		broadcasterLogger, closeOutput, err = newOutputLogger(cfg.outputPath, logger)
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+	}
+
+	if cfg.name != "" {
+		logger = logger.With(slog.String("node", cfg.name))
+	}
+
+	messageChan := make(chan []string, 1000)
+
+	var notificationChan chan []string
+	if cfg.doubleSpendRatio > 0 && cfg.blockchain == bsvBlockchain {
+		notificationChan = make(chan []string, 1000)
+	}
+
+	// The components keep running after ctx has been canceled by a shutdown signal so that the run can be drained
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	if cfg.metrics == nil && cfg.metricsAddr != "" {
+		runMetrics.Serve(runCtx, cfg.metricsAddr, logger)
+	}
+
+	var observer *p2p.Observer
+	switch cfg.blockSource {
+	case blockSourceZMQ, "":
+		zmqSubscriber, err := zmq.New(runCtx, cfg.host, cfg.zmqPort, logger, zmq.WithMetrics(runMetrics))
+		if err != nil {
+			return err
+		}
+
+		err = zmqSubscriber.Subscribe(pubhashblockTopic, messageChan)
+		if err != nil {
+			return err
+		}
+
+		if notificationChan != nil {
+			// The double spend notifications of BSV are only available via ZMQ
+			for _, topic := range doublespend.Topics {
+				err = zmqSubscriber.Subscribe(topic, notificationChan)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err = zmqSubscriber.Start(runCtx)
+		if err != nil {
+			return err
+		}
+	case blockSourceP2P:
+		observer, err = p2p.NewObserver(append([]string{cfg.p2pAddr}, cfg.observe...))
+		if err != nil {
+			return err
+		}
+
+		err = observer.Subscribe(p2p.TopicHashBlock, messageChan)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("given block source %s not valid - has to be either %s or %s", cfg.blockSource, blockSourceZMQ, blockSourceP2P)
+	}
+
+	broadcasterOpts := []broadcaster.Option{broadcaster.WithArrival(cfg.arrival), broadcaster.WithRand(newRand(runSeed, "broadcaster")), broadcaster.WithMetrics(runMetrics)}
+	if cfg.logSubmissions {
+		broadcasterOpts = append(broadcasterOpts, broadcaster.WithSubmissionLogging())
+	}
+
+	var tracker *doublespend.Tracker
+	if cfg.doubleSpendRatio > 0 {
+		tracker = doublespend.New(proc, btcClient)
+		broadcasterOpts = append(broadcasterOpts, broadcaster.WithDoubleSpends(tracker, cfg.doubleSpendRatio))
+	}
+
+	newBroadcaster, err := broadcaster.NewBroadcaster(proc, broadcasterOpts...)
+	if err != nil {
+		return err
+	}
+
+	if submission != nil {
+		submission.setFailureHandler(newBroadcaster.SubmissionFailed)
+	}
+
+	var rateController *ratecontrol.Controller
+	if cfg.rateControl != "" && cfg.rateControl != ratecontrol.ModeNone {
+		rateController, err = ratecontrol.New(cfg.rateControl, newBroadcaster, proc,
+			ratecontrol.WithInterval(cfg.rateControlInterval),
+			ratecontrol.WithTargetTxs(cfg.targetMempoolTxs),
+			ratecontrol.WithTargetUsage(cfg.targetMempoolUsage),
+			ratecontrol.WithMaxRejection(cfg.maxRejection),
+			ratecontrol.WithRateLimits(cfg.minRate, cfg.maxRate),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.awaitStart == nil {
+		prepareUtxosAt := cfg.startAt.Add(-1 * cfg.wait)
+		timer := prepareUtxosAt.Sub(time.Now().UTC())
+
+		logger.Info("Time", "prepare utxos at", prepareUtxosAt.String(), "timer", timer.String()) // Todo: Remove
+
+		startTimer := time.NewTimer(timer)
+		logger.Info("Waiting to prepare utxos", "until", prepareUtxosAt.String(), "now", time.Now().In(time.UTC).String())
+		<-startTimer.C
+	}
+
+	logger.Info("Preparing utxos")
+	err = newBroadcaster.PrepareUtxos(10000)
+	if err != nil {
+		return err
+	}
+
+	if cfg.awaitStart != nil {
+		logger.Info("Waiting for start")
+		cfg.startAt, err = cfg.awaitStart(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	runAttrs := []any{
+		slog.Int64("seed", runSeed),
+		slog.String("blockchain", cfg.blockchain),
+		slog.String("host", cfg.host),
+		slog.Int64("rate", cfg.rate),
+		slog.String("limit", cfg.limit.String()),
+		slog.String("gen-blocks", cfg.genBlocks.String()),
+		slog.String("miner-strategy", cfg.minerStrategy),
+		slog.String("start-at", cfg.startAt.Format(time.RFC3339)),
+	}
+	if cfg.name != "" {
+		runAttrs = append(runAttrs, slog.String("node", cfg.name))
+	}
+	broadcasterLogger.Info("Run started", runAttrs...)
+
+	if cfg.name != "" {
+		broadcasterLogger = broadcasterLogger.With(slog.String("node", cfg.name))
+	}
+
+	if clockOffset != nil {
+		// The offset is recorded so that the timestamps of several instances can be corrected during analysis
+		broadcasterLogger.Info(clock.MsgClockOffset, clockOffset.Attrs()...)
+	}
+
+	var newBlockCh chan string
+	if cfg.genBlocks > 0 {
+		newBlockCh = make(chan string, 100)
+	}
+
+	newMiner := miner.New(proc, minerOpts...)
+
+	newListener := listener.New(proc, listener.WithMetrics(runMetrics))
+
+	if observer != nil {
+		// The observer is started once the output is ready as it logs the block announcements of all observed nodes
+		err = observer.Start(runCtx, broadcasterLogger, cfg.startAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	newListener.Start(runCtx, messageChan, newBlockCh, broadcasterLogger, cfg.startAt)
+	if cfg.genBlocks > 0 {
+		newMiner.Start(runCtx, cfg.genBlocks, newBlockCh, broadcasterLogger, cfg.startAt)
+	}
+
+	doneChan := make(chan error, 1)
+
+	stopChan := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { close(stopChan) })
+	}
+
+	if cfg.apiAddr != "" {
+		api.New(newBroadcaster, newMiner, newListener, stop, broadcasterLogger).Serve(runCtx, cfg.apiAddr)
+	}
+
+	if len(cfg.events) > 0 {
+		scenario.NewScheduler(cfg.events, newBroadcaster, newMiner, proc, stop).Start(runCtx, cfg.startAt, broadcasterLogger)
+	}
+
+	if rateController != nil {
+		rateController.Start(runCtx, cfg.startAt, broadcasterLogger)
+	}
+
+	if tracker != nil {
+		tracker.Start(runCtx, notificationChan, broadcasterLogger)
+	}
+
+	go func() {
+		err = newBroadcaster.Start(cfg.rate, cfg.limit, broadcasterLogger, cfg.startAt)
+		doneChan <- err
+	}()
+
+	reason := "limit"
+	select {
+	case <-ctx.Done():
+		reason = "signal"
+		logger.Info("Shutdown signal received. Shutting down the rate broadcaster.")
+	case <-stopChan:
+		reason = "stop"
+		logger.Info("Stop requested. Shutting down the rate broadcaster.")
+	case err = <-doneChan:
+		if err != nil {
+			reason = "error"
+			logger.Error("Error during broadcasting", slog.String("err", err.Error()))
+		}
+	}
+
+	newBroadcaster.Shutdown()
+	logger.Info("Broadcasting shutdown complete")
+
+	stoppedAt := time.Now()
+	blocksAtStop := newListener.BlocksSeen()
+	if cfg.drainBlocks > 0 {
+		logger.Info("Draining", slog.Int64("blocks", cfg.drainBlocks), slog.String("timeout", cfg.drainTimeout.String()))
+
+		drainCtx, drainCancel := context.WithTimeout(runCtx, cfg.drainTimeout)
+		err = newListener.AwaitBlocks(drainCtx, blocksAtStop+cfg.drainBlocks)
+		drainCancel()
+		if err != nil {
+			logger.Warn("Drain timeout reached", slog.Int64("blocks", newListener.BlocksSeen()-blocksAtStop))
+		}
+	}
+
+	cancel()
+	newListener.Wait()
+	if observer != nil {
+		observer.Wait()
+	}
+	if cfg.genBlocks > 0 {
+		newMiner.Wait()
+	}
+	if tracker != nil {
+		tracker.Wait()
+	}
+
+	summaryAttrs := []any{
+		slog.String("reason", reason),
+		slog.Int64("total txs", newBroadcaster.Status().TotalTxs),
+		slog.Int("utxos", newBroadcaster.Status().Utxos),
+		slog.Int64("blocks", newListener.BlocksSeen()),
+		slog.Int64("drained blocks", newListener.BlocksSeen()-blocksAtStop),
+		slog.String("drain duration", time.Since(stoppedAt).Round(time.Millisecond).String()),
+		slog.String("elapsed", time.Since(cfg.startAt).Round(time.Millisecond).String()),
+	}
+
+	if rateController != nil && cfg.rateControl == ratecontrol.ModeSearch {
+		summaryAttrs = append(summaryAttrs, slog.Int64("max sustainable rate", rateController.MaxSustainableRate()))
+	}
+
+	mempoolSize, err := proc.GetMempoolSize()
+	if err != nil {
+		logger.Warn("Failed to get mempool size", slog.String("err", err.Error()))
+	} else {
+		summaryAttrs = append(summaryAttrs, slog.Uint64("mempool txs", mempoolSize))
+	}
+
+	broadcasterLogger.Info("Run summary", summaryAttrs...)
+
+	if submission != nil {
+		submission.logStats(broadcasterLogger)
+	}
+
+	if tracker != nil {
+		logDoubleSpendStats(tracker.Stats(), broadcasterLogger)
+	}
+
+	return nil
+}
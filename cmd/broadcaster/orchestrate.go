@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lmittmann/tint"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/boecklim/node-analysis/pkg/metrics"
+	"github.com/boecklim/node-analysis/pkg/scenario"
+)
+
+// runOrchestrate runs all nodes of a scenario in this process. In contrast to the scenario command all nodes write to
+// one output file and expose their metrics labeled by node at one address so that the run is recorded with a single
+// clock.
+func runOrchestrate(args []string) error {
+	fs := flag.NewFlagSet(orchestrateCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <scenario file>\n", os.Args[0], orchestrateCommand)
+		fs.PrintDefaults()
+	}
+
+	output := fs.String("output", "", "path to the output file of all nodes - default is <output dir>/<scenario name>.log if the scenario has an output directory")
+	startAt := fs.String("start-at", "", "time at which to start overriding the start time given in the scenario - format RFC3339")
+	metricsAddr := fs.String("metrics-addr", "", "address at which to serve the prometheus metrics of all nodes e.g. :9100 - if empty no metrics are served")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one scenario file has to be given")
+	}
+
+	s, err := scenario.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *startAt == "" {
+		*startAt = s.StartAt
+	}
+	startTime, err := parseStartAt(*startAt)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" && s.Output.Dir != "" {
+		*output = filepath.Join(s.Output.Dir, s.Name+".log")
+	}
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
+
+	outputLogger, closeOutput, err := newOutputLogger(*output, logger)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSignal(cancel)
+
+	configs := scenarioNodeConfigs(s, startTime)
+	for i := range configs {
+		configs[i].output = outputLogger
+	}
+
+	if *metricsAddr != "" {
+		// The metrics of all nodes are registered at the same registry and distinguished by the node label
+		registry := prometheus.NewRegistry()
+		for i := range configs {
+			configs[i].metrics = metrics.New(metrics.WithRegistry(registry), metrics.WithNode(configs[i].name))
+		}
+		configs[0].metrics.Serve(ctx, *metricsAddr, logger)
+	}
+
+	logger.Info("Orchestrating scenario", "name", s.Name, "nodes", len(configs), "start-at", startTime.Format(time.RFC3339), "duration", s.Duration.String(), "output", *output)
+
+	return runNodes(ctx, configs, logger)
+}
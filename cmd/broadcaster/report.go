@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/analysis"
+)
+
+// runReport writes an HTML report with charts of the runs found in the given output files
+func runReport(args []string) (err error) {
+	fs := flag.NewFlagSet(reportCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <output file>...\n", os.Args[0], reportCommand)
+		fs.PrintDefaults()
+	}
+
+	output := fs.String("output", "./results/report.html", "path of the HTML file to which the report is written")
+	title := fs.String("title", "Node analysis report", "title of the report")
+	skewThreshold := fs.Duration("skew-threshold", time.Second, "clock skew between instances above which an instance is flagged")
+	correctSkew := fs.Bool("correct-skew", false, "correct the timestamps of each instance by its estimated clock skew before calculating propagation")
+
+	err = fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no output files given")
+	}
+
+	runs, err := readAllRuns(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	report := analysis.NewReport(*title, runs, analysis.Merge(runs, *skewThreshold, *correctSkew))
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %v", err)
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+
+	err = report.WriteHTML(f)
+	if err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+
+	fmt.Printf("Report of %d runs written to %s\n", len(runs), *output)
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/boecklim/node-analysis/pkg/scenario"
+)
+
+// runScenario runs all nodes of a scenario file or only the selected node
+func runScenario(args []string) error {
+	fs := flag.NewFlagSet(scenarioCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <scenario file>\n", os.Args[0], scenarioCommand)
+		fs.PrintDefaults()
+	}
+
+	nodeName := fs.String("node", "", "name of the node to run - if empty all nodes of the scenario are run in this process")
+	host := fs.String("host", "", "host of the selected node overriding the host given in the scenario e.g. localhost on remote instances")
+	startAt := fs.String("start-at", "", "time at which to start overriding the start time given in the scenario - format RFC3339")
+	output := fs.String("output", "", "path to the output file of the selected node overriding the output directory given in the scenario")
+	validate := fs.Bool("validate", false, "only validate the scenario file")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one scenario file has to be given")
+	}
+
+	s, err := scenario.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *validate {
+		fmt.Printf("Scenario %s is valid - %d nodes, duration %s\n", s.Name, len(s.Nodes), s.Duration)
+		return nil
+	}
+
+	if *startAt == "" {
+		*startAt = s.StartAt
+	}
+	startTime, err := parseStartAt(*startAt)
+	if err != nil {
+		return err
+	}
+
+	configs := scenarioNodeConfigs(s, startTime)
+
+	if *nodeName != "" {
+		index, _, err := s.Node(*nodeName)
+		if err != nil {
+			return err
+		}
+
+		cfg := configs[index]
+		if *host != "" {
+			cfg.host = *host
+		}
+		if *output != "" {
+			cfg.outputPath = *output
+		}
+		configs = []nodeConfig{cfg}
+	} else if *host != "" || *output != "" {
+		return errors.New("host and output can only be overridden for a selected node")
+	}
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancelOnSignal(cancel)
+
+	logger.Info("Running scenario", "name", s.Name, "nodes", len(configs), "start-at", startTime.Format(time.RFC3339), "duration", s.Duration.String())
+
+	return runNodes(ctx, configs, logger)
+}
+
+// runNodes runs the nodes concurrently and returns the errors of all nodes
+func runNodes(ctx context.Context, configs []nodeConfig, logger *slog.Logger) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(configs))
+	for i, cfg := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := runNode(ctx, cfg, logger)
+			if err != nil {
+				errs[i] = fmt.Errorf("node %s: %w", cfg.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// scenarioNodeConfigs returns the configuration of each node of the scenario
+func scenarioNodeConfigs(s *scenario.Scenario, startAt time.Time) []nodeConfig {
+	configs := make([]nodeConfig, 0, len(s.Nodes))
+
+	for i, node := range s.Nodes {
+		m := s.MinerOf(node)
+
+		rate := node.Rate
+		if len(node.RateProfile) > 0 && node.RateProfile[0].At == 0 {
+			rate = node.RateProfile[0].Rate
+		}
+
+		outputPath := ""
+		if s.Output.Dir != "" {
+			outputPath = filepath.Join(s.Output.Dir, node.Name+".log")
+		}
+
+		cfg := nodeConfig{
+			name:                node.Name,
+			blockchain:          s.Blockchain,
+			host:                node.Host,
+			rpcPort:             node.RPCPort,
+			zmqPort:             node.ZMQPort,
+			outputPath:          outputPath,
+			rate:                rate,
+			events:              s.EventsOf(node),
+			limit:               s.Duration,
+			wait:                s.WaitOf(node),
+			startAt:             startAt,
+			seed:                s.SeedOf(i),
+			arrival:             s.Arrival,
+			logSubmissions:      s.Output.LogSubmissions,
+			genBlocks:           m.Interval,
+			minerStrategy:       m.Strategy,
+			withholdDelay:       m.WithholdDelay,
+			selfishLead:         m.SelfishLead,
+			selfishMaxWithhold:  m.SelfishMaxWithhold,
+			blockEmpty:          m.Block.Empty,
+			blockMaxSize:        m.Block.MaxSize,
+			blockTxs:            m.Block.Txs,
+			retargetMode:        m.Retarget.Mode,
+			retargetWindow:      m.Retarget.Window,
+			retargetTarget:      m.Retarget.Target,
+			maxSkew:             *s.Clock.MaxSkew,
+			skewAction:          s.Clock.SkewAction,
+			drainBlocks:         s.Drain.Blocks,
+			drainTimeout:        s.Drain.Timeout,
+			rateControl:         s.RateControl.Mode,
+			rateControlInterval: s.RateControl.Interval,
+			targetMempoolTxs:    s.RateControl.TargetTxs,
+			targetMempoolUsage:  s.RateControl.TargetUsage,
+			maxRejection:        s.RateControl.MaxRejection,
+			minRate:             s.RateControl.MinRate,
+			maxRate:             s.RateControl.MaxRate,
+			metricsAddr:         node.MetricsAddr,
+			apiAddr:             node.APIAddr,
+		}
+
+		cfg.submit = s.Submit
+		cfg.blockSource = s.BlockSource
+		cfg.p2pAddr = node.P2PAddr
+
+		if node.Route != nil {
+			cfg.route = node.Route.Policy
+			cfg.routeSticky = *node.Route.Sticky
+			cfg.doubleSpendRatio = node.Route.DoubleSpendRatio
+			for _, target := range node.Route.Targets {
+				_, targetNode, _ := s.Node(target.Node)
+				cfg.routeTargets = append(cfg.routeTargets, routeTarget{
+					name:    targetNode.Name,
+					host:    targetNode.Host,
+					rpcPort: targetNode.RPCPort,
+					p2pAddr: targetNode.P2PAddr,
+					weight:  target.Weight,
+				})
+			}
+		}
+
+		configs = append(configs, cfg)
+	}
+
+	return configs
+}
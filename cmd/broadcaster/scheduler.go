@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/boecklim/node-analysis/pkg/miner"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+// runScheduler runs the central mining scheduler which decides for all given nodes which one mines the next block
+func runScheduler(args []string) error {
+	fs := flag.NewFlagSet(schedulerCommand, flag.ExitOnError)
+
+	nodes := fs.String("nodes", fmt.Sprintf("%s:%d", rpcHostDefault, rpcPortDefault), "comma separated list of RPC addresses of the nodes e.g. node1:18443,node2:18443")
+	hashrates := fs.String("hashrates", "", "comma separated list of relative hashrates of the nodes in the same order as given in nodes - if not given all nodes have the same hashrate")
+	generateBlocks := fs.Duration("gen-blocks", 10*time.Minute, "time interval in which a new block is mined in the network on average. Valid time units are s, m, h")
+	limit := fs.Duration("limit", 10*time.Minute, "time limit after which to stop generating blocks")
+	startAt := fs.String("start-at", "", "time at which to start - format RFC3339: e.g. 2024-12-02T21:16:00+01:00")
+	outputPath := fs.String("output", "", "path to output file of scheduler e.g. ./results/scheduler.log")
+	seed := fs.Int64("seed", 0, "seed of the random number generator of the scheduler - for value 0 a random seed is used")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *generateBlocks <= 0 {
+		return errors.New("gen-blocks has to be greater than 0")
+	}
+
+	startSchedulingAt, err := parseStartAt(*startAt)
+	if err != nil {
+		return err
+	}
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
+
+	schedulerLogger, closeOutput, err := newOutputLogger(*outputPath, logger)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	runSeed := getSeed(*seed)
+	schedulerLogger.Info("Run started", slog.String("service", "scheduler"), slog.Int64("seed", runSeed), slog.String("nodes", *nodes), slog.String("gen-blocks", generateBlocks.String()))
+
+	addresses := strings.Split(*nodes, ",")
+
+	nodeHashrates := make([]float64, len(addresses))
+	if *hashrates == "" {
+		for i := range nodeHashrates {
+			nodeHashrates[i] = 1
+		}
+	} else {
+		values := strings.Split(*hashrates, ",")
+		if len(values) != len(addresses) {
+			return fmt.Errorf("number of hashrates %d does not match number of nodes %d", len(values), len(addresses))
+		}
+
+		for i, value := range values {
+			nodeHashrates[i], err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return fmt.Errorf("invalid hashrate %s: %v", value, err)
+			}
+		}
+	}
+
+	schedulerNodes := make([]miner.Node, len(addresses))
+	for i, address := range addresses {
+		address = strings.TrimSpace(address)
+
+		host, portString, err := net.SplitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("invalid node address %s: %v", address, err)
+		}
+
+		port, err := strconv.Atoi(portString)
+		if err != nil {
+			return fmt.Errorf("invalid port of node address %s: %v", address, err)
+		}
+
+		client, err := node_client.New(host, port, rpcUser, rpcPassword, logger)
+		if err != nil {
+			return err
+		}
+
+		proc, err := node_client.NewProcessor(client, logger, false, node_client.WithRand(newRand(runSeed, "processor")))
+		if err != nil {
+			return err
+		}
+
+		schedulerNodes[i] = miner.Node{
+			Name:     address,
+			Hashrate: nodeHashrates[i],
+			Client:   proc,
+		}
+
+		logger.Info("Node", "address", address, "hashrate", nodeHashrates[i])
+	}
+
+	scheduler, err := miner.NewScheduler(schedulerNodes, newRand(runSeed, "scheduler"))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), startSchedulingAt.Add(*limit))
+	defer cancel()
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt) // Listen for Ctrl+C
+
+	scheduler.Start(ctx, *generateBlocks, schedulerLogger, startSchedulingAt)
+
+	select {
+	case <-signalChan:
+		logger.Info("Shutdown signal received. Shutting down the scheduler.")
+	case <-ctx.Done():
+	}
+
+	logger.Info("Scheduler shutdown complete")
+	return nil
+}
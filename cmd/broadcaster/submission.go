@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/doublespend"
+	"github.com/boecklim/node-analysis/pkg/metrics"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+	"github.com/boecklim/node-analysis/pkg/p2p"
+	"github.com/boecklim/node-analysis/pkg/router"
+)
+
+const (
+	submitRPC = "rpc"
+	submitP2P = "p2p"
+
+	blockSourceZMQ = "zmq"
+	blockSourceP2P = "p2p"
+)
+
+// routeTarget is a node to which the txs are routed if the txs are submitted to several nodes
+type routeTarget struct {
+	name    string
+	host    string
+	rpcPort int
+	p2pAddr string
+	weight  float64
+}
+
+// txSubmission submits the txs broadcast at the rate if they are not submitted via RPC to the node itself. The txs are
+// submitted via RPC or as P2P peer to either the node or the route targets.
+type txSubmission struct {
+	router *router.Router
+	peers  []*p2p.Submitter
+	names  []string
+}
+
+func newTxSubmission(cfg nodeConfig, seed int64, m *metrics.Metrics, logger *slog.Logger) (*txSubmission, error) {
+	t := &txSubmission{}
+
+	targets := cfg.routeTargets
+	if len(targets) == 0 {
+		targets = []routeTarget{{name: cfg.p2pAddr, p2pAddr: cfg.p2pAddr, weight: 1}}
+	}
+
+	routerTargets := make([]router.Target, len(targets))
+	for i, target := range targets {
+		var client node_client.Submitter
+
+		switch cfg.submit {
+		case submitP2P:
+			peer := p2p.NewSubmitter(target.p2pAddr, logger)
+			err := peer.Connect()
+			if err != nil {
+				t.close()
+				return nil, err
+			}
+
+			t.peers = append(t.peers, peer)
+			t.names = append(t.names, target.name)
+			client = peer
+		case submitRPC, "":
+			rpcClient, err := node_client.New(target.host, target.rpcPort, rpcUser, rpcPassword, logger, node_client.WithMetrics(m))
+			if err != nil {
+				return nil, err
+			}
+			client = rpcClient
+		default:
+			return nil, fmt.Errorf("given submit %s not valid - has to be either %s or %s", cfg.submit, submitRPC, submitP2P)
+		}
+
+		routerTargets[i] = router.Target{Name: target.name, Client: client, Weight: target.weight}
+	}
+
+	if len(cfg.routeTargets) == 0 {
+		return t, nil
+	}
+
+	opts := []router.Option{router.WithRand(newRand(seed, "router"))}
+	if cfg.routeSticky {
+		opts = append(opts, router.WithSticky())
+	}
+
+	var err error
+	t.router, err = router.New(cfg.route, routerTargets, opts...)
+	if err != nil {
+		t.close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// submitter returns the router or, if the txs are not routed, the P2P submitter of the node
+func (t *txSubmission) submitter() node_client.Submitter {
+	if t.router != nil {
+		return t.router
+	}
+
+	return t.peers[0]
+}
+
+// setFailureHandler reports the txs which have been announced as P2P peer, but have not been requested or have been
+// rejected by the node
+func (t *txSubmission) setFailureHandler(onFailure func(hash string, err error)) {
+	for _, peer := range t.peers {
+		peer.SetFailureHandler(onFailure)
+	}
+}
+
+// logStats logs the submissions to each target
+func (t *txSubmission) logStats(logger *slog.Logger) {
+	if t.router != nil {
+		for _, stats := range t.router.Stats() {
+			logger.Info("Route stats",
+				slog.String("service", "router"),
+				slog.String("target", stats.Name),
+				slog.Int64("submitted", stats.Submitted),
+				slog.Int64("failed", stats.Failed),
+				slog.String("duration", stats.Duration.Round(time.Millisecond).String()),
+			)
+		}
+	}
+
+	for i, peer := range t.peers {
+		stats := peer.Stats()
+		logger.Info("P2P stats",
+			slog.String("service", "p2p"),
+			slog.String("target", t.names[i]),
+			slog.Int64("announced", stats.Announced),
+			slog.Int64("requested", stats.Requested),
+			slog.Int64("rejected", stats.Rejected),
+			slog.Int64("expired", stats.Expired),
+			slog.String("request delay", meanDuration(stats.RequestDelay, stats.Requested).String()),
+		)
+	}
+}
+
+// logDoubleSpendStats logs the double spends and how many of them have been won by each target
+func logDoubleSpendStats(stats doublespend.Stats, logger *slog.Logger) {
+	attrs := []any{
+		slog.String("service", "doublespend"),
+		slog.Int64("submitted", stats.Submitted),
+		slog.Int64("resolved", stats.Resolved),
+		slog.Int64("unresolved", stats.Submitted-stats.Resolved),
+		slog.Int64("notified", stats.Notified),
+		slog.String("mean duration", meanDuration(stats.Duration, stats.Resolved).String()),
+	}
+
+	targets := slices.Sorted(maps.Keys(stats.Wins))
+	for _, target := range targets {
+		attrs = append(attrs, slog.Int64("wins "+target, stats.Wins[target]))
+	}
+
+	logger.Info("Double spend stats", attrs...)
+}
+
+func (t *txSubmission) close() {
+	for _, peer := range t.peers {
+		peer.Close()
+	}
+}
+
+// meanDuration returns the total duration divided by the count or 0 if the count is 0
+func meanDuration(total time.Duration, count int64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+
+	return (total / time.Duration(count)).Round(time.Millisecond)
+}
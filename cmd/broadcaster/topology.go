@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/boecklim/node-analysis/pkg/node_client"
+	"github.com/boecklim/node-analysis/pkg/scenario"
+	"github.com/boecklim/node-analysis/pkg/topology"
+)
+
+const (
+	topologyApply     = "apply"
+	topologyVerify    = "verify"
+	topologyPartition = "partition"
+	topologyIsolate   = "isolate"
+	topologyHeal      = "heal"
+)
+
+// runTopology connects the nodes of a scenario according to its topology, verifies the connections and applies or
+// heals partitions
+func runTopology(args []string) error {
+	fs := flag.NewFlagSet(topologyCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <%s | %s | %s | %s | %s> <scenario file>\n", os.Args[0], topologyCommand, topologyApply, topologyVerify, topologyPartition, topologyIsolate, topologyHeal)
+		fs.PrintDefaults()
+	}
+
+	groupsFlag := fs.String("groups", "", "groups of nodes separated by | which are disconnected from each other e.g. node1,node2|node3,node4 - for partition and verify")
+	nodeName := fs.String("node", "", "node whose network activity is disabled - for isolate")
+	settle := fs.Duration("settle", 5*time.Second, "time to wait for the connections to be established before verifying")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("an action and a scenario file have to be given")
+	}
+	action := fs.Arg(0)
+
+	s, err := scenario.Load(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if s.Topology.Graph == "" {
+		return errors.New("scenario has no topology")
+	}
+
+	graph, err := s.Edges()
+	if err != nil {
+		return err
+	}
+
+	var groups [][]string
+	if *groupsFlag != "" {
+		for _, group := range strings.Split(*groupsFlag, "|") {
+			groups = append(groups, strings.Split(group, ","))
+		}
+	}
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelInfo, TimeFormat: time.RFC3339}))
+
+	nodes := make([]topology.Node, 0, len(s.Nodes))
+	for _, node := range s.Nodes {
+		client, err := node_client.New(node.Host, node.RPCPort, rpcUser, rpcPassword, logger)
+		if err != nil {
+			return err
+		}
+
+		nodes = append(nodes, topology.Node{Name: node.Name, P2PAddr: node.P2PAddr, Client: client})
+	}
+
+	controller := topology.New(nodes, graph, logger)
+
+	expected := graph
+	switch action {
+	case topologyApply:
+		err = controller.Apply()
+	case topologyPartition:
+		if len(groups) < 2 {
+			return errors.New("at least two groups have to be given")
+		}
+		err = controller.Partition(groups)
+		expected = topology.Partition(graph, groups)
+	case topologyIsolate:
+		// The connections of an isolated node cannot be verified
+		return controller.Isolate(*nodeName)
+	case topologyHeal:
+		err = controller.Heal()
+	case topologyVerify:
+		*settle = 0
+		expected = topology.Partition(graph, groups)
+	default:
+		fs.Usage()
+		return fmt.Errorf("action %s not valid", action)
+	}
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(*settle)
+
+	verification, err := controller.Verify(expected)
+	if err != nil {
+		return err
+	}
+
+	if !verification.OK() {
+		return fmt.Errorf("topology does not match: %s", verification)
+	}
+
+	logger.Info("Topology verified", "graph", s.Topology.Graph, "edges", len(expected))
+
+	return nil
+}
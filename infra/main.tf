@@ -161,6 +161,10 @@ data "template_cloudinit_config" "config_btc" {
     content      = <<EOF
 #cloud-config
 write_files:
+  - owner: azureuser:azureuser
+    path: /home/azureuser/scenario.yaml
+    encoding: b64
+    content: ${var.scenario_file == "" ? "" : filebase64(var.scenario_file)}
   - owner: azureuser:azureuser
     path: /root/.bitcoin/bitcoin.conf
     defer: true
@@ -209,7 +213,7 @@ runcmd:
   - wget -P /home/azureuser https://github.com/boecklim/node-analysis/releases/download/${var.broadcaster_version}/broadcaster
   - chmod +x /home/azureuser/broadcaster
   - sleep 120
-  - /home/azureuser/broadcaster -blockchain=btc -gen-blocks=${var.gen_block_time} -rate=${var.rate} -limit=${var.limit} -wait="${(count.index + 1) * 10}s" -start-at=${var.start_time} -output=/home/azureuser/output.log
+  - ${var.coordinator_url != "" ? "/home/azureuser/broadcaster agent -coordinator=${var.coordinator_url} -node=node${count.index + 1} -host=localhost -output=/home/azureuser/output.log" : var.scenario_file == "" ? "/home/azureuser/broadcaster -blockchain=btc -gen-blocks=${var.gen_block_time} -rate=${var.rate} -limit=${var.limit} -wait=\"${(count.index + 1) * 10}s\" -start-at=${var.start_time} -seed=${var.seed == 0 ? 0 : var.seed + count.index} -output=/home/azureuser/output.log" : "/home/azureuser/broadcaster scenario -node=node${count.index + 1} -host=localhost -start-at=${var.start_time} -output=/home/azureuser/output.log /home/azureuser/scenario.yaml"}
 EOF
   }
 }
@@ -225,6 +229,10 @@ data "template_cloudinit_config" "config_bsv" {
     content      = <<EOF
 #cloud-config
 write_files:
+  - owner: azureuser:azureuser
+    path: /home/azureuser/scenario.yaml
+    encoding: b64
+    content: ${var.scenario_file == "" ? "" : filebase64(var.scenario_file)}
   - owner: azureuser:azureuser
     path: /home/azureuser/bitcoin-sv-1.1.0/bitcoin.conf
     defer: true
@@ -261,6 +269,9 @@ write_files:
         blockreconstructionextratxn=100000
         banscore=10000
         zmqpubhashblock=tcp://*:29000
+        zmqpubinvalidtx=tcp://*:29000
+        zmqpubdiscardedfrommempool=tcp://*:29000
+        invalidtxsink=ZMQ
         genesisactivationheight=1
         minminingtxfee=0.0000005
 
@@ -296,7 +307,7 @@ runcmd:
   - wget -P /home/azureuser https://github.com/boecklim/node-analysis/releases/download/${var.broadcaster_version}/broadcaster
   - chmod +x /home/azureuser/broadcaster
   - sleep 120
-  - /home/azureuser/broadcaster -blockchain=bsv -gen-blocks=${var.gen_block_time} -rate=${var.rate} -limit=${var.limit} -wait="${(count.index + 1) * 10}s" -start-at=${var.start_time} -output=/home/azureuser/output.log
+  - ${var.coordinator_url != "" ? "/home/azureuser/broadcaster agent -coordinator=${var.coordinator_url} -node=node${count.index + 1} -host=localhost -output=/home/azureuser/output.log" : var.scenario_file == "" ? "/home/azureuser/broadcaster -blockchain=bsv -gen-blocks=${var.gen_block_time} -rate=${var.rate} -limit=${var.limit} -wait=\"${(count.index + 1) * 10}s\" -start-at=${var.start_time} -seed=${var.seed == 0 ? 0 : var.seed + count.index} -output=/home/azureuser/output.log" : "/home/azureuser/broadcaster scenario -node=node${count.index + 1} -host=localhost -start-at=${var.start_time} -output=/home/azureuser/output.log /home/azureuser/scenario.yaml"}
 EOF
   }
 }
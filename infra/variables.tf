@@ -57,3 +57,21 @@ variable "limit" {
   description = "Time limit after which to stop broadcasting"
   default = "10m"
 }
+
+variable "seed" {
+  type = number
+  description = "Seed of the random number generators - each VM uses the seed plus its index. For value 0 each VM uses a random seed"
+  default = 0
+}
+
+variable "scenario_file" {
+  type = string
+  description = "Path to a scenario file which is run instead of the broadcaster flags - the nodes of the scenario have to be named node1, node2, ... in the order of the VMs"
+  default = ""
+}
+
+variable "coordinator_url" {
+  type = string
+  description = "URL of a coordinator (./broadcaster coordinate <scenario file>) reachable from the VMs - if given each VM runs an agent for the node node<index> instead of a fixed start time"
+  default = ""
+}
@@ -0,0 +1,201 @@
+package analysis
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth        = 860
+	chartHeight       = 340
+	chartMarginLeft   = 80
+	chartMarginRight  = 180
+	chartMarginTop    = 36
+	chartMarginBottom = 50
+	chartTicks        = 5
+)
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// Point is a single value of a chart series
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Series is a named set of points drawn in the same color
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Marker annotates a value of the x axis with a vertical line
+type Marker struct {
+	X     float64
+	Label string
+}
+
+// Chart is a line or scatter chart rendered as inline SVG
+type Chart struct {
+	Title    string
+	XLabel   string
+	YLabel   string
+	Series   []Series
+	Markers  []Marker
+	Scatter  bool
+	TimeAxis bool
+}
+
+// CDFSeries returns the empirical cumulative distribution function of the values
+func CDFSeries(name string, values []float64) Series {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	points := make([]Point, len(sorted))
+	for i, v := range sorted {
+		points[i] = Point{X: v, Y: float64(i+1) / float64(len(sorted))}
+	}
+
+	return Series{Name: name, Points: points}
+}
+
+func (c Chart) bounds() (minX, maxX, minY, maxY float64, found bool) {
+	minX, minY = math.Inf(1), 0
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			found = true
+			minX = math.Min(minX, p.X)
+			maxX = math.Max(maxX, p.X)
+			minY = math.Min(minY, p.Y)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+
+	if maxX == minX {
+		maxX = minX + 1
+	}
+	if maxY == minY {
+		maxY = minY + 1
+	}
+
+	return minX, maxX, minY, maxY, found
+}
+
+// ticks returns evenly spaced values between min and max rounded to a readable step
+func ticks(min, max float64) []float64 {
+	rawStep := (max - min) / chartTicks
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+
+	step := magnitude
+	for _, factor := range []float64{1, 2, 5, 10} {
+		step = factor * magnitude
+		if step >= rawStep {
+			break
+		}
+	}
+
+	result := make([]float64, 0, chartTicks+1)
+	for v := math.Ceil(min/step) * step; v <= max+step*1e-9; v += step {
+		result = append(result, v)
+	}
+
+	return result
+}
+
+func (c Chart) formatX(v float64) string {
+	if c.TimeAxis {
+		return time.Unix(0, int64(v*float64(time.Second))).UTC().Format("15:04:05")
+	}
+
+	return formatValue(v)
+}
+
+func formatValue(v float64) string {
+	if math.Abs(v) >= 1e6 {
+		return strconv.FormatFloat(v/1e6, 'f', -1, 64) + "M"
+	}
+
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// SVG renders the chart
+func (c Chart) SVG() template.HTML {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="20" font-size="15" font-weight="bold">%s</text>`, chartMarginLeft, html.EscapeString(c.Title))
+
+	minX, maxX, minY, maxY, found := c.bounds()
+	if !found {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="#888">No data</text></svg>`, chartWidth/2-30, chartHeight/2)
+		return template.HTML(b.String())
+	}
+
+	plotWidth := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	plotHeight := float64(chartHeight - chartMarginTop - chartMarginBottom)
+
+	scaleX := func(v float64) float64 {
+		return chartMarginLeft + (v-minX)/(maxX-minX)*plotWidth
+	}
+	scaleY := func(v float64) float64 {
+		return chartMarginTop + plotHeight - (v-minY)/(maxY-minY)*plotHeight
+	}
+
+	for _, tick := range ticks(minY, maxY) {
+		y := scaleY(tick)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e5e5e5"/>`, chartMarginLeft, y, chartMarginLeft+plotWidth, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartMarginLeft-6, y+4, formatValue(tick))
+	}
+	for _, tick := range ticks(minX, maxX) {
+		x := scaleX(tick)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#e5e5e5"/>`, x, chartMarginTop, x, chartMarginTop+plotHeight)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, x, chartMarginTop+plotHeight+16, c.formatX(tick))
+	}
+
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#444"/>`, chartMarginLeft, chartMarginTop, plotWidth, plotHeight)
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, chartMarginLeft+plotWidth/2, chartHeight-8, html.EscapeString(c.XLabel))
+	fmt.Fprintf(&b, `<text x="16" y="%.1f" text-anchor="middle" transform="rotate(-90 16 %.1f)">%s</text>`, chartMarginTop+plotHeight/2, chartMarginTop+plotHeight/2, html.EscapeString(c.YLabel))
+
+	for _, m := range c.Markers {
+		if m.X < minX || m.X > maxX {
+			continue
+		}
+
+		x := scaleX(m.X)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#888" stroke-dasharray="4 3"/>`, x, chartMarginTop, x, chartMarginTop+plotHeight)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="#555" font-size="10" transform="rotate(-90 %.1f %d)" text-anchor="end">%s</text>`, x-3, chartMarginTop+4, x-3, chartMarginTop+4, html.EscapeString(m.Label))
+	}
+
+	for i, s := range c.Series {
+		color := chartColors[i%len(chartColors)]
+
+		if c.Scatter {
+			for _, p := range s.Points {
+				fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s" fill-opacity="0.7"/>`, scaleX(p.X), scaleY(p.Y), color)
+			}
+		} else {
+			coordinates := make([]string, len(s.Points))
+			for j, p := range s.Points {
+				coordinates[j] = fmt.Sprintf("%.1f,%.1f", scaleX(p.X), scaleY(p.Y))
+			}
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`, strings.Join(coordinates, " "), color)
+		}
+
+		legendY := chartMarginTop + 8 + i*18
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="10" height="10" fill="%s"/>`, chartMarginLeft+plotWidth+12, legendY-9, color)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%s</text>`, chartMarginLeft+plotWidth+28, legendY, html.EscapeString(s.Name))
+	}
+
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
@@ -0,0 +1,314 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"text/tabwriter"
+)
+
+// Better states which direction of change of a metric is an improvement
+type Better string
+
+const (
+	BetterLower  Better = "lower"
+	BetterHigher Better = "higher"
+	BetterNone   Better = "none"
+)
+
+// tCritical95 contains the two-sided 95% quantiles of the t-distribution for 1 to 30 degrees of freedom
+var tCritical95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+const zCritical95 = 1.96
+
+const (
+	// bootstrapResamples is the number of resamples from which the confidence interval of the block bootstrap is taken
+	bootstrapResamples = 2000
+	// bootstrapSeed makes the confidence intervals of the block bootstrap reproducible
+	bootstrapSeed = 1
+)
+
+// MetricComparison is the difference of a metric between the candidate and the baseline with its 95% confidence interval
+type MetricComparison struct {
+	Name             string  `json:"name"`
+	Better           Better  `json:"better"`
+	Baseline         float64 `json:"baseline"`
+	Candidate        float64 `json:"candidate"`
+	BaselineCount    int     `json:"baseline_count"`
+	CandidateCount   int     `json:"candidate_count"`
+	Delta            float64 `json:"delta"`
+	DeltaLow         float64 `json:"delta_low"`
+	DeltaHigh        float64 `json:"delta_high"`
+	Significant      bool    `json:"significant"`
+	Regression       bool    `json:"regression"`
+	InsufficientData bool    `json:"insufficient_data"`
+}
+
+// Comparison contains the comparisons of all metrics of two sets of runs
+type Comparison struct {
+	Baseline  string             `json:"baseline"`
+	Candidate string             `json:"candidate"`
+	Metrics   []MetricComparison `json:"metrics"`
+}
+
+// comparisonSamples contains the values of a set of runs from which the metrics are compared. The rates are kept per run
+// as consecutive rate samples of a run are correlated.
+type comparisonSamples struct {
+	intervals []float64
+	sizes     []float64
+	rates     [][]float64
+	latencies []float64
+	submitted int
+	failed    int
+}
+
+func newComparisonSamples(runs []Run) comparisonSamples {
+	var s comparisonSamples
+
+	for _, run := range runs {
+		blocks := run.Blocks()
+		for _, interval := range BlockIntervals(blocks) {
+			s.intervals = append(s.intervals, interval.Seconds())
+		}
+		for _, block := range blocks {
+			s.sizes = append(s.sizes, float64(block.SizeBytes))
+		}
+
+		samples := run.StatsSamples()
+		var rates []float64
+		for _, rate := range TxRates(samples) {
+			rates = append(rates, rate.Rate)
+		}
+		s.rates = append(s.rates, rates)
+		if len(samples) > 0 {
+			s.submitted += int(samples[len(samples)-1].TotalTxs)
+		}
+
+		for _, latency := range ConfirmationLatencies(run) {
+			s.latencies = append(s.latencies, latency.Seconds())
+		}
+
+		// Each failed submission is one tx regardless of its attempts, so that the error rate is the ratio of failed txs
+		for _, submission := range run.Submissions() {
+			if !submission.Success {
+				s.failed++
+			}
+		}
+	}
+
+	return s
+}
+
+// compareMeans compares the means of two samples using the confidence interval of Welch's t-test
+func compareMeans(name string, better Better, baseline []float64, candidate []float64) MetricComparison {
+	b := NewDistribution(baseline)
+	c := NewDistribution(candidate)
+
+	m := MetricComparison{
+		Name:           name,
+		Better:         better,
+		Baseline:       b.Mean,
+		Candidate:      c.Mean,
+		BaselineCount:  b.Count,
+		CandidateCount: c.Count,
+		Delta:          c.Mean - b.Mean,
+	}
+
+	if b.Count < 2 || c.Count < 2 {
+		m.InsufficientData = true
+		return m
+	}
+
+	varianceB := b.StdDev * b.StdDev / float64(b.Count)
+	varianceC := c.StdDev * c.StdDev / float64(c.Count)
+	standardError := math.Sqrt(varianceB + varianceC)
+
+	critical := zCritical95
+	if standardError > 0 {
+		// Welch-Satterthwaite approximation of the degrees of freedom
+		dof := (varianceB + varianceC) * (varianceB + varianceC) /
+			(varianceB*varianceB/float64(b.Count-1) + varianceC*varianceC/float64(c.Count-1))
+		critical = tCritical(dof)
+	}
+
+	m.setInterval(critical * standardError)
+
+	return m
+}
+
+// compareMeansBlockBootstrap compares the means of two sets of time series whose consecutive values are correlated. The
+// confidence interval is taken from a circular block bootstrap which resamples blocks of consecutive values within each
+// series, so that the correlation within the blocks is kept and the interval is not too narrow.
+func compareMeansBlockBootstrap(name string, better Better, baseline [][]float64, candidate [][]float64) MetricComparison {
+	b := NewDistribution(slices.Concat(baseline...))
+	c := NewDistribution(slices.Concat(candidate...))
+
+	m := MetricComparison{
+		Name:           name,
+		Better:         better,
+		Baseline:       b.Mean,
+		Candidate:      c.Mean,
+		BaselineCount:  b.Count,
+		CandidateCount: c.Count,
+		Delta:          c.Mean - b.Mean,
+	}
+
+	if b.Count < 2 || c.Count < 2 {
+		m.InsufficientData = true
+		return m
+	}
+
+	rng := rand.New(rand.NewSource(bootstrapSeed))
+	deltas := make([]float64, bootstrapResamples)
+	for i := range deltas {
+		deltas[i] = blockBootstrapMean(rng, candidate) - blockBootstrapMean(rng, baseline)
+	}
+	sort.Float64s(deltas)
+
+	low := deltas[int(0.025*float64(len(deltas)))]
+	high := deltas[int(0.975*float64(len(deltas)))-1]
+	m.setBounds(low, high)
+
+	return m
+}
+
+// blockBootstrapMean returns the mean of one resample of the series. Each series is resampled by blocks of consecutive
+// values starting at random positions and wrapping around at its end. The block length is the cube root of the length
+// of the series.
+func blockBootstrapMean(rng *rand.Rand, series [][]float64) float64 {
+	var sum float64
+	var count int
+
+	for _, values := range series {
+		n := len(values)
+		if n == 0 {
+			continue
+		}
+
+		blockLength := int(math.Ceil(math.Cbrt(float64(n))))
+		for drawn := 0; drawn < n; {
+			start := rng.Intn(n)
+			for j := 0; j < blockLength && drawn < n; j++ {
+				sum += values[(start+j)%n]
+				drawn++
+			}
+		}
+		count += n
+	}
+
+	return sum / float64(count)
+}
+
+// compareProportions compares two proportions using the normal approximation of their difference
+func compareProportions(name string, better Better, baselineHits, baselineTotal, candidateHits, candidateTotal int) MetricComparison {
+	m := MetricComparison{
+		Name:           name,
+		Better:         better,
+		BaselineCount:  baselineTotal,
+		CandidateCount: candidateTotal,
+	}
+
+	if baselineTotal == 0 || candidateTotal == 0 {
+		m.InsufficientData = true
+		return m
+	}
+
+	m.Baseline = float64(baselineHits) / float64(baselineTotal)
+	m.Candidate = float64(candidateHits) / float64(candidateTotal)
+	m.Delta = m.Candidate - m.Baseline
+
+	standardError := math.Sqrt(m.Baseline*(1-m.Baseline)/float64(baselineTotal) + m.Candidate*(1-m.Candidate)/float64(candidateTotal))
+	m.setInterval(zCritical95 * standardError)
+
+	return m
+}
+
+// setInterval sets the confidence interval around the delta and flags the change if the interval does not contain zero
+func (m *MetricComparison) setInterval(margin float64) {
+	m.setBounds(m.Delta-margin, m.Delta+margin)
+}
+
+// setBounds sets the confidence interval of the delta and flags the change if the interval does not contain zero
+func (m *MetricComparison) setBounds(low float64, high float64) {
+	m.DeltaLow = low
+	m.DeltaHigh = high
+	m.Significant = m.DeltaLow > 0 || m.DeltaHigh < 0
+
+	switch m.Better {
+	case BetterLower:
+		m.Regression = m.Significant && m.Delta > 0
+	case BetterHigher:
+		m.Regression = m.Significant && m.Delta < 0
+	}
+}
+
+func tCritical(dof float64) float64 {
+	index := int(math.Floor(dof))
+	if index < 1 {
+		index = 1
+	}
+	if index > len(tCritical95) {
+		return zCritical95
+	}
+
+	return tCritical95[index-1]
+}
+
+// Compare compares the block interval, block size, throughput, confirmation latency and error rate of the candidate
+// runs to the baseline runs
+func Compare(baselineName string, baseline []Run, candidateName string, candidate []Run) Comparison {
+	b := newComparisonSamples(baseline)
+	c := newComparisonSamples(candidate)
+
+	return Comparison{
+		Baseline:  baselineName,
+		Candidate: candidateName,
+		Metrics: []MetricComparison{
+			compareMeans("Block interval [s]", BetterNone, b.intervals, c.intervals),
+			compareMeans("Block size [bytes]", BetterNone, b.sizes, c.sizes),
+			compareMeansBlockBootstrap("Throughput [txs/s]", BetterHigher, b.rates, c.rates),
+			compareMeans("Confirmation latency [s]", BetterLower, b.latencies, c.latencies),
+			compareProportions("Error rate", BetterLower, b.failed, b.submitted+b.failed, c.failed, c.submitted+c.failed),
+		},
+	}
+}
+
+// WriteComparisonText writes the comparison in a human-readable format
+func WriteComparisonText(w io.Writer, c Comparison) error {
+	fmt.Fprintf(w, "Baseline:  %s\nCandidate: %s\n\n", c.Baseline, c.Candidate)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Metric\tbaseline\tcandidate\tdelta\tdelta [%%]\t95%% CI\t\n")
+	for _, m := range c.Metrics {
+		relative := "-"
+		if m.Baseline != 0 {
+			relative = fmt.Sprintf("%+.1f", 100*m.Delta/m.Baseline)
+		}
+
+		interval := "insufficient data"
+		if !m.InsufficientData {
+			interval = fmt.Sprintf("[%+.4g, %+.4g]", m.DeltaLow, m.DeltaHigh)
+		}
+
+		flag := ""
+		switch {
+		case m.Regression:
+			flag = "REGRESSION"
+		case m.Significant && m.Better == BetterNone:
+			flag = "significant"
+		case m.Significant:
+			flag = "improvement"
+		}
+
+		fmt.Fprintf(tw, "%s\t%.4g\t%.4g\t%+.4g\t%s\t%s\t%s\n", m.Name, m.Baseline, m.Candidate, m.Delta, relative, interval, flag)
+	}
+
+	return tw.Flush()
+}
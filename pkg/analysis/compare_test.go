@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareMeans(t *testing.T) {
+	tt := []struct {
+		name      string
+		better    Better
+		baseline  []float64
+		candidate []float64
+
+		expectedSignificant      bool
+		expectedRegression       bool
+		expectedInsufficientData bool
+	}{
+		{
+			name:      "no significant change",
+			better:    BetterLower,
+			baseline:  []float64{1, 2, 3, 4, 5},
+			candidate: []float64{1.5, 2.5, 3.5, 4.5, 5.5},
+		},
+		{
+			name:      "regression",
+			better:    BetterLower,
+			baseline:  []float64{1, 1.1, 0.9, 1, 1.05},
+			candidate: []float64{2, 2.1, 1.9, 2, 2.05},
+
+			expectedSignificant: true,
+			expectedRegression:  true,
+		},
+		{
+			name:      "improvement",
+			better:    BetterHigher,
+			baseline:  []float64{1, 1.1, 0.9, 1, 1.05},
+			candidate: []float64{2, 2.1, 1.9, 2, 2.05},
+
+			expectedSignificant: true,
+		},
+		{
+			name:      "insufficient data",
+			better:    BetterHigher,
+			baseline:  []float64{1},
+			candidate: []float64{2, 2.1},
+
+			expectedInsufficientData: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := compareMeans(tc.name, tc.better, tc.baseline, tc.candidate)
+
+			require.Equal(t, tc.expectedSignificant, m.Significant)
+			require.Equal(t, tc.expectedRegression, m.Regression)
+			require.Equal(t, tc.expectedInsufficientData, m.InsufficientData)
+			if !tc.expectedInsufficientData {
+				require.Less(t, m.DeltaLow, m.Delta)
+				require.Greater(t, m.DeltaHigh, m.Delta)
+			}
+		})
+	}
+}
+
+func TestCompareProportions(t *testing.T) {
+	m := compareProportions("Error rate", BetterLower, 10, 1000, 50, 1000)
+
+	require.InDelta(t, 0.04, m.Delta, 0.0001)
+	require.True(t, m.Significant)
+	require.True(t, m.Regression)
+}
+
+func TestCompareMeansBlockBootstrap(t *testing.T) {
+	// Rates which drift slowly, so that consecutive samples are strongly correlated
+	var baseline, candidate []float64
+	for i := range 60 {
+		drift := float64(i/10) * 2
+		baseline = append(baseline, 100+drift)
+		candidate = append(candidate, 101+drift)
+	}
+
+	welch := compareMeans("Throughput", BetterHigher, baseline, candidate)
+	m := compareMeansBlockBootstrap("Throughput", BetterHigher, [][]float64{baseline}, [][]float64{candidate})
+
+	require.InDelta(t, 1, m.Delta, 0.0001)
+	require.LessOrEqual(t, m.DeltaLow, m.Delta)
+	require.GreaterOrEqual(t, m.DeltaHigh, m.Delta)
+	require.Greater(t, m.DeltaHigh-m.DeltaLow, welch.DeltaHigh-welch.DeltaLow)
+
+	// The same seed gives the same interval
+	require.Equal(t, m, compareMeansBlockBootstrap("Throughput", BetterHigher, [][]float64{baseline}, [][]float64{candidate}))
+
+	m = compareMeansBlockBootstrap("Throughput", BetterHigher, [][]float64{{1}}, [][]float64{{2, 3}})
+	require.True(t, m.InsufficientData)
+}
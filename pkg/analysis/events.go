@@ -0,0 +1,273 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	MsgRunStarted           = "Run started"
+	MsgStartingBroadcasting = "Starting broadcasting"
+	MsgStoppingBroadcasting = "Stopping broadcasting"
+	MsgStats                = "Stats"
+	MsgBlock                = "Block"
+	MsgTxSubmitted          = "Tx submitted"
+	MsgSubmittingTxFailed   = "Submitting tx failed"
+	MsgScenarioEvent        = "Scenario event"
+	MsgClockOffset          = "Clock offset"
+	MsgBlockAnnouncement    = "Block announcement"
+
+	ServiceBroadcaster = "broadcaster"
+	ServiceListener    = "listener"
+	ServiceMiner       = "miner"
+	ServiceScenario    = "scenario"
+	ServiceP2P         = "p2p"
+
+	LevelError = "ERROR"
+
+	// maxLineBytes is the maximum size of a single log line
+	maxLineBytes = 10 * 1024 * 1024
+)
+
+// Event is a single JSON log record written to the output file
+type Event struct {
+	Time    time.Time
+	Level   string
+	Msg     string
+	Service string
+	Attrs   map[string]any
+}
+
+// String returns the attribute with the given key as string
+func (e Event) String(key string) string {
+	value, found := e.Attrs[key]
+	if !found {
+		return ""
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Float returns the attribute with the given key as number. Numbers which are logged as strings are parsed.
+func (e Event) Float(key string) (float64, bool) {
+	value, found := e.Attrs[key]
+	if !found {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return f, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// Duration returns the attribute with the given key as duration
+func (e Event) Duration(key string) (time.Duration, bool) {
+	value, found := e.Attrs[key]
+	if !found {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, false
+		}
+		return d, true
+	case json.Number:
+		// slog encodes time.Duration values as nanoseconds
+		nanoseconds, err := v.Int64()
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(nanoseconds), true
+	default:
+		return 0, false
+	}
+}
+
+// Timestamp returns the attribute with the given key as time
+func (e Event) Timestamp(key string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, e.String(key))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// ParseEvent parses a single JSON log line
+func ParseEvent(line []byte) (Event, error) {
+	attrs := map[string]any{}
+
+	// Numbers are decoded as json.Number so that large integers like seeds keep their precision
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	err := decoder.Decode(&attrs)
+	if err != nil {
+		return Event{}, err
+	}
+
+	e := Event{Attrs: attrs}
+
+	if value, ok := attrs["time"].(string); ok {
+		e.Time, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return Event{}, fmt.Errorf("invalid time %s: %v", value, err)
+		}
+	}
+
+	e.Level, _ = attrs["level"].(string)
+	e.Msg, _ = attrs["msg"].(string)
+	e.Service, _ = attrs["service"].(string)
+
+	delete(attrs, "time")
+	delete(attrs, "level")
+	delete(attrs, "msg")
+	delete(attrs, "service")
+
+	return e, nil
+}
+
+// ReadEvents reads all events from the JSON lines. Lines which are not valid JSON are skipped.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	events := make([]Event, 0)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		event, err := ParseEvent(line)
+		if err != nil {
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Run contains the events of one run of the broadcaster
+type Run struct {
+	Source string
+	Node   string
+	Index  int
+	Events []Event
+}
+
+// Name identifies the run by its source file, the node if the file contains the events of several nodes and its
+// index within the file
+func (r Run) Name() string {
+	if r.Node != "" {
+		return fmt.Sprintf("%s:%s#%d", r.Source, r.Node, r.Index)
+	}
+
+	return fmt.Sprintf("%s#%d", r.Source, r.Index)
+}
+
+// Config returns the attributes logged at the start of the run
+func (r Run) Config() map[string]any {
+	for _, e := range r.Events {
+		if e.Msg == MsgRunStarted {
+			return e.Attrs
+		}
+	}
+
+	return map[string]any{}
+}
+
+// SplitRuns splits the events into runs. As output files are appended to, one file can contain several runs which
+// are separated by the event logged at the start of each run. If the file contains the events of several nodes of an
+// orchestrated run, the events are first split by their node attribute.
+func SplitRuns(source string, events []Event) []Run {
+	nodes := make([]string, 0)
+	eventsByNode := map[string][]Event{}
+	for _, e := range events {
+		node := e.String("node")
+		if _, found := eventsByNode[node]; !found {
+			nodes = append(nodes, node)
+		}
+		eventsByNode[node] = append(eventsByNode[node], e)
+	}
+
+	if len(nodes) == 1 {
+		return splitNodeRuns(source, "", events)
+	}
+
+	runs := make([]Run, 0)
+	for _, node := range nodes {
+		runs = append(runs, splitNodeRuns(source, node, eventsByNode[node])...)
+	}
+
+	return runs
+}
+
+func splitNodeRuns(source string, node string, events []Event) []Run {
+	runs := make([]Run, 0)
+
+	current := Run{Source: source, Node: node, Events: make([]Event, 0)}
+	for _, e := range events {
+		if e.Msg == MsgRunStarted && len(current.Events) > 0 {
+			runs = append(runs, current)
+			current = Run{Source: source, Node: node, Index: len(runs), Events: make([]Event, 0)}
+		}
+
+		current.Events = append(current.Events, e)
+	}
+
+	if len(current.Events) > 0 {
+		runs = append(runs, current)
+	}
+
+	return runs
+}
+
+// ReadRuns reads all runs of the given output file
+func ReadRuns(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events, err := ReadEvents(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events of %s: %v", path, err)
+	}
+
+	return SplitRuns(path, events), nil
+}
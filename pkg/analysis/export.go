@@ -0,0 +1,193 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	ExportCSV     = "csv"
+	ExportParquet = "parquet"
+)
+
+// BlockRow is a block reported by a listener
+type BlockRow struct {
+	Run       string    `parquet:"run"`
+	Node      string    `parquet:"node"`
+	Hash      string    `parquet:"hash"`
+	Height    int64     `parquet:"height"`
+	Timestamp time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	DeltaMs   int64     `parquet:"delta_ms"`
+	Size      uint64    `parquet:"size"`
+	Txs       uint64    `parquet:"txs"`
+}
+
+// SubmissionRow is a tx submitted by a broadcaster
+type SubmissionRow struct {
+	Run        string    `parquet:"run"`
+	Node       string    `parquet:"node"`
+	Timestamp  time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Hash       string    `parquet:"hash"`
+	Utxo       string    `parquet:"utxo"`
+	DurationMs float64   `parquet:"duration_ms"`
+	Attempts   int64     `parquet:"attempts"`
+	Success    bool      `parquet:"success"`
+	Error      string    `parquet:"error"`
+}
+
+// StatsRow is a stats sample of a broadcaster
+type StatsRow struct {
+	Run        string    `parquet:"run"`
+	Node       string    `parquet:"node"`
+	Timestamp  time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	TotalTxs   int64     `parquet:"total_txs"`
+	Utxos      int64     `parquet:"utxos"`
+	MempoolTxs uint64    `parquet:"mempool_txs"`
+}
+
+// Tables contains the typed rows of all runs
+type Tables struct {
+	Blocks      []BlockRow
+	Submissions []SubmissionRow
+	Stats       []StatsRow
+}
+
+// NewTables collects the blocks, submissions and stats samples of all runs
+func NewTables(runs []Run) Tables {
+	t := Tables{
+		Blocks:      make([]BlockRow, 0),
+		Submissions: make([]SubmissionRow, 0),
+		Stats:       make([]StatsRow, 0),
+	}
+
+	for _, run := range runs {
+		for _, block := range run.Blocks() {
+			t.Blocks = append(t.Blocks, BlockRow{
+				Run:       run.Name(),
+				Node:      block.Node,
+				Hash:      block.Hash,
+				Height:    block.Height,
+				Timestamp: block.Timestamp,
+				DeltaMs:   block.Delta.Milliseconds(),
+				Size:      block.SizeBytes,
+				Txs:       block.Txs,
+			})
+		}
+
+		for _, submission := range run.Submissions() {
+			t.Submissions = append(t.Submissions, SubmissionRow{
+				Run:        run.Name(),
+				Node:       run.Source,
+				Timestamp:  submission.Timestamp,
+				Hash:       submission.Hash,
+				Utxo:       submission.Utxo,
+				DurationMs: float64(submission.Duration) / float64(time.Millisecond),
+				Attempts:   int64(submission.Attempts),
+				Success:    submission.Success,
+				Error:      submission.Err,
+			})
+		}
+
+		for _, sample := range run.StatsSamples() {
+			t.Stats = append(t.Stats, StatsRow{
+				Run:        run.Name(),
+				Node:       run.Source,
+				Timestamp:  sample.Time,
+				TotalTxs:   sample.TotalTxs,
+				Utxos:      sample.Utxos,
+				MempoolTxs: sample.MempoolTxs,
+			})
+		}
+	}
+
+	return t
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// WriteCSV writes the tables as blocks.csv, submissions.csv and stats.csv to the directory
+func (t Tables) WriteCSV(dir string) error {
+	err := writeCSV(filepath.Join(dir, "blocks.csv"),
+		[]string{"run", "node", "hash", "height", "timestamp", "delta_ms", "size", "txs"},
+		t.Blocks,
+		func(r BlockRow) []string {
+			return []string{r.Run, r.Node, r.Hash, strconv.FormatInt(r.Height, 10), formatTimestamp(r.Timestamp), strconv.FormatInt(r.DeltaMs, 10), strconv.FormatUint(r.Size, 10), strconv.FormatUint(r.Txs, 10)}
+		})
+	if err != nil {
+		return err
+	}
+
+	err = writeCSV(filepath.Join(dir, "submissions.csv"),
+		[]string{"run", "node", "timestamp", "hash", "utxo", "duration_ms", "attempts", "success", "error"},
+		t.Submissions,
+		func(r SubmissionRow) []string {
+			return []string{r.Run, r.Node, formatTimestamp(r.Timestamp), r.Hash, r.Utxo, strconv.FormatFloat(r.DurationMs, 'f', 3, 64), strconv.FormatInt(r.Attempts, 10), strconv.FormatBool(r.Success), r.Error}
+		})
+	if err != nil {
+		return err
+	}
+
+	return writeCSV(filepath.Join(dir, "stats.csv"),
+		[]string{"run", "node", "timestamp", "total_txs", "utxos", "mempool_txs"},
+		t.Stats,
+		func(r StatsRow) []string {
+			return []string{r.Run, r.Node, formatTimestamp(r.Timestamp), strconv.FormatInt(r.TotalTxs, 10), strconv.FormatInt(r.Utxos, 10), strconv.FormatUint(r.MempoolTxs, 10)}
+		})
+}
+
+func writeCSV[T any](path string, header []string, rows []T, record func(T) []string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+
+	w := csv.NewWriter(f)
+
+	err = w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		err = w.Write(record(row))
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+// WriteParquet writes the tables as blocks.parquet, submissions.parquet and stats.parquet to the directory
+func (t Tables) WriteParquet(dir string) error {
+	err := parquet.WriteFile(filepath.Join(dir, "blocks.parquet"), t.Blocks)
+	if err != nil {
+		return fmt.Errorf("failed to write blocks: %v", err)
+	}
+
+	err = parquet.WriteFile(filepath.Join(dir, "submissions.parquet"), t.Submissions)
+	if err != nil {
+		return fmt.Errorf("failed to write submissions: %v", err)
+	}
+
+	err = parquet.WriteFile(filepath.Join(dir, "stats.parquet"), t.Stats)
+	if err != nil {
+		return fmt.Errorf("failed to write stats: %v", err)
+	}
+
+	return nil
+}
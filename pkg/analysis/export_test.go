@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTables_Write(t *testing.T) {
+	events, err := ReadEvents(strings.NewReader(testOutput))
+	require.NoError(t, err)
+
+	tables := NewTables(SplitRuns("output.log", events))
+	require.Len(t, tables.Blocks, 2)
+	require.Len(t, tables.Submissions, 1)
+	require.Len(t, tables.Stats, 2)
+	require.Equal(t, int64(15000), tables.Blocks[1].DeltaMs)
+
+	dir := t.TempDir()
+
+	err = tables.WriteCSV(dir)
+	require.NoError(t, err)
+
+	blocksCSV, err := os.ReadFile(filepath.Join(dir, "blocks.csv"))
+	require.NoError(t, err)
+	require.Equal(t, "run,node,hash,height,timestamp,delta_ms,size,txs\n"+
+		"output.log#0,output.log,aa,0,2024-12-11T13:30:10Z,10000,12000,60\n"+
+		"output.log#0,output.log,bb,0,2024-12-11T13:30:25Z,15000,30000,150\n", string(blocksCSV))
+
+	err = tables.WriteParquet(dir)
+	require.NoError(t, err)
+
+	blocks, err := parquet.ReadFile[BlockRow](filepath.Join(dir, "blocks.parquet"))
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	require.Equal(t, "bb", blocks[1].Hash)
+	require.True(t, tables.Blocks[1].Timestamp.Equal(blocks[1].Timestamp))
+}
+
+func TestRun_Submissions(t *testing.T) {
+	output := `{"time":"2024-12-11T13:29:00Z","level":"INFO","msg":"Run started","seed":1,"rate":10}
+{"time":"2024-12-11T13:30:00Z","level":"WARN","msg":"Submission attempt failed","service":"broadcaster","utxo":"aa:0","attempt":1,"err":"connection reset"}
+{"time":"2024-12-11T13:30:00Z","level":"INFO","msg":"Tx submitted","service":"broadcaster","hash":"bb","timestamp":"2024-12-11T13:30:00Z","duration":"60ms","attempts":2}
+{"time":"2024-12-11T13:30:01Z","level":"WARN","msg":"Submission attempt failed","service":"broadcaster","utxo":"cc:0","attempt":1,"err":"connection reset"}
+{"time":"2024-12-11T13:30:01Z","level":"WARN","msg":"Submission attempt failed","service":"broadcaster","utxo":"cc:0","attempt":2,"err":"connection reset"}
+{"time":"2024-12-11T13:30:01Z","level":"ERROR","msg":"Submitting tx failed","service":"broadcaster","utxo":"cc:0","hash":"dd","timestamp":"2024-12-11T13:30:01Z","duration":"120ms","attempts":3,"err":"connection refused"}
+{"time":"2024-12-11T13:30:02Z","level":"INFO","msg":"Tx submitted","service":"broadcaster","hash":"ee","timestamp":"2024-12-11T13:30:02Z","duration":"1ms","attempts":1}
+{"time":"2024-12-11T13:31:02Z","level":"ERROR","msg":"Submitting tx failed","service":"broadcaster","hash":"ee","timestamp":"2024-12-11T13:31:02Z","late":true,"err":"tx not requested by peer within 1m0s"}
+`
+	events, err := ReadEvents(strings.NewReader(output))
+	require.NoError(t, err)
+
+	submissions := SplitRuns("output.log", events)[0].Submissions()
+	require.Len(t, submissions, 3)
+
+	require.True(t, submissions[0].Success)
+	require.Equal(t, "bb", submissions[0].Hash)
+	require.Equal(t, 2, submissions[0].Attempts)
+
+	require.False(t, submissions[1].Success)
+	require.Equal(t, "dd", submissions[1].Hash)
+	require.Equal(t, "cc:0", submissions[1].Utxo)
+	require.Equal(t, 3, submissions[1].Attempts)
+	require.Equal(t, "connection refused", submissions[1].Err)
+
+	// The late failure of a tx submitted as P2P peer replaces its successful submission
+	require.False(t, submissions[2].Success)
+	require.Equal(t, "ee", submissions[2].Hash)
+	require.Equal(t, time.Date(2024, 12, 11, 13, 30, 2, 0, time.UTC), submissions[2].Timestamp)
+	require.Equal(t, "tx not requested by peer within 1m0s", submissions[2].Err)
+}
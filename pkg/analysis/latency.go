@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+)
+
+// ConfirmationLatencies returns for each successful submission the time until the next block was reported by the same
+// instance. As blocks on regtest include the whole mempool this approximates the time until the tx was confirmed.
+// Submissions after the last block are not included.
+func ConfirmationLatencies(r Run) []time.Duration {
+	blocks := r.Blocks()
+	latencies := make([]time.Duration, 0)
+
+	for _, submission := range r.Submissions() {
+		if !submission.Success {
+			continue
+		}
+
+		index := sort.Search(len(blocks), func(i int) bool {
+			return !blocks[i].Timestamp.Before(submission.Timestamp)
+		})
+		if index == len(blocks) {
+			continue
+		}
+
+		latencies = append(latencies, blocks[index].Timestamp.Sub(submission.Timestamp))
+	}
+
+	return latencies
+}
+
+// RateSample is the achieved tx rate between two stats samples
+type RateSample struct {
+	Time time.Time `json:"time"`
+	Rate float64   `json:"rate"`
+}
+
+// TxRates returns the achieved tx rate between consecutive stats samples
+func TxRates(samples []StatsSample) []RateSample {
+	if len(samples) < 2 {
+		return nil
+	}
+
+	rates := make([]RateSample, 0, len(samples)-1)
+	for i := 1; i < len(samples); i++ {
+		elapsed := samples[i].Time.Sub(samples[i-1].Time).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+
+		rates = append(rates, RateSample{
+			Time: samples[i].Time,
+			Rate: float64(samples[i].TotalTxs-samples[i-1].TotalTxs) / elapsed,
+		})
+	}
+
+	return rates
+}
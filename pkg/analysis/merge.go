@@ -0,0 +1,400 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const MsgBlockGenerated = "Block generated"
+
+// Observation is the point in time at which a node reported a block
+type Observation struct {
+	Node      string        `json:"node"`
+	Timestamp time.Time     `json:"timestamp"`
+	Offset    time.Duration `json:"offset"`
+	Via       string        `json:"via,omitempty"`
+}
+
+// MergedBlock is a block as seen by the whole network
+type MergedBlock struct {
+	Hash         string        `json:"hash"`
+	FirstSeen    time.Time     `json:"first_seen"`
+	FirstNode    string        `json:"first_node"`
+	MinedBy      string        `json:"mined_by,omitempty"`
+	Interval     time.Duration `json:"interval"`
+	SizeBytes    uint64        `json:"size_bytes"`
+	Txs          uint64        `json:"txs"`
+	Observations []Observation `json:"observations"`
+}
+
+// NodeClock is the estimated offset of the clock of a node compared to the other nodes
+type NodeClock struct {
+	Node       string        `json:"node"`
+	Blocks     int           `json:"blocks"`
+	FirstSeen  int           `json:"first_seen"`
+	MeanOffset time.Duration `json:"mean_offset"`
+	Skew       time.Duration `json:"skew"`
+	Measured   bool          `json:"measured"`
+	Skewed     bool          `json:"skewed"`
+}
+
+// AnnouncedBlock is a block as announced by the nodes observed as P2P peer by one run. As all announcements are timed by
+// the clock of the observing run, the offsets don't depend on the clocks of the nodes.
+type AnnouncedBlock struct {
+	Hash          string        `json:"hash"`
+	Observer      string        `json:"observer"`
+	FirstSeen     time.Time     `json:"first_seen"`
+	FirstPeer     string        `json:"first_peer"`
+	Announcements []Observation `json:"announcements"`
+}
+
+// PeerPropagation is how often an observed node announced a block first and how long after the first node it announced
+// blocks on average
+type PeerPropagation struct {
+	Peer       string        `json:"peer"`
+	Blocks     int           `json:"blocks"`
+	FirstSeen  int           `json:"first_seen"`
+	MeanOffset time.Duration `json:"mean_offset"`
+}
+
+// Merged is the network-wide timeline of blocks merged from the output of several instances
+type Merged struct {
+	Nodes                []NodeClock   `json:"nodes"`
+	Blocks               []MergedBlock `json:"blocks"`
+	BlockIntervalSeconds Distribution  `json:"block_interval_seconds"`
+	PropagationSeconds   Distribution  `json:"propagation_seconds"`
+	SkewCorrected        bool          `json:"skew_corrected"`
+
+	Announced           []AnnouncedBlock  `json:"announced,omitempty"`
+	Peers               []PeerPropagation `json:"peers,omitempty"`
+	AnnouncementSeconds Distribution      `json:"announcement_seconds"`
+}
+
+// estimateSkews estimates the clock skew of each node as the median difference between the time it reported a block
+// and the median time at which all nodes reported this block
+func estimateSkews(observationsByHash map[string][]Observation) map[string][]time.Duration {
+	differences := map[string][]time.Duration{}
+
+	for _, observations := range observationsByHash {
+		if len(observations) < 2 {
+			continue
+		}
+
+		// Timestamps are taken relative to the first observation to avoid losing precision
+		first := observations[0].Timestamp
+		relative := make([]time.Duration, len(observations))
+		for i, observation := range observations {
+			relative[i] = observation.Timestamp.Sub(first)
+		}
+		reference := medianDuration(relative)
+
+		for i, observation := range observations {
+			differences[observation.Node] = append(differences[observation.Node], relative[i]-reference)
+		}
+	}
+
+	return differences
+}
+
+func medianDuration(durations []time.Duration) time.Duration {
+	values := make([]float64, len(durations))
+	for i, d := range durations {
+		values[i] = float64(d)
+	}
+	sort.Float64s(values)
+
+	return time.Duration(Percentile(values, 0.5))
+}
+
+// Merge deduplicates the blocks reported by the listeners of all runs by their hash. Nodes whose clock deviates more
+// than the skew threshold from the other nodes are flagged. If correctSkew is true, the timestamps of each node are
+// corrected by its skew before the timeline is created. The skew of a node is taken from the clock offset measured at
+// the start of its run if available and estimated from the reported blocks otherwise.
+func Merge(runs []Run, skewThreshold time.Duration, correctSkew bool) Merged {
+	observationsByHash := map[string][]Observation{}
+	blocksByHash := map[string]Block{}
+	minedBy := map[string]string{}
+	nodes := map[string]struct{}{}
+	measured := map[string]time.Duration{}
+
+	for _, run := range runs {
+		offset, found := run.ClockOffset()
+		if found {
+			measured[run.NodeName()] = offset
+		}
+
+		for _, block := range run.Blocks() {
+			nodes[block.Node] = struct{}{}
+			observationsByHash[block.Hash] = append(observationsByHash[block.Hash], Observation{Node: block.Node, Timestamp: block.Timestamp})
+			if _, found := blocksByHash[block.Hash]; !found {
+				blocksByHash[block.Hash] = block
+			}
+		}
+
+		for _, e := range run.Events {
+			if e.Msg == MsgBlockGenerated {
+				node := e.String("node")
+				if node == "" {
+					node = run.Source
+				}
+				minedBy[e.String("hash")] = node
+			}
+		}
+	}
+
+	// The skews of nodes without a measured offset are estimated after the measured offsets have been applied
+	measuredObservations := make(map[string][]Observation, len(observationsByHash))
+	for hash, observations := range observationsByHash {
+		for _, observation := range observations {
+			observation.Timestamp = observation.Timestamp.Add(measured[observation.Node])
+			measuredObservations[hash] = append(measuredObservations[hash], observation)
+		}
+	}
+	differences := estimateSkews(measuredObservations)
+
+	m := Merged{SkewCorrected: correctSkew}
+	skews := map[string]time.Duration{}
+	for node := range nodes {
+		skews[node] = medianDuration(differences[node])
+
+		offset, found := measured[node]
+		if found {
+			// A clock which is behind the network by the offset reports blocks earlier by the offset
+			skews[node] = -offset
+		}
+	}
+
+	propagation := make([]float64, 0)
+	for hash, observations := range observationsByHash {
+		block := blocksByHash[hash]
+
+		if correctSkew {
+			for i := range observations {
+				observations[i].Timestamp = observations[i].Timestamp.Add(-skews[observations[i].Node])
+			}
+		}
+
+		sort.Slice(observations, func(i, j int) bool {
+			return observations[i].Timestamp.Before(observations[j].Timestamp)
+		})
+
+		firstSeen := observations[0].Timestamp
+		for i := range observations {
+			observations[i].Offset = observations[i].Timestamp.Sub(firstSeen)
+			if i > 0 {
+				propagation = append(propagation, observations[i].Offset.Seconds())
+			}
+		}
+
+		m.Blocks = append(m.Blocks, MergedBlock{
+			Hash:         hash,
+			FirstSeen:    firstSeen,
+			FirstNode:    observations[0].Node,
+			MinedBy:      minedBy[hash],
+			SizeBytes:    block.SizeBytes,
+			Txs:          block.Txs,
+			Observations: observations,
+		})
+	}
+
+	sort.Slice(m.Blocks, func(i, j int) bool {
+		return m.Blocks[i].FirstSeen.Before(m.Blocks[j].FirstSeen)
+	})
+
+	intervals := make([]float64, 0, len(m.Blocks))
+	for i := 1; i < len(m.Blocks); i++ {
+		m.Blocks[i].Interval = m.Blocks[i].FirstSeen.Sub(m.Blocks[i-1].FirstSeen)
+		intervals = append(intervals, m.Blocks[i].Interval.Seconds())
+	}
+
+	m.Nodes = nodeClocks(m.Blocks, skews, measured, skewThreshold)
+	m.BlockIntervalSeconds = NewDistribution(intervals)
+	m.PropagationSeconds = NewDistribution(propagation)
+
+	var announcementOffsets []float64
+	m.Announced, m.Peers, announcementOffsets = mergeAnnouncements(runs)
+	m.AnnouncementSeconds = NewDistribution(announcementOffsets)
+
+	return m
+}
+
+// mergeAnnouncements groups the block announcements logged by the observer of each run by block. It returns the
+// announced blocks, the propagation by peer and the offsets of all announcements after the first announcement of the
+// block in seconds.
+func mergeAnnouncements(runs []Run) ([]AnnouncedBlock, []PeerPropagation, []float64) {
+	type key struct {
+		observer string
+		hash     string
+	}
+
+	byBlock := map[key]*AnnouncedBlock{}
+	blocks := make([]*AnnouncedBlock, 0)
+
+	for _, run := range runs {
+		// The announcements are ordered by time, so the first announcement of a block is the first one found
+		for _, announcement := range run.Announcements() {
+			k := key{observer: announcement.Observer, hash: announcement.Hash}
+			block, found := byBlock[k]
+			if !found {
+				block = &AnnouncedBlock{
+					Hash:      announcement.Hash,
+					Observer:  announcement.Observer,
+					FirstSeen: announcement.Timestamp,
+					FirstPeer: announcement.Peer,
+				}
+				byBlock[k] = block
+				blocks = append(blocks, block)
+			}
+
+			block.Announcements = append(block.Announcements, Observation{
+				Node:      announcement.Peer,
+				Timestamp: announcement.Timestamp,
+				Offset:    announcement.Timestamp.Sub(block.FirstSeen),
+				Via:       announcement.Via,
+			})
+		}
+	}
+
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].FirstSeen.Before(blocks[j].FirstSeen)
+	})
+
+	peers := map[string]*PeerPropagation{}
+	offsetSums := map[string]time.Duration{}
+	offsets := make([]float64, 0)
+	announced := make([]AnnouncedBlock, len(blocks))
+
+	for i, block := range blocks {
+		for j, announcement := range block.Announcements {
+			peer, found := peers[announcement.Node]
+			if !found {
+				peer = &PeerPropagation{Peer: announcement.Node}
+				peers[announcement.Node] = peer
+			}
+
+			peer.Blocks++
+			if j == 0 {
+				peer.FirstSeen++
+			} else {
+				offsets = append(offsets, announcement.Offset.Seconds())
+			}
+			offsetSums[announcement.Node] += announcement.Offset
+		}
+
+		announced[i] = *block
+	}
+
+	result := make([]PeerPropagation, 0, len(peers))
+	for node, peer := range peers {
+		peer.MeanOffset = offsetSums[node] / time.Duration(peer.Blocks)
+		result = append(result, *peer)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Peer < result[j].Peer
+	})
+
+	return announced, result, offsets
+}
+
+// nodeClocks calculates for each node how often it saw a block first and how long after the first node it saw blocks on average
+func nodeClocks(blocks []MergedBlock, skews map[string]time.Duration, measured map[string]time.Duration, skewThreshold time.Duration) []NodeClock {
+	clocks := make(map[string]*NodeClock, len(skews))
+	for node, skew := range skews {
+		_, isMeasured := measured[node]
+		clocks[node] = &NodeClock{
+			Node:     node,
+			Skew:     skew,
+			Measured: isMeasured,
+			Skewed:   skew > skewThreshold || skew < -skewThreshold,
+		}
+	}
+
+	offsetSums := map[string]time.Duration{}
+	for _, block := range blocks {
+		for i, observation := range block.Observations {
+			clock := clocks[observation.Node]
+			clock.Blocks++
+			if i == 0 {
+				clock.FirstSeen++
+			}
+			offsetSums[observation.Node] += observation.Offset
+		}
+	}
+
+	result := make([]NodeClock, 0, len(clocks))
+	for node, clock := range clocks {
+		if clock.Blocks > 0 {
+			clock.MeanOffset = offsetSums[node] / time.Duration(clock.Blocks)
+		}
+		result = append(result, *clock)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Node < result[j].Node
+	})
+
+	return result
+}
+
+// WriteMergedText writes the merged timeline in a human-readable format
+func WriteMergedText(w io.Writer, m Merged) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Node\tblocks\tfirst seen\tmean offset\tclock skew\t\n")
+	for _, node := range m.Nodes {
+		flag := ""
+		if node.Measured {
+			flag = "measured"
+		}
+		if node.Skewed {
+			flag = strings.TrimSpace(flag + " SKEWED")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n", node.Node, node.Blocks, node.FirstSeen, node.MeanOffset, node.Skew, flag)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintf(tw, "\tcount\tmin\tmean\tstd dev\tp50\tp90\tp99\tmax\t\n")
+	writeDistribution(tw, "Block interval [s]", m.BlockIntervalSeconds)
+	writeDistribution(tw, "Propagation [s]", m.PropagationSeconds)
+	fmt.Fprintln(tw)
+
+	if m.SkewCorrected {
+		fmt.Fprintln(tw, "Timestamps corrected by clock skew")
+	}
+	fmt.Fprintf(tw, "First seen\tinterval\thash\tsize [bytes]\ttxs\tfirst node\tmined by\tnodes\tlast seen after\n")
+	for _, block := range m.Blocks {
+		lastOffset := block.Observations[len(block.Observations)-1].Offset
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%d\t%s\n",
+			block.FirstSeen.Format(time.RFC3339Nano), block.Interval, block.Hash, block.SizeBytes, block.Txs, block.FirstNode, block.MinedBy, len(block.Observations), lastOffset)
+	}
+
+	if len(m.Announced) == 0 {
+		return tw.Flush()
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Block announcements timed by the clock of the observer")
+	fmt.Fprintf(tw, "Peer\tblocks\tfirst announced\tmean offset\t\n")
+	for _, peer := range m.Peers {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t\n", peer.Peer, peer.Blocks, peer.FirstSeen, peer.MeanOffset)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintf(tw, "\tcount\tmin\tmean\tstd dev\tp50\tp90\tp99\tmax\t\n")
+	writeDistribution(tw, "Announcement propagation [s]", m.AnnouncementSeconds)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintf(tw, "First announced\tobserver\thash\tfirst peer\tpeers\tlast announced after\n")
+	for _, block := range m.Announced {
+		lastOffset := block.Announcements[len(block.Announcements)-1].Offset
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			block.FirstSeen.Format(time.RFC3339Nano), block.Observer, block.Hash, block.FirstPeer, len(block.Announcements), lastOffset)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	outputs := map[string]string{
+		"node1": `{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block","service":"listener","hash":"aa","timestamp":"2024-12-11T13:30:10Z","txs":60,"size":12000}
+{"time":"2024-12-11T13:30:25Z","level":"INFO","msg":"Block","service":"listener","hash":"bb","timestamp":"2024-12-11T13:30:25Z","txs":150,"size":30000}`,
+		"node2": `{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block generated","service":"miner","hash":"aa"}
+{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block","service":"listener","hash":"aa","timestamp":"2024-12-11T13:30:10.1Z","txs":60,"size":12000}
+{"time":"2024-12-11T13:30:25Z","level":"INFO","msg":"Block","service":"listener","hash":"bb","timestamp":"2024-12-11T13:30:25.1Z","txs":150,"size":30000}`,
+		"node3": `{"time":"2024-12-11T13:30:15Z","level":"INFO","msg":"Block","service":"listener","hash":"aa","timestamp":"2024-12-11T13:30:15.05Z","txs":60,"size":12000}
+{"time":"2024-12-11T13:30:30Z","level":"INFO","msg":"Block","service":"listener","hash":"bb","timestamp":"2024-12-11T13:30:30.05Z","txs":150,"size":30000}`,
+	}
+
+	runs := make([]Run, 0)
+	for _, node := range []string{"node1", "node2", "node3"} {
+		events, err := ReadEvents(strings.NewReader(outputs[node]))
+		require.NoError(t, err)
+		runs = append(runs, SplitRuns(node, events)...)
+	}
+
+	merged := Merge(runs, time.Second, false)
+
+	require.Len(t, merged.Blocks, 2)
+	require.Equal(t, "aa", merged.Blocks[0].Hash)
+	require.Equal(t, "node1", merged.Blocks[0].FirstNode)
+	require.Equal(t, "node2", merged.Blocks[0].MinedBy)
+	require.Equal(t, 15*time.Second, merged.Blocks[1].Interval)
+
+	require.Len(t, merged.Nodes, 3)
+	require.False(t, merged.Nodes[0].Skewed)
+	require.False(t, merged.Nodes[1].Skewed)
+	require.True(t, merged.Nodes[2].Skewed)
+	require.Equal(t, 2, merged.Nodes[0].FirstSeen)
+
+	corrected := Merge(runs, time.Second, true)
+	require.Equal(t, "node1", corrected.Blocks[0].FirstNode)
+	require.Less(t, corrected.Blocks[0].Observations[2].Offset, time.Second)
+}
+
+func TestSplitRuns_Orchestrated(t *testing.T) {
+	output := `{"time":"2024-12-11T13:30:00Z","level":"INFO","msg":"Run started","node":"node1","rate":5}
+{"time":"2024-12-11T13:30:00Z","level":"INFO","msg":"Run started","node":"node2","rate":10}
+{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block","service":"listener","node":"node1","hash":"aa","timestamp":"2024-12-11T13:30:10Z"}
+{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block","service":"listener","node":"node2","hash":"aa","timestamp":"2024-12-11T13:30:10.2Z"}`
+
+	events, err := ReadEvents(strings.NewReader(output))
+	require.NoError(t, err)
+
+	runs := SplitRuns("orchestrate.log", events)
+	require.Len(t, runs, 2)
+	require.Equal(t, "orchestrate.log:node1#0", runs[0].Name())
+	require.Equal(t, "orchestrate.log:node2#0", runs[1].Name())
+	require.Len(t, runs[1].Events, 2)
+
+	merged := Merge(runs, time.Second, false)
+	require.Len(t, merged.Blocks, 1)
+	require.Equal(t, "node1", merged.Blocks[0].FirstNode)
+	require.Len(t, merged.Blocks[0].Observations, 2)
+}
+
+func TestMerge_MeasuredOffset(t *testing.T) {
+	outputs := map[string]string{
+		"node1": `{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block","service":"listener","hash":"aa","timestamp":"2024-12-11T13:30:10Z"}`,
+		"node2": `{"time":"2024-12-11T13:30:00Z","level":"INFO","msg":"Clock offset","network offset":"-4.9s"}
+{"time":"2024-12-11T13:30:15Z","level":"INFO","msg":"Block","service":"listener","hash":"aa","timestamp":"2024-12-11T13:30:15Z"}`,
+	}
+
+	runs := make([]Run, 0)
+	for _, node := range []string{"node1", "node2"} {
+		events, err := ReadEvents(strings.NewReader(outputs[node]))
+		require.NoError(t, err)
+		runs = append(runs, SplitRuns(node, events)...)
+	}
+
+	merged := Merge(runs, time.Second, true)
+
+	require.False(t, merged.Nodes[0].Measured)
+	require.True(t, merged.Nodes[1].Measured)
+	require.Equal(t, 4900*time.Millisecond, merged.Nodes[1].Skew)
+	require.True(t, merged.Nodes[1].Skewed)
+	// The skew of node1 is estimated against the corrected timestamps of node2
+	require.False(t, merged.Nodes[0].Skewed)
+	require.Equal(t, 50*time.Millisecond, merged.Blocks[0].Observations[1].Offset)
+}
+
+func TestMerge_Announcements(t *testing.T) {
+	output := `{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block announcement","service":"p2p","hash":"aa","peer":"node1:18444","via":"headers","timestamp":"2024-12-11T13:30:10Z"}
+{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block announcement","service":"p2p","hash":"aa","peer":"node2:18444","via":"headers","timestamp":"2024-12-11T13:30:10.2Z"}
+{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block announcement","service":"p2p","hash":"aa","peer":"node3:18444","via":"inv","timestamp":"2024-12-11T13:30:10.5Z"}
+{"time":"2024-12-11T13:30:25Z","level":"INFO","msg":"Block announcement","service":"p2p","hash":"bb","peer":"node2:18444","via":"headers","timestamp":"2024-12-11T13:30:25Z"}
+{"time":"2024-12-11T13:30:25Z","level":"INFO","msg":"Block announcement","service":"p2p","hash":"bb","peer":"node1:18444","via":"headers","timestamp":"2024-12-11T13:30:25.1Z"}`
+
+	events, err := ReadEvents(strings.NewReader(output))
+	require.NoError(t, err)
+
+	merged := Merge(SplitRuns("node1", events), time.Second, false)
+
+	require.Len(t, merged.Announced, 2)
+	require.Equal(t, "aa", merged.Announced[0].Hash)
+	require.Equal(t, "node1", merged.Announced[0].Observer)
+	require.Equal(t, "node1:18444", merged.Announced[0].FirstPeer)
+	require.Len(t, merged.Announced[0].Announcements, 3)
+	require.Equal(t, 500*time.Millisecond, merged.Announced[0].Announcements[2].Offset)
+	require.Equal(t, "inv", merged.Announced[0].Announcements[2].Via)
+	require.Equal(t, "node2:18444", merged.Announced[1].FirstPeer)
+
+	require.Equal(t, []PeerPropagation{
+		{Peer: "node1:18444", Blocks: 2, FirstSeen: 1, MeanOffset: 50 * time.Millisecond},
+		{Peer: "node2:18444", Blocks: 2, FirstSeen: 1, MeanOffset: 100 * time.Millisecond},
+		{Peer: "node3:18444", Blocks: 1, FirstSeen: 0, MeanOffset: 500 * time.Millisecond},
+	}, merged.Peers)
+	require.Equal(t, 3, merged.AnnouncementSeconds.Count)
+
+	var b strings.Builder
+	require.NoError(t, WriteMergedText(&b, merged))
+	require.Contains(t, b.String(), "Announcement propagation [s]")
+}
@@ -0,0 +1,295 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+)
+
+// Block is a block reported by the listener
+type Block struct {
+	Hash      string
+	Height    int64
+	Timestamp time.Time
+	Delta     time.Duration
+	SizeBytes uint64
+	Txs       uint64
+	Node      string
+}
+
+// Announcement is the first announcement of a block by a node observed as P2P peer. The announcements of all nodes
+// observed by a run are timed by the clock of this run.
+type Announcement struct {
+	Hash      string
+	Peer      string
+	Via       string
+	Timestamp time.Time
+	Observer  string
+}
+
+// StatsSample is a stats record periodically logged by the broadcaster
+type StatsSample struct {
+	Time       time.Time
+	TotalTxs   int64
+	Utxos      int64
+	MempoolTxs uint64
+}
+
+// Submission is a tx submitted by the broadcaster including all attempts. Failed submissions carry the error of the last
+// attempt and, if the tx has been built, the hash of the tx.
+type Submission struct {
+	Timestamp time.Time
+	Hash      string
+	Utxo      string
+	Duration  time.Duration
+	Attempts  int
+	Success   bool
+	Err       string
+}
+
+// ErrorRecord is a record logged with level error
+type ErrorRecord struct {
+	Time    time.Time
+	Service string
+	Msg     string
+	Err     string
+}
+
+// ScenarioAction is an action of the scenario timeline executed during the run
+type ScenarioAction struct {
+	Time   time.Time
+	Action string
+	Node   string
+}
+
+// Blocks returns the blocks reported by the listener ordered by time
+func (r Run) Blocks() []Block {
+	blocks := make([]Block, 0)
+
+	for _, e := range r.Events {
+		if e.Msg != MsgBlock || e.Service != ServiceListener {
+			continue
+		}
+
+		timestamp, found := e.Timestamp("timestamp")
+		if !found {
+			timestamp = e.Time
+		}
+
+		delta, _ := e.Duration("delta")
+		size, _ := e.Float("size")
+		txs, _ := e.Float("txs")
+		height, _ := e.Float("height")
+
+		node := e.String("node")
+		if node == "" {
+			node = r.Source
+		}
+
+		blocks = append(blocks, Block{
+			Hash:      e.String("hash"),
+			Height:    int64(height),
+			Timestamp: timestamp,
+			Delta:     delta,
+			SizeBytes: uint64(size),
+			Txs:       uint64(txs),
+			Node:      node,
+		})
+	}
+
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].Timestamp.Before(blocks[j].Timestamp)
+	})
+
+	return blocks
+}
+
+// Announcements returns the block announcements of the observed nodes ordered by time
+func (r Run) Announcements() []Announcement {
+	announcements := make([]Announcement, 0)
+
+	for _, e := range r.Events {
+		if e.Msg != MsgBlockAnnouncement || e.Service != ServiceP2P {
+			continue
+		}
+
+		timestamp, found := e.Timestamp("timestamp")
+		if !found {
+			timestamp = e.Time
+		}
+
+		observer := e.String("node")
+		if observer == "" {
+			observer = r.Source
+		}
+
+		announcements = append(announcements, Announcement{
+			Hash:      e.String("hash"),
+			Peer:      e.String("peer"),
+			Via:       e.String("via"),
+			Timestamp: timestamp,
+			Observer:  observer,
+		})
+	}
+
+	sort.SliceStable(announcements, func(i, j int) bool {
+		return announcements[i].Timestamp.Before(announcements[j].Timestamp)
+	})
+
+	return announcements
+}
+
+// ScenarioActions returns the executed actions of the scenario timeline
+func (r Run) ScenarioActions() []ScenarioAction {
+	actions := make([]ScenarioAction, 0)
+
+	for _, e := range r.Events {
+		if e.Msg != MsgScenarioEvent || e.Service != ServiceScenario {
+			continue
+		}
+
+		node := e.String("node")
+		if node == "" {
+			node = r.Source
+		}
+
+		actions = append(actions, ScenarioAction{
+			Time:   e.Time,
+			Action: e.String("action"),
+			Node:   node,
+		})
+	}
+
+	return actions
+}
+
+// NodeName returns the node of the run or the source file if the node is not logged
+func (r Run) NodeName() string {
+	for _, e := range r.Events {
+		node := e.String("node")
+		if node != "" {
+			return node
+		}
+	}
+
+	return r.Source
+}
+
+// ClockOffset returns the offset of the network time against the local clock measured at the start of the run
+func (r Run) ClockOffset() (time.Duration, bool) {
+	for _, e := range r.Events {
+		if e.Msg == MsgClockOffset {
+			return e.Duration("network offset")
+		}
+	}
+
+	return 0, false
+}
+
+// StatsSamples returns the stats records of the broadcaster
+func (r Run) StatsSamples() []StatsSample {
+	samples := make([]StatsSample, 0)
+
+	for _, e := range r.Events {
+		if e.Msg != MsgStats || e.Service != ServiceBroadcaster {
+			continue
+		}
+
+		total, _ := e.Float("total")
+		utxos, _ := e.Float("utxos")
+		mempoolTxs, _ := e.Float("mempool txs")
+
+		samples = append(samples, StatsSample{
+			Time:       e.Time,
+			TotalTxs:   int64(total),
+			Utxos:      int64(utxos),
+			MempoolTxs: uint64(mempoolTxs),
+		})
+	}
+
+	return samples
+}
+
+// Submissions returns the submitted txs logged with -log-submissions and the failed submissions. A tx which has been
+// submitted as P2P peer, but has later not been requested or has been rejected by the node, is logged as late failure
+// which replaces its successful submission.
+func (r Run) Submissions() []Submission {
+	submissions := make([]Submission, 0)
+	submitted := map[string]int{}
+
+	for _, e := range r.Events {
+		if e.Service != ServiceBroadcaster {
+			continue
+		}
+
+		switch e.Msg {
+		case MsgTxSubmitted:
+			timestamp, found := e.Timestamp("timestamp")
+			if !found {
+				timestamp = e.Time
+			}
+
+			duration, _ := e.Duration("duration")
+			attempts, _ := e.Float("attempts")
+
+			submitted[e.String("hash")] = len(submissions)
+			submissions = append(submissions, Submission{
+				Timestamp: timestamp,
+				Hash:      e.String("hash"),
+				Duration:  duration,
+				Attempts:  int(attempts),
+				Success:   true,
+			})
+		case MsgSubmittingTxFailed:
+			if e.String("late") == "true" {
+				i, found := submitted[e.String("hash")]
+				if found {
+					submissions[i].Success = false
+					submissions[i].Err = e.String("err")
+					continue
+				}
+			}
+
+			timestamp, found := e.Timestamp("timestamp")
+			if !found {
+				timestamp = e.Time
+			}
+
+			duration, _ := e.Duration("duration")
+			attempts, found := e.Float("attempts")
+			if !found {
+				attempts = 1
+			}
+
+			submissions = append(submissions, Submission{
+				Timestamp: timestamp,
+				Hash:      e.String("hash"),
+				Utxo:      e.String("utxo"),
+				Duration:  duration,
+				Attempts:  int(attempts),
+				Err:       e.String("err"),
+			})
+		}
+	}
+
+	return submissions
+}
+
+// Errors returns all records logged with level error
+func (r Run) Errors() []ErrorRecord {
+	records := make([]ErrorRecord, 0)
+
+	for _, e := range r.Events {
+		if e.Level != LevelError {
+			continue
+		}
+
+		records = append(records, ErrorRecord{
+			Time:    e.Time,
+			Service: e.Service,
+			Msg:     e.Msg,
+			Err:     e.String("err"),
+		})
+	}
+
+	return records
+}
@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// Report contains the summaries and charts of one or more runs
+type Report struct {
+	Title     string
+	Generated time.Time
+	Summaries []Summary
+	Merged    Merged
+	Charts    []Chart
+}
+
+// NewReport creates the charts of the runs. Block intervals and propagation are taken from the merged timeline.
+func NewReport(title string, runs []Run, merged Merged) Report {
+	r := Report{
+		Title:     title,
+		Generated: time.Now(),
+		Summaries: make([]Summary, 0, len(runs)),
+		Merged:    merged,
+	}
+
+	rates := Chart{Title: "Tx rate", XLabel: "time [UTC]", YLabel: "txs/s", TimeAxis: true}
+	mempool := Chart{Title: "Mempool size", XLabel: "time [UTC]", YLabel: "txs", TimeAxis: true}
+	latency := Chart{Title: "Confirmation latency (time until next block)", XLabel: "latency [s]", YLabel: "fraction of txs"}
+
+	for _, run := range runs {
+		r.Summaries = append(r.Summaries, Summarize(run))
+
+		for _, action := range run.ScenarioActions() {
+			marker := Marker{X: unixSeconds(action.Time), Label: action.Node + ": " + action.Action}
+			rates.Markers = append(rates.Markers, marker)
+			mempool.Markers = append(mempool.Markers, marker)
+		}
+
+		samples := run.StatsSamples()
+
+		rateSeries := Series{Name: run.Name()}
+		for _, sample := range TxRates(samples) {
+			rateSeries.Points = append(rateSeries.Points, Point{X: unixSeconds(sample.Time), Y: sample.Rate})
+		}
+		rates.Series = append(rates.Series, rateSeries)
+
+		mempoolSeries := Series{Name: run.Name()}
+		for _, sample := range samples {
+			mempoolSeries.Points = append(mempoolSeries.Points, Point{X: unixSeconds(sample.Time), Y: float64(sample.MempoolTxs)})
+		}
+		mempool.Series = append(mempool.Series, mempoolSeries)
+
+		latencies := ConfirmationLatencies(run)
+		if len(latencies) > 0 {
+			values := make([]float64, len(latencies))
+			for i, l := range latencies {
+				values[i] = l.Seconds()
+			}
+			latency.Series = append(latency.Series, CDFSeries(run.Name(), values))
+		}
+	}
+
+	sizes := Chart{Title: "Block size vs interval", XLabel: "interval [s]", YLabel: "size [bytes]", Scatter: true}
+	sizeSeries := Series{Name: "blocks"}
+	offsets := map[string][]float64{}
+	for i, block := range merged.Blocks {
+		if i > 0 {
+			sizeSeries.Points = append(sizeSeries.Points, Point{X: block.Interval.Seconds(), Y: float64(block.SizeBytes)})
+		}
+
+		for _, observation := range block.Observations {
+			offsets[observation.Node] = append(offsets[observation.Node], observation.Offset.Seconds())
+		}
+	}
+	sizes.Series = append(sizes.Series, sizeSeries)
+
+	propagation := Chart{Title: "Block propagation per node", XLabel: "seen after first node [s]", YLabel: "fraction of blocks"}
+	nodes := make([]string, 0, len(offsets))
+	for node := range offsets {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		propagation.Series = append(propagation.Series, CDFSeries(node, offsets[node]))
+	}
+
+	r.Charts = []Chart{rates, mempool, sizes, latency, propagation}
+
+	return r
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 24px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.chart { margin-bottom: 24px; }
+.skewed { color: #d62728; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{time .Generated}}</p>
+
+<h2>Runs</h2>
+<table>
+<tr><th>Run</th><th>Start</th><th>End</th><th>Blocks</th><th>Mean interval [s]</th><th>Mean block size [bytes]</th><th>Target rate [txs/s]</th><th>Achieved rate [txs/s]</th><th>Total txs</th><th>Errors</th></tr>
+{{- range .Summaries}}
+<tr><td>{{.Run}}</td><td>{{time .Start}}</td><td>{{time .End}}</td><td>{{.Blocks}}</td><td>{{printf "%.2f" .BlockIntervalSeconds.Mean}}</td><td>{{printf "%.0f" .BlockSizeBytes.Mean}}</td><td>{{printf "%.2f" .TargetRate}}</td><td>{{printf "%.2f" .AchievedRate}}</td><td>{{.TotalTxs}}</td><td>{{.ErrorCount}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Nodes</h2>
+<table>
+<tr><th>Node</th><th>Blocks</th><th>First seen</th><th>Mean offset</th><th>Clock skew</th></tr>
+{{- range .Merged.Nodes}}
+<tr><td>{{.Node}}</td><td>{{.Blocks}}</td><td>{{.FirstSeen}}</td><td>{{.MeanOffset}}</td><td{{if .Skewed}} class="skewed"{{end}}>{{.Skew}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Charts</h2>
+{{- range .Charts}}
+<div class="chart">{{.SVG}}</div>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a single HTML file with the charts embedded as SVG
+func (r Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
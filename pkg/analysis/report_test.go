@@ -0,0 +1,38 @@
+package analysis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport_WriteHTML(t *testing.T) {
+	events, err := ReadEvents(strings.NewReader(testOutput))
+	require.NoError(t, err)
+
+	runs := SplitRuns("output.log", events)
+	report := NewReport("Test report", runs, Merge(runs, time.Second, false))
+	require.Len(t, report.Charts, 5)
+
+	rates := report.Charts[0].Series[0].Points
+	require.Len(t, rates, 1)
+	require.InDelta(t, 152.0/25, rates[0].Y, 0.001)
+
+	var buf bytes.Buffer
+	err = report.WriteHTML(&buf)
+	require.NoError(t, err)
+
+	html := buf.String()
+	require.Contains(t, html, "<title>Test report</title>")
+	require.Contains(t, html, "output.log#0")
+	require.Equal(t, 5, strings.Count(html, "<svg "))
+	require.Contains(t, html, "No data")
+}
+
+func TestTicks(t *testing.T) {
+	require.Equal(t, []float64{0, 20, 40, 60, 80, 100}, ticks(0, 100))
+	require.Equal(t, []float64{0.5, 1, 1.5, 2}, ticks(0.3, 2.2))
+}
@@ -0,0 +1,261 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/errclass"
+)
+
+// Distribution summarizes a set of values
+type Distribution struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P99    float64 `json:"p99"`
+}
+
+// NewDistribution calculates the distribution of the given values
+func NewDistribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+
+	var squares float64
+	for _, v := range sorted {
+		squares += (v - mean) * (v - mean)
+	}
+
+	var stdDev float64
+	if len(sorted) > 1 {
+		stdDev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+
+	return Distribution{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   mean,
+		StdDev: stdDev,
+		P50:    Percentile(sorted, 0.5),
+		P90:    Percentile(sorted, 0.9),
+		P99:    Percentile(sorted, 0.99),
+	}
+}
+
+// Percentile returns the percentile p between 0 and 1 of the sorted values using linear interpolation
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// MempoolSample is the size of the mempool at a point in time
+type MempoolSample struct {
+	Time time.Time `json:"time"`
+	Txs  uint64    `json:"txs"`
+}
+
+// Summary contains the statistics of one run
+type Summary struct {
+	Run    string         `json:"run"`
+	Config map[string]any `json:"config,omitempty"`
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+
+	Blocks               int          `json:"blocks"`
+	BlockIntervalSeconds Distribution `json:"block_interval_seconds"`
+	BlockSizeBytes       Distribution `json:"block_size_bytes"`
+	BlockTxs             Distribution `json:"block_txs"`
+
+	TargetRate   float64 `json:"target_rate"`
+	AchievedRate float64 `json:"achieved_rate"`
+	TotalTxs     int64   `json:"total_txs"`
+
+	MempoolTxs Distribution    `json:"mempool_txs"`
+	Mempool    []MempoolSample `json:"mempool"`
+
+	ErrorCount int            `json:"error_count"`
+	Errors     map[string]int `json:"errors"`
+}
+
+// BlockIntervals returns the time between consecutive blocks
+func BlockIntervals(blocks []Block) []time.Duration {
+	if len(blocks) < 2 {
+		return nil
+	}
+
+	intervals := make([]time.Duration, 0, len(blocks)-1)
+	for i := 1; i < len(blocks); i++ {
+		intervals = append(intervals, blocks[i].Timestamp.Sub(blocks[i-1].Timestamp))
+	}
+
+	return intervals
+}
+
+// broadcastingPeriod returns the times at which broadcasting started and stopped
+func (r Run) broadcastingPeriod() (start time.Time, end time.Time) {
+	for _, e := range r.Events {
+		switch {
+		case e.Msg == MsgStartingBroadcasting && e.Service == ServiceBroadcaster && start.IsZero():
+			start = e.Time
+		case e.Msg == MsgStoppingBroadcasting && e.Service == ServiceBroadcaster:
+			end = e.Time
+		}
+	}
+
+	return start, end
+}
+
+// TargetRate returns the configured rate of txs per second
+func (r Run) TargetRate() float64 {
+	for _, e := range r.Events {
+		if e.Msg == MsgStartingBroadcasting || e.Msg == MsgRunStarted {
+			rate, found := e.Float("rate")
+			if found {
+				return rate
+			}
+		}
+	}
+
+	return 0
+}
+
+// Summarize calculates the statistics of the run
+func Summarize(r Run) Summary {
+	s := Summary{
+		Run:        r.Name(),
+		Config:     r.Config(),
+		TargetRate: r.TargetRate(),
+		Errors:     map[string]int{},
+	}
+
+	if len(r.Events) > 0 {
+		s.Start = r.Events[0].Time
+		s.End = r.Events[len(r.Events)-1].Time
+	}
+
+	blocks := r.Blocks()
+	s.Blocks = len(blocks)
+
+	intervals := BlockIntervals(blocks)
+	intervalValues := make([]float64, len(intervals))
+	for i, interval := range intervals {
+		intervalValues[i] = interval.Seconds()
+	}
+	s.BlockIntervalSeconds = NewDistribution(intervalValues)
+
+	sizes := make([]float64, len(blocks))
+	txs := make([]float64, len(blocks))
+	for i, block := range blocks {
+		sizes[i] = float64(block.SizeBytes)
+		txs[i] = float64(block.Txs)
+	}
+	s.BlockSizeBytes = NewDistribution(sizes)
+	s.BlockTxs = NewDistribution(txs)
+
+	samples := r.StatsSamples()
+	s.Mempool = make([]MempoolSample, len(samples))
+	mempoolValues := make([]float64, len(samples))
+	for i, sample := range samples {
+		s.Mempool[i] = MempoolSample{Time: sample.Time, Txs: sample.MempoolTxs}
+		mempoolValues[i] = float64(sample.MempoolTxs)
+	}
+	s.MempoolTxs = NewDistribution(mempoolValues)
+
+	start, end := r.broadcastingPeriod()
+	if len(samples) > 0 {
+		last := samples[len(samples)-1]
+		s.TotalTxs = last.TotalTxs
+		if end.IsZero() || last.Time.After(end) {
+			end = last.Time
+		}
+	}
+	if !start.IsZero() && end.After(start) {
+		s.AchievedRate = float64(s.TotalTxs) / end.Sub(start).Seconds()
+	}
+
+	for _, record := range r.Errors() {
+		s.ErrorCount++
+		class := record.Msg
+		if record.Err != "" {
+			class = fmt.Sprintf("%s: %s", record.Msg, errclass.Of(record.Err))
+		}
+		s.Errors[class]++
+	}
+
+	return s
+}
+
+// WriteText writes the summaries in a human-readable format
+func WriteText(w io.Writer, summaries []Summary) error {
+	for _, s := range summaries {
+		fmt.Fprintf(w, "Run:    %s\n", s.Run)
+		fmt.Fprintf(w, "Period: %s - %s\n", s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
+		fmt.Fprintf(w, "Blocks: %d\n\n", s.Blocks)
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "\tcount\tmin\tmean\tstd dev\tp50\tp90\tp99\tmax\t\n")
+		writeDistribution(tw, "Block interval [s]", s.BlockIntervalSeconds)
+		writeDistribution(tw, "Block size [bytes]", s.BlockSizeBytes)
+		writeDistribution(tw, "Block txs", s.BlockTxs)
+		writeDistribution(tw, "Mempool txs", s.MempoolTxs)
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+
+		achievedRatio := 0.0
+		if s.TargetRate > 0 {
+			achievedRatio = 100 * s.AchievedRate / s.TargetRate
+		}
+		fmt.Fprintf(w, "\nTx rate [txs/s]: target %.2f, achieved %.2f (%.1f%%), total txs %d\n", s.TargetRate, s.AchievedRate, achievedRatio, s.TotalTxs)
+
+		fmt.Fprintf(w, "Errors: %d\n", s.ErrorCount)
+		classes := make([]string, 0, len(s.Errors))
+		for class := range s.Errors {
+			classes = append(classes, class)
+		}
+		sort.Slice(classes, func(i, j int) bool {
+			if s.Errors[classes[i]] == s.Errors[classes[j]] {
+				return classes[i] < classes[j]
+			}
+			return s.Errors[classes[i]] > s.Errors[classes[j]]
+		})
+		for _, class := range classes {
+			fmt.Fprintf(w, "  %8d  %s\n", s.Errors[class], class)
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+func writeDistribution(w io.Writer, name string, d Distribution) {
+	fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n", name, d.Count, d.Min, d.Mean, d.StdDev, d.P50, d.P90, d.P99, d.Max)
+}
@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testOutput = `{"time":"2024-12-11T13:29:00Z","level":"INFO","msg":"Run started","seed":1734000000000000001,"rate":10}
+{"time":"2024-12-11T13:30:00Z","level":"INFO","msg":"Starting broadcasting","service":"broadcaster","outputs":10000,"rate":10}
+{"time":"2024-12-11T13:30:05Z","level":"INFO","msg":"Stats","service":"broadcaster","total":48,"time left":"1m55s","utxos":10000,"mempool txs":48}
+{"time":"2024-12-11T13:30:10Z","level":"INFO","msg":"Block","service":"listener","hash":"aa","timestamp":"2024-12-11T13:30:10Z","delta":"10s","txs":60,"size":12000}
+not a json line
+{"time":"2024-12-11T13:30:11Z","level":"ERROR","msg":"Submitting tx failed","service":"broadcaster","hash":"00","err":"Transaction outputs already in utxo set"}
+{"time":"2024-12-11T13:30:25Z","level":"INFO","msg":"Block","service":"listener","hash":"bb","timestamp":"2024-12-11T13:30:25Z","delta":"15s","txs":150,"size":30000}
+{"time":"2024-12-11T13:30:30Z","level":"INFO","msg":"Stats","service":"broadcaster","total":200,"time left":"1m30s","utxos":10000,"mempool txs":50}
+{"time":"2024-12-11T13:32:00Z","level":"INFO","msg":"Run started","seed":43,"rate":20}
+`
+
+func TestSummarize(t *testing.T) {
+	events, err := ReadEvents(strings.NewReader(testOutput))
+	require.NoError(t, err)
+
+	runs := SplitRuns("output.log", events)
+	require.Len(t, runs, 2)
+
+	require.Equal(t, json.Number("1734000000000000001"), runs[0].Config()["seed"])
+
+	summary := Summarize(runs[0])
+
+	require.Equal(t, "output.log#0", summary.Run)
+	require.Equal(t, 2, summary.Blocks)
+	require.Equal(t, 1, summary.BlockIntervalSeconds.Count)
+	require.InDelta(t, 15, summary.BlockIntervalSeconds.Mean, 0.001)
+	require.InDelta(t, 21000, summary.BlockSizeBytes.Mean, 0.001)
+	require.InDelta(t, 10, summary.TargetRate, 0.001)
+	require.InDelta(t, 200.0/30, summary.AchievedRate, 0.001)
+	require.Len(t, summary.Mempool, 2)
+	require.Equal(t, map[string]int{"Submitting tx failed: outputs already in utxo set": 1}, summary.Errors)
+
+	summary = Summarize(runs[1])
+	require.InDelta(t, 20, summary.TargetRate, 0.001)
+	require.Equal(t, 0, summary.Blocks)
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+
+	require.InDelta(t, 3, Percentile(sorted, 0.5), 0.001)
+	require.InDelta(t, 4.6, Percentile(sorted, 0.9), 0.001)
+	require.InDelta(t, 0, Percentile(nil, 0.5), 0.001)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/listener"
+)
+
+type Broadcaster interface {
+	Status() broadcaster.Status
+	SetRate(rateTxsPerSecond int64) error
+	Pause()
+	Resume()
+}
+
+type Miner interface {
+	Trigger() error
+}
+
+type Listener interface {
+	RecentBlocks() []listener.Block
+}
+
+// Server is an HTTP API to inspect and control a running broadcaster
+type Server struct {
+	broadcaster Broadcaster
+	miner       Miner
+	listener    Listener
+	stop        func()
+	logger      *slog.Logger
+	mux         *http.ServeMux
+}
+
+// StatusResponse is the current state of the run
+type StatusResponse struct {
+	broadcaster.Status
+	Elapsed      string           `json:"elapsed"`
+	RecentBlocks []listener.Block `json:"recent_blocks"`
+}
+
+type RateRequest struct {
+	Rate int64 `json:"rate"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New creates the API. The stop function is called to stop the run gracefully.
+func New(b Broadcaster, m Miner, l Listener, stop func(), logger *slog.Logger) *Server {
+	s := &Server{
+		broadcaster: b,
+		miner:       m,
+		listener:    l,
+		stop:        stop,
+		logger:      logger.With(slog.String("service", "api")),
+		mux:         http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	s.mux.HandleFunc("POST /rate", s.handleRate)
+	s.mux.HandleFunc("POST /pause", s.handlePause)
+	s.mux.HandleFunc("POST /resume", s.handleResume)
+	s.mux.HandleFunc("POST /block", s.handleBlock)
+	s.mux.HandleFunc("POST /stop", s.handleStop)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve serves the API on the given address until the context is canceled
+func (s *Server) Serve(ctx context.Context, addr string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		s.logger.Info("Serving API", "addr", addr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Failed to serve API", "err", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+}
+
+func writeResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeResponse(w, status, errorResponse{Error: err.Error()})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	status := StatusResponse{
+		Status:       s.broadcaster.Status(),
+		RecentBlocks: s.listener.RecentBlocks(),
+	}
+
+	if !status.StartedAt.IsZero() {
+		status.Elapsed = time.Since(status.StartedAt).Round(time.Second).String()
+	}
+
+	writeResponse(w, http.StatusOK, status)
+}
+
+func (s *Server) handleRate(w http.ResponseWriter, r *http.Request) {
+	var request RateRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	oldRate := s.broadcaster.Status().Rate
+
+	err = s.broadcaster.SetRate(request.Rate)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.logger.Info("Rate changed", "old rate", oldRate, "rate", request.Rate)
+
+	writeResponse(w, http.StatusOK, s.broadcaster.Status())
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, _ *http.Request) {
+	s.broadcaster.Pause()
+	s.logger.Info("Broadcasting paused")
+
+	writeResponse(w, http.StatusOK, s.broadcaster.Status())
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, _ *http.Request) {
+	s.broadcaster.Resume()
+	s.logger.Info("Broadcasting resumed")
+
+	writeResponse(w, http.StatusOK, s.broadcaster.Status())
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, _ *http.Request) {
+	err := s.miner.Trigger()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(w, http.StatusAccepted, struct{}{})
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, _ *http.Request) {
+	s.logger.Info("Stop requested")
+	s.stop()
+
+	writeResponse(w, http.StatusAccepted, struct{}{})
+}
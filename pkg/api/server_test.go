@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/listener"
+)
+
+type broadcasterMock struct {
+	status broadcaster.Status
+}
+
+func (b *broadcasterMock) Status() broadcaster.Status { return b.status }
+
+func (b *broadcasterMock) SetRate(rate int64) error {
+	if rate <= 0 {
+		return errors.New("invalid rate")
+	}
+	b.status.Rate = rate
+	return nil
+}
+
+func (b *broadcasterMock) Pause()  { b.status.Paused = true }
+func (b *broadcasterMock) Resume() { b.status.Paused = false }
+
+type minerMock struct {
+	err error
+}
+
+func (m *minerMock) Trigger() error { return m.err }
+
+type listenerMock struct{}
+
+func (l *listenerMock) RecentBlocks() []listener.Block {
+	return []listener.Block{{Hash: "aa", Height: 101}}
+}
+
+func TestServer(t *testing.T) {
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		minerErr error
+
+		expectedStatus int
+		expectedRate   int64
+		expectedPaused bool
+		expectedStop   bool
+	}{
+		{
+			name:   "status",
+			method: http.MethodGet,
+			path:   "/status",
+
+			expectedStatus: http.StatusOK,
+			expectedRate:   5,
+		},
+		{
+			name:   "change rate",
+			method: http.MethodPost,
+			path:   "/rate",
+			body:   `{"rate":20}`,
+
+			expectedStatus: http.StatusOK,
+			expectedRate:   20,
+		},
+		{
+			name:   "invalid rate",
+			method: http.MethodPost,
+			path:   "/rate",
+			body:   `{"rate":0}`,
+
+			expectedStatus: http.StatusBadRequest,
+			expectedRate:   5,
+		},
+		{
+			name:   "pause",
+			method: http.MethodPost,
+			path:   "/pause",
+
+			expectedStatus: http.StatusOK,
+			expectedRate:   5,
+			expectedPaused: true,
+		},
+		{
+			name:     "trigger block - generating block failed",
+			method:   http.MethodPost,
+			path:     "/block",
+			minerErr: errors.New("failed to generate block: connection refused"),
+
+			expectedStatus: http.StatusInternalServerError,
+			expectedRate:   5,
+		},
+		{
+			name:   "stop",
+			method: http.MethodPost,
+			path:   "/stop",
+
+			expectedStatus: http.StatusAccepted,
+			expectedRate:   5,
+			expectedStop:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &broadcasterMock{status: broadcaster.Status{Rate: 5, StartedAt: time.Now().Add(-time.Minute)}}
+			stopped := false
+
+			sut := New(b, &minerMock{err: tc.minerErr}, &listenerMock{}, func() { stopped = true }, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+			recorder := httptest.NewRecorder()
+			sut.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+
+			require.Equal(t, tc.expectedStatus, recorder.Code)
+			require.Equal(t, tc.expectedRate, b.status.Rate)
+			require.Equal(t, tc.expectedPaused, b.status.Paused)
+			require.Equal(t, tc.expectedStop, stopped)
+
+			if tc.path == "/status" {
+				var status StatusResponse
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&status))
+				require.Equal(t, "1m0s", status.Elapsed)
+				require.Len(t, status.RecentBlocks, 1)
+			}
+		})
+	}
+}
@@ -5,16 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+
+	"github.com/boecklim/node-analysis/pkg/metrics"
 )
 
 type Processor interface {
 	PrepareUtxos(utxoChannel chan TxOut, targetUtxos int) (err error)
+	// SubmitSelfPayingSingleOutputTx submits a tx spending the output to itself. If the tx has been built, but its
+	// submission fails, the hash of the tx is returned together with the error.
 	SubmitSelfPayingSingleOutputTx(txOut TxOut) (txHash *chainhash.Hash, satoshis int64, err error)
 	GetMempoolSize() (nrTxs uint64, err error)
 }
@@ -27,17 +32,118 @@ type Broadcaster struct {
 	ctx       context.Context
 	wg        sync.WaitGroup
 	totalTxs  int64
+	failedTxs int64
 	limit     time.Duration
+
+	rate      atomic.Int64
+	paused    atomic.Bool
+	startedAt atomic.Pointer[time.Time]
+	logger    atomic.Pointer[slog.Logger]
+
+	arrival        string
+	rng            *rand.Rand
+	logSubmissions bool
+	metrics        *metrics.Metrics
+
+	doubleSpender    DoubleSpender
+	doubleSpendRatio float64
+	doubleSpends     atomic.Int64
+}
+
+// DoubleSpend are two txs spending the same utxo which have been sent to two different nodes at the same time
+type DoubleSpend struct {
+	Utxo    string
+	Hashes  [2]string
+	Targets [2]string
+	Errs    [2]error
+	SentAt  time.Time
+}
+
+// Accepted returns whether at least one of the txs has been accepted
+func (d DoubleSpend) Accepted() bool {
+	return d.Errs[0] == nil || d.Errs[1] == nil
+}
+
+type DoubleSpender interface {
+	SubmitDoubleSpend(txOut TxOut) (DoubleSpend, error)
 }
 
 const (
-	millisecondsPerSecond = 1000
+	// maxAttempts is the number of times the submission of a tx is attempted
+	maxAttempts = 3
+
+	// ArrivalConstant submits txs in constant intervals
+	ArrivalConstant = "constant"
+	// ArrivalPoisson submits txs with exponentially distributed intervals such that the arrivals form a poisson process
+	ArrivalPoisson = "poisson"
 )
 
-func NewBroadcaster(client Processor) (*Broadcaster, error) {
+// Status is the current state of the broadcaster
+type Status struct {
+	Rate         int64     `json:"rate"`
+	Paused       bool      `json:"paused"`
+	StartedAt    time.Time `json:"started_at"`
+	TotalTxs     int64     `json:"total_txs"`
+	FailedTxs    int64     `json:"failed_txs"`
+	DoubleSpends int64     `json:"double_spends"`
+	Utxos        int       `json:"utxos"`
+}
+
+type Option func(b *Broadcaster)
+
+// WithArrival sets the arrival process of the submitted txs - default is ArrivalConstant
+func WithArrival(arrival string) Option {
+	return func(b *Broadcaster) {
+		b.arrival = arrival
+	}
+}
+
+// WithRand sets the source of randomness from which the intervals of the poisson arrival process are sampled
+func WithRand(rng *rand.Rand) Option {
+	return func(b *Broadcaster) {
+		b.rng = rng
+	}
+}
+
+// WithSubmissionLogging logs each successfully submitted tx
+func WithSubmissionLogging() Option {
+	return func(b *Broadcaster) {
+		b.logSubmissions = true
+	}
+}
+
+// WithMetrics records submitted and failed txs, the number of utxos and the mempool size
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(b *Broadcaster) {
+		b.metrics = m
+	}
+}
+
+// WithDoubleSpends double spends the given ratio of the utxos instead of submitting a self paying tx. The outputs of the
+// double spending txs are not spent again.
+func WithDoubleSpends(spender DoubleSpender, ratio float64) Option {
+	return func(b *Broadcaster) {
+		b.doubleSpender = spender
+		b.doubleSpendRatio = ratio
+	}
+}
+
+func NewBroadcaster(client Processor, opts ...Option) (*Broadcaster, error) {
 	b := &Broadcaster{
 		processor:   client,
 		utxoChannel: make(chan TxOut, 10100),
+		arrival:     ArrivalConstant,
+		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	switch b.arrival {
+	case ArrivalConstant, ArrivalPoisson:
+	default:
+		return nil, fmt.Errorf("arrival %s not valid - has to be either %s or %s", b.arrival, ArrivalConstant, ArrivalPoisson)
 	}
 
 	ctx, cancelAll := context.WithCancel(context.Background())
@@ -58,21 +164,23 @@ func (b *Broadcaster) PrepareUtxos(targetUtxos int) (err error) {
 
 func (b *Broadcaster) Start(rateTxsPerSecond int64, limit time.Duration, logger *slog.Logger, startAt time.Time) (err error) {
 	b.limit = limit
+	b.rate.Store(rateTxsPerSecond)
 	deadline := time.Now().Add(limit)
 
 	logger = logger.With(slog.String("service", "broadcaster"))
+	b.logger.Store(logger)
 
 	startTimer := time.NewTimer(time.Until(startAt))
 	logger.Info("Waiting to start", "until", startAt.String())
 	<-startTimer.C
 
-	logger.Info("Starting broadcasting", "outputs", len(b.utxoChannel))
+	logger.Info("Starting broadcasting", "outputs", len(b.utxoChannel), "rate", rateTxsPerSecond, "arrival", b.arrival, "limit", limit.String())
+
+	startedAt := time.Now()
+	b.startedAt.Store(&startedAt)
 
-	submitInterval := time.Duration(millisecondsPerSecond/float64(rateTxsPerSecond)) * time.Millisecond
-	submitTicker := time.NewTicker(submitInterval)
+	submitTimer := time.NewTimer(b.nextSubmitInterval(submitInterval(rateTxsPerSecond)))
 
-	var satoshis int64
-	var hash *chainhash.Hash
 	statTicker := time.NewTicker(5 * time.Second)
 	ctx, cancel := context.WithDeadline(b.ctx, deadline)
 	defer cancel()
@@ -84,7 +192,6 @@ func (b *Broadcaster) Start(rateTxsPerSecond int64, limit time.Duration, logger
 			b.wg.Done()
 		}()
 
-	mainLoop:
 		for {
 			select {
 			case <-ctx.Done():
@@ -94,40 +201,56 @@ func (b *Broadcaster) Start(rateTxsPerSecond int64, limit time.Duration, logger
 				mempoolSize, err = b.processor.GetMempoolSize()
 				if err != nil {
 					logger.Error("Failed to get mempool size", "err", err)
+				} else {
+					b.metrics.SetMempoolTxs(mempoolSize)
 				}
+				b.metrics.SetUtxos(len(b.utxoChannel))
 
 				logger.Info("Stats", slog.Int64("total", atomic.LoadInt64(&b.totalTxs)), slog.String("time left", time.Until(deadline).String()), slog.Int("utxos", len(b.utxoChannel)), slog.Uint64("mempool txs", mempoolSize))
-			case <-submitTicker.C:
-				txOut := <-b.utxoChannel
+			case <-submitTimer.C:
+				submitTimer.Reset(b.nextSubmitInterval(submitInterval(b.rate.Load())))
 
-				success := false
+				if b.paused.Load() {
+					continue
+				}
 
-				// Try 3 times
-				for range 3 {
-					hash, satoshis, err = b.processor.SubmitSelfPayingSingleOutputTx(txOut)
-					if err != nil {
-						if errors.Is(err, context.Canceled) {
-							return
-						}
+				txOut := <-b.utxoChannel
 
-						logger.Error("Submitting tx failed", "hash", txOut.Hash.String(), "err", err)
-						if strings.Contains(err.Error(), "Transaction outputs already in utxo set") {
-							continue mainLoop
-						}
+				if b.doubleSpender != nil && b.rng.Float64() < b.doubleSpendRatio {
+					b.doubleSpend(txOut, logger)
+					continue
+				}
 
-						time.Sleep(50 * time.Millisecond)
-						continue
+				submittedAt := time.Now()
+				hash, satoshis, attempts, err := b.submit(txOut, logger)
+				if err != nil {
+					if errors.Is(err, context.Canceled) {
+						return
 					}
 
-					success = true
-					break
-				}
+					atomic.AddInt64(&b.failedTxs, 1)
+					b.metrics.TxFailed(err)
+
+					attrs := []any{
+						slog.String("utxo", fmt.Sprintf("%s:%d", txOut.Hash.String(), txOut.VOut)),
+						slog.String("timestamp", submittedAt.Format(time.RFC3339Nano)),
+						slog.String("duration", time.Since(submittedAt).String()),
+						slog.Int("attempts", attempts),
+						slog.String("err", err.Error()),
+					}
+					if hash != nil {
+						attrs = append(attrs, slog.String("hash", hash.String()))
+					}
 
-				if !success {
+					logger.Error("Submitting tx failed", attrs...)
 					continue
 				}
 
-				logger.Debug("Submitting tx successful", "hash", hash.String())
+				if b.logSubmissions {
+					logger.Info("Tx submitted", "hash", hash.String(), "timestamp", submittedAt.Format(time.RFC3339Nano), "duration", time.Since(submittedAt).String(), "attempts", attempts)
+				} else {
+					logger.Debug("Submitting tx successful", "hash", hash.String())
+				}
 				b.utxoChannel <- TxOut{
 					Hash:     hash,
 					ValueSat: satoshis,
@@ -135,6 +258,7 @@ func (b *Broadcaster) Start(rateTxsPerSecond int64, limit time.Duration, logger
 				}
 
 				atomic.AddInt64(&b.totalTxs, 1)
+				b.metrics.TxSubmitted()
 			}
 		}
 	}()
@@ -144,6 +268,123 @@ func (b *Broadcaster) Start(rateTxsPerSecond int64, limit time.Duration, logger
 	return nil
 }
 
+// submit submits a self paying tx spending the utxo. A failed submission is retried up to maxAttempts times unless the
+// outputs of the tx are already in the utxo set. The hash of the tx and the number of attempts are returned together with
+// the error of the last attempt.
+func (b *Broadcaster) submit(txOut TxOut, logger *slog.Logger) (hash *chainhash.Hash, satoshis int64, attempts int, err error) {
+	for attempts = 1; ; attempts++ {
+		hash, satoshis, err = b.processor.SubmitSelfPayingSingleOutputTx(txOut)
+		if err == nil || errors.Is(err, context.Canceled) {
+			return hash, satoshis, attempts, err
+		}
+
+		if attempts == maxAttempts || strings.Contains(err.Error(), "Transaction outputs already in utxo set") {
+			return hash, satoshis, attempts, err
+		}
+
+		logger.Warn("Submission attempt failed", "utxo", fmt.Sprintf("%s:%d", txOut.Hash.String(), txOut.VOut), "attempt", attempts, "err", err)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// doubleSpend submits two txs spending the utxo to two different nodes
+func (b *Broadcaster) doubleSpend(txOut TxOut, logger *slog.Logger) {
+	ds, err := b.doubleSpender.SubmitDoubleSpend(txOut)
+	if err != nil {
+		logger.Error("Double spending failed", "hash", txOut.Hash.String(), "err", err)
+		return
+	}
+
+	b.doubleSpends.Add(1)
+
+	attrs := []any{
+		slog.String("utxo", ds.Utxo),
+		slog.String("timestamp", ds.SentAt.Format(time.RFC3339Nano)),
+	}
+	for i := range ds.Hashes {
+		attrs = append(attrs, slog.String(fmt.Sprintf("hash %d", i+1), ds.Hashes[i]), slog.String(fmt.Sprintf("target %d", i+1), ds.Targets[i]))
+		if ds.Errs[i] != nil {
+			attrs = append(attrs, slog.String(fmt.Sprintf("err %d", i+1), ds.Errs[i].Error()))
+		}
+	}
+
+	logger.Info("Double spend submitted", attrs...)
+}
+
+// SubmissionFailed records a tx whose submission has returned successfully, but which has not been accepted by the
+// node afterwards e.g. because it has not been requested by the node as P2P peer. The tx is counted as failed instead
+// of submitted.
+func (b *Broadcaster) SubmissionFailed(hash string, err error) {
+	atomic.AddInt64(&b.totalTxs, -1)
+	atomic.AddInt64(&b.failedTxs, 1)
+	b.metrics.TxFailed(err)
+
+	logger := b.logger.Load()
+	if logger == nil {
+		return
+	}
+
+	logger.Error("Submitting tx failed",
+		slog.String("hash", hash),
+		slog.String("timestamp", time.Now().Format(time.RFC3339Nano)),
+		slog.Bool("late", true),
+		slog.String("err", err.Error()),
+	)
+}
+
+// submitInterval returns the average time between two submitted txs at the given rate
+func submitInterval(rateTxsPerSecond int64) time.Duration {
+	return time.Second / time.Duration(rateTxsPerSecond)
+}
+
+// SetRate changes the rate of txs per second. The new rate takes effect after the next submitted tx.
+func (b *Broadcaster) SetRate(rateTxsPerSecond int64) error {
+	if rateTxsPerSecond <= 0 {
+		return fmt.Errorf("rate %d not valid - has to be greater than 0", rateTxsPerSecond)
+	}
+
+	b.rate.Store(rateTxsPerSecond)
+
+	return nil
+}
+
+// Pause stops submitting txs until Resume is called. The time limit is not extended by the paused time.
+func (b *Broadcaster) Pause() {
+	b.paused.Store(true)
+}
+
+func (b *Broadcaster) Resume() {
+	b.paused.Store(false)
+}
+
+// Status returns the current state of the broadcaster
+func (b *Broadcaster) Status() Status {
+	s := Status{
+		Rate:         b.rate.Load(),
+		Paused:       b.paused.Load(),
+		TotalTxs:     atomic.LoadInt64(&b.totalTxs),
+		FailedTxs:    atomic.LoadInt64(&b.failedTxs),
+		DoubleSpends: b.doubleSpends.Load(),
+		Utxos:        len(b.utxoChannel),
+	}
+
+	startedAt := b.startedAt.Load()
+	if startedAt != nil {
+		s.StartedAt = *startedAt
+	}
+
+	return s
+}
+
+// nextSubmitInterval returns the time until the next tx is submitted given the average submit interval
+func (b *Broadcaster) nextSubmitInterval(submitInterval time.Duration) time.Duration {
+	if b.arrival == ArrivalPoisson {
+		return time.Duration(b.rng.ExpFloat64() * float64(submitInterval))
+	}
+
+	return submitInterval
+}
+
 func (b *Broadcaster) Shutdown() {
 	b.cancelAll()
 
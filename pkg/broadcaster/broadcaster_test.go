@@ -0,0 +1,42 @@
+package broadcaster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitInterval(t *testing.T) {
+	tt := []struct {
+		name string
+		rate int64
+
+		expectedInterval time.Duration
+	}{
+		{
+			name: "low rate",
+			rate: 3,
+
+			expectedInterval: 333333333 * time.Nanosecond,
+		},
+		{
+			name: "rate above 500 txs/s",
+			rate: 800,
+
+			expectedInterval: 1250 * time.Microsecond,
+		},
+		{
+			name: "rate above 1000 txs/s",
+			rate: 4000,
+
+			expectedInterval: 250 * time.Microsecond,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedInterval, submitInterval(tc.rate))
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+const (
+	ActionWarn   = "warn"
+	ActionRefuse = "refuse"
+
+	MsgClockOffset = "Clock offset"
+
+	defaultSamples  = 5
+	defaultInterval = 1250 * time.Millisecond
+)
+
+type Client interface {
+	NodeTime() (time.Time, error)
+	GetNetworkInfo() (*node_client.GetNetworkInfoResult, error)
+	GetPeerInfo() ([]node_client.PeerInfo, error)
+	GetMiningInfo() (*node_client.GetMiningInfoResult, error)
+	GetBlockHash(blockHeight int64) (*string, error)
+	GetBlock(blockHash string) (*node_client.GetBlockVerboseResult, error)
+}
+
+// Measurement is the estimated offset of the local clock against the node and its peers
+type Measurement struct {
+	// NodeOffset is the clock of the node minus the local clock
+	NodeOffset time.Duration
+	// Uncertainty is the maximum error of the node offset
+	Uncertainty time.Duration
+	// NodeTimeOffset is the offset of the node against its peers as reported by getnetworkinfo
+	NodeTimeOffset time.Duration
+	// MaxPeerOffset is the largest offset of a peer against the node as reported by getpeerinfo
+	MaxPeerOffset time.Duration
+	// BestBlockAge is the local time minus the header time of the best block
+	BestBlockAge time.Duration
+}
+
+// NetworkOffset is the time of the network minus the local clock. Adding it to a local timestamp gives the time of
+// the network.
+func (m Measurement) NetworkOffset() time.Duration {
+	return m.NodeOffset + m.NodeTimeOffset
+}
+
+// Skew is the largest absolute offset of the local clock against the node or the network
+func (m Measurement) Skew() time.Duration {
+	return max(m.NodeOffset.Abs(), m.NetworkOffset().Abs())
+}
+
+// Attrs returns the attributes with which the measurement is logged
+func (m Measurement) Attrs() []any {
+	return []any{
+		slog.String("node offset", m.NodeOffset.String()),
+		slog.String("uncertainty", m.Uncertainty.String()),
+		slog.String("node time offset", m.NodeTimeOffset.String()),
+		slog.String("network offset", m.NetworkOffset().String()),
+		slog.String("max peer offset", m.MaxPeerOffset.String()),
+		slog.String("best block age", m.BestBlockAge.String()),
+	}
+}
+
+// Estimator estimates the offset of the local clock against a node
+type Estimator struct {
+	client   Client
+	samples  int
+	interval time.Duration
+	now      func() time.Time
+	sleep    func(d time.Duration)
+}
+
+type Option func(e *Estimator)
+
+// WithSamples sets the number of times the node time is sampled and the interval between the samples. As the node
+// time has a resolution of one second, samples at different fractions of a second narrow down the offset.
+func WithSamples(samples int, interval time.Duration) Option {
+	return func(e *Estimator) {
+		e.samples = samples
+		e.interval = interval
+	}
+}
+
+// WithClock sets the local clock
+func WithClock(now func() time.Time, sleep func(d time.Duration)) Option {
+	return func(e *Estimator) {
+		e.now = now
+		e.sleep = sleep
+	}
+}
+
+func New(client Client, opts ...Option) *Estimator {
+	e := &Estimator{
+		client:   client,
+		samples:  defaultSamples,
+		interval: defaultInterval,
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Measure estimates the offset of the local clock against the node and its peers
+func (e *Estimator) Measure() (Measurement, error) {
+	m := Measurement{}
+
+	var err error
+	m.NodeOffset, m.Uncertainty, err = e.nodeOffset()
+	if err != nil {
+		return Measurement{}, fmt.Errorf("failed to get node time: %w", err)
+	}
+
+	networkInfo, err := e.client.GetNetworkInfo()
+	if err != nil {
+		return Measurement{}, err
+	}
+	m.NodeTimeOffset = time.Duration(networkInfo.TimeOffset) * time.Second
+
+	peers, err := e.client.GetPeerInfo()
+	if err != nil {
+		return Measurement{}, err
+	}
+	for _, peer := range peers {
+		offset := time.Duration(peer.TimeOffset) * time.Second
+		if offset.Abs() > m.MaxPeerOffset.Abs() {
+			m.MaxPeerOffset = offset
+		}
+	}
+
+	info, err := e.client.GetMiningInfo()
+	if err != nil {
+		return Measurement{}, err
+	}
+	hash, err := e.client.GetBlockHash(info.Blocks)
+	if err != nil {
+		return Measurement{}, err
+	}
+	block, err := e.client.GetBlock(*hash)
+	if err != nil {
+		return Measurement{}, err
+	}
+	m.BestBlockAge = e.now().Sub(time.Unix(block.Time, 0))
+
+	return m, nil
+}
+
+// nodeOffset samples the node time. Each sample limits the offset to the interval between the node time minus the
+// local time after the request and the node time plus one second minus the local time before the request.
+// The intersection of the intervals of all samples is the estimated offset.
+func (e *Estimator) nodeOffset() (offset time.Duration, uncertainty time.Duration, err error) {
+	if e.samples <= 0 {
+		return 0, 0, errors.New("at least one sample is required")
+	}
+
+	var lower, upper time.Duration
+	for i := 0; i < e.samples; i++ {
+		if i > 0 {
+			e.sleep(e.interval)
+		}
+
+		before := e.now()
+		nodeTime, err := e.client.NodeTime()
+		if err != nil {
+			return 0, 0, err
+		}
+		after := e.now()
+
+		sampleLower := nodeTime.Sub(after)
+		sampleUpper := nodeTime.Add(time.Second).Sub(before)
+
+		if i == 0 {
+			lower, upper = sampleLower, sampleUpper
+			continue
+		}
+
+		if sampleLower > upper || sampleUpper < lower {
+			// The node clock has been adjusted between the samples - the latest sample is used
+			lower, upper = sampleLower, sampleUpper
+			continue
+		}
+
+		lower = max(lower, sampleLower)
+		upper = min(upper, sampleUpper)
+	}
+
+	return (lower + upper) / 2, (upper - lower) / 2, nil
+}
+
+// Check returns an error if the skew of the measurement exceeds the maximum skew and the action is to refuse to start.
+// Otherwise a warning is logged.
+func Check(m Measurement, maxSkew time.Duration, action string, logger *slog.Logger) error {
+	if m.MaxPeerOffset.Abs() > maxSkew {
+		logger.Warn("Clock of a peer of the node is skewed", "max peer offset", m.MaxPeerOffset.String(), "max skew", maxSkew.String())
+	}
+
+	if m.Skew() <= maxSkew {
+		return nil
+	}
+
+	if action == ActionRefuse {
+		return fmt.Errorf("clock skew %s exceeds maximum skew %s", m.Skew(), maxSkew)
+	}
+
+	logger.Warn("Clock skew exceeds maximum skew", append(m.Attrs(), "max skew", maxSkew.String())...)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package clock
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+type clientMock struct {
+	local      *time.Time
+	nodeOffset time.Duration
+}
+
+func (c *clientMock) NodeTime() (time.Time, error) {
+	// The request takes 20ms
+	*c.local = c.local.Add(10 * time.Millisecond)
+	nodeTime := c.local.Add(c.nodeOffset).Truncate(time.Second)
+	*c.local = c.local.Add(10 * time.Millisecond)
+
+	return nodeTime, nil
+}
+
+func (c *clientMock) GetNetworkInfo() (*node_client.GetNetworkInfoResult, error) {
+	return &node_client.GetNetworkInfoResult{TimeOffset: -2}, nil
+}
+
+func (c *clientMock) GetPeerInfo() ([]node_client.PeerInfo, error) {
+	return []node_client.PeerInfo{{TimeOffset: 1}, {TimeOffset: -4}, {TimeOffset: 2}}, nil
+}
+
+func (c *clientMock) GetMiningInfo() (*node_client.GetMiningInfoResult, error) {
+	return &node_client.GetMiningInfoResult{Blocks: 101}, nil
+}
+
+func (c *clientMock) GetBlockHash(_ int64) (*string, error) {
+	hash := "aa"
+	return &hash, nil
+}
+
+func (c *clientMock) GetBlock(_ string) (*node_client.GetBlockVerboseResult, error) {
+	return &node_client.GetBlockVerboseResult{Time: c.local.Add(-time.Minute).Unix()}, nil
+}
+
+func TestEstimator_Measure(t *testing.T) {
+	local := time.Date(2024, 12, 11, 13, 30, 0, 330_000_000, time.UTC)
+	client := &clientMock{local: &local, nodeOffset: 3400 * time.Millisecond}
+
+	now := func() time.Time { return local }
+	sleep := func(d time.Duration) { local = local.Add(d) }
+
+	m, err := New(client, WithClock(now, sleep)).Measure()
+	require.NoError(t, err)
+
+	require.InDelta(t, float64(3400*time.Millisecond), float64(m.NodeOffset), float64(m.Uncertainty))
+	require.Less(t, m.Uncertainty, 200*time.Millisecond)
+	require.Equal(t, -2*time.Second, m.NodeTimeOffset)
+	require.InDelta(t, float64(1400*time.Millisecond), float64(m.NetworkOffset()), float64(m.Uncertainty))
+	require.Equal(t, -4*time.Second, m.MaxPeerOffset)
+	require.InDelta(t, float64(time.Minute), float64(m.BestBlockAge), float64(time.Second))
+
+	require.NoError(t, Check(m, 5*time.Second, ActionRefuse, slog.Default()))
+	require.NoError(t, Check(m, time.Second, ActionWarn, slog.Default()))
+	require.ErrorContains(t, Check(m, time.Second, ActionRefuse, slog.Default()), "exceeds maximum skew 1s")
+}
@@ -0,0 +1,133 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Agent runs one node of the scenario distributed by a coordinator
+type Agent struct {
+	url    string
+	node   string
+	client *http.Client
+}
+
+// NewAgent creates an agent for the node which connects to the coordinator at the given URL
+func NewAgent(url string, node string) *Agent {
+	return &Agent{
+		url:    strings.TrimSuffix(url, "/"),
+		node:   node,
+		client: &http.Client{},
+	}
+}
+
+// Register registers the agent at the coordinator and returns the scenario file
+func (a *Agent) Register(ctx context.Context) ([]byte, error) {
+	body, err := json.Marshal(RegisterRequest{Node: a.node})
+	if err != nil {
+		return nil, err
+	}
+
+	var response RegisterResponse
+	_, err = a.do(ctx, http.MethodPost, "/register", bytes.NewReader(body), &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register: %w", err)
+	}
+
+	return response.Scenario, nil
+}
+
+// Ready reports to the coordinator that the utxos have been prepared
+func (a *Agent) Ready(ctx context.Context) error {
+	_, err := a.do(ctx, http.MethodPost, "/ready/"+a.node, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to report ready: %w", err)
+	}
+
+	return nil
+}
+
+// AwaitStart waits until all agents are ready and returns the start time of the run in the local clock
+func (a *Agent) AwaitStart(ctx context.Context) (time.Time, error) {
+	for {
+		var response StartResponse
+		status, err := a.do(ctx, http.MethodGet, "/start/"+a.node, nil, &response)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to get start time: %w", err)
+		}
+
+		if status == http.StatusOK {
+			return time.Now().Add(response.StartIn), nil
+		}
+	}
+}
+
+// UploadResults uploads the output file to the coordinator
+func (a *Agent) UploadResults(ctx context.Context, r io.Reader) error {
+	_, err := a.do(ctx, http.MethodPost, "/results/"+a.node, r, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upload results: %w", err)
+	}
+
+	return nil
+}
+
+// URL returns the URL of the coordinator
+func (a *Agent) URL() string {
+	return a.url
+}
+
+// Failed reports to the coordinator that the run has failed and uploads the output written until the failure
+func (a *Agent) Failed(ctx context.Context, runErr error, output []byte) error {
+	body, err := json.Marshal(FailedRequest{Error: runErr.Error(), Output: output})
+	if err != nil {
+		return err
+	}
+
+	_, err = a.do(ctx, http.MethodPost, "/failed/"+a.node, bytes.NewReader(body), nil)
+	if err != nil {
+		return fmt.Errorf("failed to report failure: %w", err)
+	}
+
+	return nil
+}
+
+// do sends the request and decodes the JSON response into v if the response has content
+func (a *Agent) do(ctx context.Context, method string, path string, body io.Reader, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.url+path, body)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e errorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+
+		return resp.StatusCode, errors.New(e.Error)
+	}
+
+	if v != nil && resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(v)
+		if err != nil {
+			return resp.StatusCode, err
+		}
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,381 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStartDelay = 10 * time.Second
+
+	// maxPoll is the maximum time for which a request for the start time is held open before the agent has to ask again
+	maxPoll = 30 * time.Second
+)
+
+// Coordinator distributes a scenario to the agents of its nodes, starts the run on all agents at the same time once
+// all agents have prepared their utxos and collects the output files at the end
+type Coordinator struct {
+	scenario   []byte
+	nodes      []string
+	results    func(node string, r io.Reader) error
+	startDelay time.Duration
+	logger     *slog.Logger
+	mux        *http.ServeMux
+
+	mu         sync.Mutex
+	registered map[string]struct{}
+	ready      map[string]struct{}
+	collected  map[string]struct{}
+	failed     map[string]string
+	startAt    time.Time
+	started    chan struct{}
+	aborted    chan struct{}
+	done       chan struct{}
+}
+
+// RegisterRequest is sent by an agent to register for a node of the scenario
+type RegisterRequest struct {
+	Node string `json:"node"`
+}
+
+// RegisterResponse contains the scenario file which the agent runs
+type RegisterResponse struct {
+	Scenario []byte `json:"scenario"`
+}
+
+// StartResponse contains the time at which the run starts. Agents should start after StartIn from receiving the
+// response so that the start does not depend on the clocks of the agents.
+type StartResponse struct {
+	StartAt time.Time     `json:"start_at"`
+	StartIn time.Duration `json:"start_in"`
+}
+
+// FailedRequest is sent by an agent whose run has failed. The output written until the failure is collected as result
+// of the node.
+type FailedRequest struct {
+	Error  string `json:"error"`
+	Output []byte `json:"output"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type Option func(c *Coordinator)
+
+// WithStartDelay sets the time between all agents being ready and the start of the run. Default is 10s.
+func WithStartDelay(d time.Duration) Option {
+	return func(c *Coordinator) {
+		c.startDelay = d
+	}
+}
+
+// New creates a coordinator for the scenario with the given nodes. The results function is called with the output file
+// uploaded by the agent of each node.
+func New(scenario []byte, nodes []string, results func(node string, r io.Reader) error, logger *slog.Logger, opts ...Option) *Coordinator {
+	c := &Coordinator{
+		scenario:   scenario,
+		nodes:      nodes,
+		results:    results,
+		startDelay: defaultStartDelay,
+		logger:     logger.With(slog.String("service", "coordinator")),
+		mux:        http.NewServeMux(),
+		registered: map[string]struct{}{},
+		ready:      map[string]struct{}{},
+		collected:  map[string]struct{}{},
+		failed:     map[string]string{},
+		started:    make(chan struct{}),
+		aborted:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.mux.HandleFunc("POST /register", c.handleRegister)
+	c.mux.HandleFunc("POST /ready/{node}", c.handleReady)
+	c.mux.HandleFunc("GET /start/{node}", c.handleStart)
+	c.mux.HandleFunc("POST /results/{node}", c.handleResults)
+	c.mux.HandleFunc("POST /failed/{node}", c.handleFailed)
+
+	return c
+}
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+// Serve serves the coordinator on the given address until the context is canceled
+func (c *Coordinator) Serve(ctx context.Context, addr string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           c,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		c.logger.Info("Serving coordinator", "addr", addr, "nodes", len(c.nodes))
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.logger.Error("Failed to serve coordinator", "err", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+}
+
+// Done is closed once the results of all nodes have been collected or their runs have failed
+func (c *Coordinator) Done() <-chan struct{} {
+	return c.done
+}
+
+// Started is closed once all agents are ready and the start time has been set
+func (c *Coordinator) Started() <-chan struct{} {
+	return c.started
+}
+
+// StartAt returns the start time of the run which is zero until all agents are ready
+func (c *Coordinator) StartAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.startAt
+}
+
+// Failed returns the error of each node whose run has failed
+func (c *Coordinator) Failed() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return maps.Clone(c.failed)
+}
+
+// Missing returns the nodes from which neither results nor a failure have been received
+func (c *Coordinator) Missing() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var missing []string
+	for _, node := range c.nodes {
+		_, collected := c.collected[node]
+		_, failed := c.failed[node]
+		if !collected && !failed {
+			missing = append(missing, node)
+		}
+	}
+
+	return missing
+}
+
+func writeResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeResponse(w, status, errorResponse{Error: err.Error()})
+}
+
+func (c *Coordinator) knownNode(w http.ResponseWriter, node string) bool {
+	if !slices.Contains(c.nodes, node) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("node %s not found in scenario", node))
+		return false
+	}
+
+	return true
+}
+
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var request RegisterRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !c.knownNode(w, request.Node) {
+		return
+	}
+
+	c.mu.Lock()
+	c.registered[request.Node] = struct{}{}
+	registered := len(c.registered)
+	c.mu.Unlock()
+
+	c.logger.Info("Agent registered", "node", request.Node, "remote", r.RemoteAddr, "registered", registered, "nodes", len(c.nodes))
+
+	writeResponse(w, http.StatusOK, RegisterResponse{Scenario: c.scenario})
+}
+
+func (c *Coordinator) handleReady(w http.ResponseWriter, r *http.Request) {
+	node := r.PathValue("node")
+	if !c.knownNode(w, node) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.registered[node]; !found {
+		writeError(w, http.StatusConflict, fmt.Errorf("node %s not registered", node))
+		return
+	}
+
+	c.ready[node] = struct{}{}
+	c.logger.Info("Agent ready", "node", node, "ready", len(c.ready), "nodes", len(c.nodes))
+
+	if len(c.ready) == len(c.nodes) && c.startAt.IsZero() {
+		c.startAt = time.Now().Add(c.startDelay)
+		close(c.started)
+		c.logger.Info("All agents ready", "start-at", c.startAt.UTC().Format(time.RFC3339Nano))
+	}
+
+	writeResponse(w, http.StatusAccepted, struct{}{})
+}
+
+// handleStart responds with the start time once all agents are ready. If not all agents are ready within maxPoll, it
+// responds with no content and the agent has to ask again.
+func (c *Coordinator) handleStart(w http.ResponseWriter, r *http.Request) {
+	if !c.knownNode(w, r.PathValue("node")) {
+		return
+	}
+
+	timer := time.NewTimer(maxPoll)
+	defer timer.Stop()
+
+	select {
+	case <-c.started:
+	case <-c.aborted:
+		c.mu.Lock()
+		err := c.abortErr()
+		c.mu.Unlock()
+
+		writeError(w, http.StatusConflict, err)
+		return
+	case <-timer.C:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	c.mu.Lock()
+	startAt := c.startAt
+	c.mu.Unlock()
+
+	writeResponse(w, http.StatusOK, StartResponse{StartAt: startAt, StartIn: time.Until(startAt)})
+}
+
+func (c *Coordinator) handleResults(w http.ResponseWriter, r *http.Request) {
+	node := r.PathValue("node")
+	if !c.knownNode(w, node) {
+		return
+	}
+
+	err := c.results(node, r.Body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.collected[node] = struct{}{}
+	c.logger.Info("Results collected", "node", node, "collected", len(c.collected), "nodes", len(c.nodes))
+	c.checkDone()
+
+	writeResponse(w, http.StatusAccepted, struct{}{})
+}
+
+// handleFailed records the failure of the run of a node and collects its partial output. If the run has not started
+// yet, it is aborted on all agents as the start would wait for the failed node forever.
+func (c *Coordinator) handleFailed(w http.ResponseWriter, r *http.Request) {
+	node := r.PathValue("node")
+	if !c.knownNode(w, node) {
+		return
+	}
+
+	var request FailedRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(request.Output) > 0 {
+		err = c.results(node, bytes.NewReader(request.Output))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failed[node] = request.Error
+	c.logger.Error("Agent failed", "node", node, "err", request.Error, "output bytes", len(request.Output))
+
+	if c.startAt.IsZero() {
+		select {
+		case <-c.aborted:
+		default:
+			close(c.aborted)
+			c.logger.Warn("Run aborted before start", "node", node)
+		}
+	}
+
+	c.checkDone()
+
+	writeResponse(w, http.StatusAccepted, struct{}{})
+}
+
+// abortErr returns the reason for which the run has been aborted. The mutex has to be held by the caller.
+func (c *Coordinator) abortErr() error {
+	var errs []error
+	for _, node := range c.nodes {
+		if reason, found := c.failed[node]; found {
+			errs = append(errs, fmt.Errorf("run aborted - node %s failed: %s", node, reason))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkDone closes done once every node has either uploaded its results or failed. The mutex has to be held by the
+// caller.
+func (c *Coordinator) checkDone() {
+	for _, node := range c.nodes {
+		_, collected := c.collected[node]
+		_, failed := c.failed[node]
+		if !collected && !failed {
+			return
+		}
+	}
+
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
@@ -0,0 +1,156 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoordinator_Loopback(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3"}
+	scenario := []byte("name: loopback")
+
+	var mu sync.Mutex
+	results := map[string]string{}
+	collect := func(node string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		results[node] = string(data)
+		mu.Unlock()
+		return nil
+	}
+
+	c := New(scenario, nodes, collect, slog.Default(), WithStartDelay(200*time.Millisecond))
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := NewAgent(server.URL, "node4").Register(ctx)
+	require.ErrorContains(t, err, "node node4 not found in scenario")
+
+	startTimes := make([]time.Time, len(nodes))
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			errs[i] = func() error {
+				agent := NewAgent(server.URL, node)
+
+				received, err := agent.Register(ctx)
+				if err != nil {
+					return err
+				}
+				if string(received) != string(scenario) {
+					return fmt.Errorf("unexpected scenario %q", received)
+				}
+
+				// The agents get ready at different times
+				time.Sleep(time.Duration(i) * 50 * time.Millisecond)
+				err = agent.Ready(ctx)
+				if err != nil {
+					return err
+				}
+
+				startTimes[i], err = agent.AwaitStart(ctx)
+				if err != nil {
+					return err
+				}
+
+				return agent.UploadResults(ctx, strings.NewReader("output of "+node))
+			}()
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		require.NoError(t, err, nodes[i])
+	}
+
+	select {
+	case <-c.Done():
+	case <-ctx.Done():
+		t.Fatal("results not collected")
+	}
+
+	for _, startAt := range startTimes[1:] {
+		require.WithinDuration(t, startTimes[0], startAt, 50*time.Millisecond)
+	}
+	require.Equal(t, "output of node2", results["node2"])
+	require.Len(t, results, 3)
+	require.Empty(t, c.Failed())
+}
+
+func TestCoordinator_Failed(t *testing.T) {
+	nodes := []string{"node1", "node2"}
+
+	var mu sync.Mutex
+	results := map[string]string{}
+	collect := func(node string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		results[node] = string(data)
+		mu.Unlock()
+		return nil
+	}
+
+	c := New([]byte("name: failed"), nodes, collect, slog.Default(), WithStartDelay(200*time.Millisecond))
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	agent1 := NewAgent(server.URL, "node1")
+	agent2 := NewAgent(server.URL, "node2")
+
+	_, err := agent1.Register(ctx)
+	require.NoError(t, err)
+	_, err = agent2.Register(ctx)
+	require.NoError(t, err)
+	require.NoError(t, agent1.Ready(ctx))
+
+	awaitErr := make(chan error, 1)
+	go func() {
+		_, err := agent1.AwaitStart(ctx)
+		awaitErr <- err
+	}()
+
+	// node2 fails before it gets ready, so the run is aborted on node1 which would wait for the start forever
+	require.NoError(t, agent2.Failed(ctx, errors.New("node not reachable"), []byte("partial output of node2")))
+	require.Equal(t, []string{"node1"}, c.Missing())
+
+	err = <-awaitErr
+	require.ErrorContains(t, err, "run aborted - node node2 failed: node not reachable")
+
+	require.NoError(t, agent1.Failed(ctx, err, nil))
+
+	select {
+	case <-c.Done():
+	case <-ctx.Done():
+		t.Fatal("failures not collected")
+	}
+
+	require.Equal(t, map[string]string{"node2": "partial output of node2"}, results)
+	require.Len(t, c.Failed(), 2)
+	require.Empty(t, c.Missing())
+}
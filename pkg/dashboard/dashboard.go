@@ -0,0 +1,327 @@
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	rateHistoryMax = 60
+	gaugeWidth     = 40
+
+	clearScreen = "\033[H\033[2J"
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+type block struct {
+	time   time.Time
+	hash   string
+	height int64
+	size   uint64
+	txs    uint64
+	delta  time.Duration
+}
+
+type errorEntry struct {
+	time    time.Time
+	service string
+	msg     string
+	err     string
+}
+
+// Dashboard renders live panels in the terminal from the records logged by broadcaster, listener and miner
+type Dashboard struct {
+	mu sync.Mutex
+
+	maxBlocks int
+	maxErrors int
+
+	startedAt    time.Time
+	targetRate   float64
+	rates        []float64
+	lastTotal    int64
+	lastStatsAt  time.Time
+	totalTxs     int64
+	utxos        int64
+	utxoCapacity int64
+	mempoolTxs   int64
+	nextBlockAt  time.Time
+	blocks       []block
+	errors       []errorEntry
+}
+
+type Option func(d *Dashboard)
+
+// WithMaxBlocks sets the number of blocks which are shown - default is 10
+func WithMaxBlocks(n int) Option {
+	return func(d *Dashboard) {
+		d.maxBlocks = n
+	}
+}
+
+// WithMaxErrors sets the number of errors which are shown - default is 5
+func WithMaxErrors(n int) Option {
+	return func(d *Dashboard) {
+		d.maxErrors = n
+	}
+}
+
+func New(opts ...Option) *Dashboard {
+	d := &Dashboard{
+		maxBlocks: 10,
+		maxErrors: 5,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Handler returns a slog handler which feeds the logged records into the dashboard
+func (d *Dashboard) Handler() slog.Handler {
+	return &handler{dashboard: d}
+}
+
+type handler struct {
+	dashboard *Dashboard
+	attrs     []slog.Attr
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]slog.Value, len(h.attrs)+r.NumAttrs())
+	for _, attr := range h.attrs {
+		attrs[attr.Key] = attr.Value.Resolve()
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value.Resolve()
+		return true
+	})
+
+	h.dashboard.record(r.Time, r.Level, r.Message, attrs)
+
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	combined := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	combined = append(combined, h.attrs...)
+	combined = append(combined, attrs...)
+
+	return &handler{dashboard: h.dashboard, attrs: combined}
+}
+
+// WithGroup is not supported as none of the components log groups - attributes of groups are treated as top level attributes
+func (h *handler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+func number(attrs map[string]slog.Value, key string) float64 {
+	value, found := attrs[key]
+	if !found {
+		return 0
+	}
+
+	switch value.Kind() {
+	case slog.KindInt64:
+		return float64(value.Int64())
+	case slog.KindUint64:
+		return float64(value.Uint64())
+	case slog.KindFloat64:
+		return value.Float64()
+	default:
+		f, _ := strconv.ParseFloat(value.String(), 64)
+		return f
+	}
+}
+
+func duration(attrs map[string]slog.Value, key string) time.Duration {
+	value, found := attrs[key]
+	if !found {
+		return 0
+	}
+
+	if value.Kind() == slog.KindDuration {
+		return value.Duration()
+	}
+
+	d, _ := time.ParseDuration(value.String())
+	return d
+}
+
+func text(attrs map[string]slog.Value, key string) string {
+	value, found := attrs[key]
+	if !found {
+		return ""
+	}
+
+	return value.String()
+}
+
+func (d *Dashboard) record(t time.Time, level slog.Level, msg string, attrs map[string]slog.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	service := text(attrs, "service")
+
+	if level >= slog.LevelError {
+		d.errors = append(d.errors, errorEntry{time: t, service: service, msg: msg, err: text(attrs, "err")})
+		if len(d.errors) > d.maxErrors {
+			d.errors = d.errors[len(d.errors)-d.maxErrors:]
+		}
+		return
+	}
+
+	switch {
+	case service == "broadcaster" && msg == "Starting broadcasting":
+		d.startedAt = t
+		d.lastStatsAt = t
+		d.targetRate = number(attrs, "rate")
+		d.utxoCapacity = int64(number(attrs, "outputs"))
+		d.utxos = d.utxoCapacity
+	case service == "broadcaster" && msg == "Stats":
+		total := int64(number(attrs, "total"))
+		if elapsed := t.Sub(d.lastStatsAt).Seconds(); elapsed > 0 {
+			d.rates = append(d.rates, float64(total-d.lastTotal)/elapsed)
+			if len(d.rates) > rateHistoryMax {
+				d.rates = d.rates[len(d.rates)-rateHistoryMax:]
+			}
+		}
+		d.lastTotal = total
+		d.lastStatsAt = t
+		d.totalTxs = total
+		d.utxos = int64(number(attrs, "utxos"))
+		d.mempoolTxs = int64(number(attrs, "mempool txs"))
+		if d.utxos > d.utxoCapacity {
+			d.utxoCapacity = d.utxos
+		}
+	case service == "listener" && msg == "Block":
+		d.blocks = append(d.blocks, block{
+			time:   t,
+			hash:   text(attrs, "hash"),
+			height: int64(number(attrs, "height")),
+			size:   uint64(number(attrs, "size")),
+			txs:    uint64(number(attrs, "txs")),
+			delta:  duration(attrs, "delta"),
+		})
+		if len(d.blocks) > d.maxBlocks {
+			d.blocks = d.blocks[len(d.blocks)-d.maxBlocks:]
+		}
+	case service == "miner" && msg == "Block found":
+		d.nextBlockAt = t.Add(duration(attrs, "next block"))
+	}
+}
+
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	maxValue := values[0]
+	for _, v := range values {
+		maxValue = max(maxValue, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		index := 0
+		if maxValue > 0 {
+			index = int(v / maxValue * float64(len(sparks)-1))
+		}
+		index = max(0, min(index, len(sparks)-1))
+		b.WriteRune(sparks[index])
+	}
+
+	return b.String()
+}
+
+func gauge(value int64, capacity int64) string {
+	filled := 0
+	if capacity > 0 {
+		filled = int(float64(value) / float64(capacity) * gaugeWidth)
+	}
+	filled = max(0, min(filled, gaugeWidth))
+
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", gaugeWidth-filled) + "]"
+}
+
+// Render writes the current state of all panels
+func (d *Dashboard) Render(w io.Writer, now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elapsed := "waiting to start"
+	if !d.startedAt.IsZero() {
+		elapsed = now.Sub(d.startedAt).Round(time.Second).String()
+	}
+	fmt.Fprintf(w, "Node analysis - %s - elapsed %s\n\n", now.Format(time.TimeOnly), elapsed)
+
+	currentRate := 0.0
+	if len(d.rates) > 0 {
+		currentRate = d.rates[len(d.rates)-1]
+	}
+	fmt.Fprintf(w, "Tx rate    %8.2f txs/s (target %.0f, total %d)\n", currentRate, d.targetRate, d.totalTxs)
+	fmt.Fprintf(w, "           %s\n", sparkline(d.rates))
+	fmt.Fprintf(w, "UTXO pool  %s %d/%d\n", gauge(d.utxos, d.utxoCapacity), d.utxos, d.utxoCapacity)
+	fmt.Fprintf(w, "Mempool    %d txs\n", d.mempoolTxs)
+
+	nextBlock := "-"
+	if !d.nextBlockAt.IsZero() {
+		nextBlock = max(d.nextBlockAt.Sub(now), 0).Round(time.Second).String()
+	}
+	fmt.Fprintf(w, "Next block %s\n\n", nextBlock)
+
+	fmt.Fprintf(w, "Last %d blocks\n", d.maxBlocks)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  time\theight\thash\tsize [bytes]\ttxs\tdelta\n")
+	for i := len(d.blocks) - 1; i >= 0; i-- {
+		b := d.blocks[i]
+		fmt.Fprintf(tw, "  %s\t%d\t%s\t%d\t%d\t%s\n", b.time.Format(time.TimeOnly), b.height, b.hash, b.size, b.txs, b.delta.Round(time.Millisecond))
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nRecent errors\n")
+	for i := len(d.errors) - 1; i >= 0; i-- {
+		e := d.errors[i]
+		fmt.Fprintf(w, "  %s %s: %s %s\n", e.time.Format(time.TimeOnly), e.service, e.msg, e.err)
+	}
+
+	return nil
+}
+
+// Run redraws the dashboard in the given interval until the context is canceled
+func (d *Dashboard) Run(ctx context.Context, w io.Writer, refresh time.Duration) {
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	var frame bytes.Buffer
+	for {
+		frame.Reset()
+		frame.WriteString(clearScreen)
+		_ = d.Render(&frame, time.Now())
+		_, _ = w.Write(frame.Bytes())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package dashboard
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard_Render(t *testing.T) {
+	d := New(WithMaxBlocks(2))
+	logger := slog.New(d.Handler())
+
+	broadcasterLogger := logger.With(slog.String("service", "broadcaster"))
+	broadcasterLogger.Info("Starting broadcasting", "outputs", 100, "rate", int64(10))
+	broadcasterLogger.Info("Stats", slog.Int64("total", 0), slog.Int("utxos", 50), slog.Uint64("mempool txs", 1234))
+	broadcasterLogger.Error("Submitting tx failed", "hash", "00", "err", errors.New("missing-inputs"))
+
+	listenerLogger := logger.With(slog.String("service", "listener"))
+	for _, hash := range []string{"aa", "bb", "cc"} {
+		listenerLogger.Info("Block", "hash", hash, "delta", "10s", "txs", uint64(60), "size", uint64(12000), "height", int64(101))
+	}
+
+	logger.With(slog.String("service", "miner")).Info("Block found", "hash", "cc", slog.String("next block", "1h0m0s"))
+
+	var buf bytes.Buffer
+	err := d.Render(&buf, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	output := buf.String()
+	require.Contains(t, output, "[####################--------------------] 50/100")
+	require.Contains(t, output, "Mempool    1234 txs")
+	require.Contains(t, output, "Submitting tx failed missing-inputs")
+	require.Contains(t, output, "Next block 59m0s")
+	require.Contains(t, output, "cc")
+	require.NotContains(t, output, "aa")
+}
+
+func TestSparkline(t *testing.T) {
+	require.Equal(t, "▁▄█", sparkline([]float64{0, 5, 10}))
+	require.Equal(t, "▁▁", sparkline([]float64{0, 0}))
+}
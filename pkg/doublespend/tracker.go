@@ -0,0 +1,348 @@
+package doublespend
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+const (
+	// TopicInvalidTx and TopicDiscardedFromMempool are the ZMQ topics by which BSV nodes notify about rejected txs and
+	// txs removed from the mempool because of a conflicting tx in a block
+	TopicInvalidTx            = "invalidtx"
+	TopicDiscardedFromMempool = "discardedfrommempool"
+
+	MsgDoubleSpendNotification = "Double spend notification"
+	MsgDoubleSpendResolved     = "Double spend resolved"
+	MsgDoubleSpendUnresolved   = "Double spend unresolved"
+
+	defaultInterval = time.Second
+)
+
+var Topics = []string{TopicInvalidTx, TopicDiscardedFromMempool}
+
+type Chain interface {
+	GetMiningInfo() (*node_client.GetMiningInfoResult, error)
+	GetTxOut(txHash string, index uint32, mempool bool) (*node_client.GetTxOutResult, error)
+}
+
+// Stats are the double spends since the start
+type Stats struct {
+	// Submitted is the number of double spends of which at least one tx has been accepted
+	Submitted int64 `json:"submitted"`
+	// Resolved is the number of double spends of which one tx has been mined
+	Resolved int64 `json:"resolved"`
+	// Notified is the number of double spends for which the node has sent a notification
+	Notified int64 `json:"notified"`
+	// Duration is the sum of the times from the submission until one tx has been mined of the resolved double spends
+	Duration time.Duration `json:"duration"`
+	// Wins is the number of resolved double spends per target whose tx has been mined
+	Wins map[string]int64 `json:"wins"`
+}
+
+type conflict struct {
+	ds       broadcaster.DoubleSpend
+	notified []string
+}
+
+// notification is the message of the invalidtx and discardedfrommempool topics. Collided with is a list for invalidtx
+// and a single tx for discardedfrommempool.
+type notification struct {
+	TxID                      string          `json:"txid"`
+	Reason                    string          `json:"reason"`
+	RejectionReason           string          `json:"rejectionReason"`
+	IsDoubleSpendDetected     bool            `json:"isDoubleSpendDetected"`
+	IsMempoolConflictDetected bool            `json:"isMempoolConflictDetected"`
+	CollidedWith              json.RawMessage `json:"collidedWith"`
+}
+
+type collidedTx struct {
+	TxID string `json:"txid"`
+}
+
+// Tracker submits double spends and records for each of them which tx has been mined, how long the conflict persisted
+// and whether the node has sent a notification about it
+type Tracker struct {
+	spender  broadcaster.DoubleSpender
+	chain    Chain
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	conflicts []*conflict
+	// byHash maps the hashes of both txs of the open conflicts to the conflict
+	byHash map[string]*conflict
+	blocks int64
+	stats  Stats
+	wg     sync.WaitGroup
+}
+
+type Option func(t *Tracker)
+
+// WithInterval sets the interval in which the node is checked for new blocks - default is 1s
+func WithInterval(interval time.Duration) Option {
+	return func(t *Tracker) {
+		t.interval = interval
+	}
+}
+
+func New(spender broadcaster.DoubleSpender, chain Chain, opts ...Option) *Tracker {
+	t := &Tracker{
+		spender:  spender,
+		chain:    chain,
+		interval: defaultInterval,
+		logger:   slog.Default(),
+		byHash:   make(map[string]*conflict),
+		stats:    Stats{Wins: make(map[string]int64)},
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// SubmitDoubleSpend submits the double spend and tracks it if at least one of the txs has been accepted
+func (t *Tracker) SubmitDoubleSpend(txOut broadcaster.TxOut) (broadcaster.DoubleSpend, error) {
+	ds, err := t.spender.SubmitDoubleSpend(txOut)
+	if err != nil || !ds.Accepted() {
+		return ds, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := &conflict{ds: ds}
+	t.conflicts = append(t.conflicts, c)
+	for _, hash := range ds.Hashes {
+		t.byHash[hash] = c
+	}
+	t.stats.Submitted++
+
+	return ds, nil
+}
+
+// Start checks the open conflicts on each new block and records the notifications of the node until the context is
+// canceled. The notifications are the messages of the topics in Topics and may be nil if the node does not send them.
+// Once the context is canceled, the conflicts which are still open are logged.
+func (t *Tracker) Start(ctx context.Context, notifications <-chan []string, logger *slog.Logger) {
+	t.logger = logger.With(slog.String("service", "doublespend"))
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				t.resolve()
+				t.logUnresolved()
+				return
+			case msg := <-notifications:
+				t.notify(msg, time.Now())
+			case <-ticker.C:
+				info, err := t.chain.GetMiningInfo()
+				if err != nil {
+					t.logger.Error("Failed to get mining info", "err", err)
+					continue
+				}
+
+				if info.Blocks == t.blocks {
+					continue
+				}
+				t.blocks = info.Blocks
+
+				t.resolve()
+			}
+		}
+	}()
+}
+
+// Wait waits until the open conflicts have been logged after the context has been canceled
+func (t *Tracker) Wait() {
+	t.wg.Wait()
+}
+
+// Stats returns the double spends since the start
+func (t *Tracker) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stats
+	s.Wins = make(map[string]int64, len(t.stats.Wins))
+	for target, wins := range t.stats.Wins {
+		s.Wins[target] = wins
+	}
+
+	return s
+}
+
+// resolve checks for each open conflict whether one of the txs has been mined
+func (t *Tracker) resolve() {
+	t.mu.Lock()
+	open := slices.Clone(t.conflicts)
+	t.mu.Unlock()
+
+	for _, c := range open {
+		winner := -1
+		for i, hash := range c.ds.Hashes {
+			// The txs have a single output which is not spent again
+			txOut, err := t.chain.GetTxOut(hash, 0, false)
+			if err != nil {
+				t.logger.Error("Failed to get tx out", "hash", hash, "err", err)
+				continue
+			}
+
+			if txOut != nil && txOut.Confirmations > 0 {
+				winner = i
+				break
+			}
+		}
+
+		if winner < 0 {
+			continue
+		}
+
+		duration := time.Since(c.ds.SentAt)
+		loser := 1 - winner
+
+		t.mu.Lock()
+		t.remove(c)
+		t.stats.Resolved++
+		t.stats.Duration += duration
+		t.stats.Wins[c.ds.Targets[winner]]++
+		notified := slices.Clone(c.notified)
+		t.mu.Unlock()
+
+		t.logger.Info(MsgDoubleSpendResolved,
+			slog.String("utxo", c.ds.Utxo),
+			slog.String("hash", c.ds.Hashes[winner]),
+			slog.String("target", c.ds.Targets[winner]),
+			slog.String("conflicting hash", c.ds.Hashes[loser]),
+			slog.String("conflicting target", c.ds.Targets[loser]),
+			slog.Bool("conflicting accepted", c.ds.Errs[loser] == nil),
+			slog.String("duration", duration.Round(time.Millisecond).String()),
+			slog.String("notified", strings.Join(notified, ",")),
+		)
+	}
+}
+
+// remove removes the conflict from the open conflicts. The mutex has to be held by the caller.
+func (t *Tracker) remove(c *conflict) {
+	t.conflicts = slices.DeleteFunc(t.conflicts, func(open *conflict) bool { return open == c })
+	for _, hash := range c.ds.Hashes {
+		delete(t.byHash, hash)
+	}
+}
+
+// notify records the notification if it concerns one of the open conflicts
+func (t *Tracker) notify(msg []string, at time.Time) {
+	if len(msg) < 2 {
+		return
+	}
+	topic := msg[0]
+
+	data, err := hex.DecodeString(msg[1])
+	if err != nil {
+		t.logger.Error("Failed to decode notification", "topic", topic, "err", err)
+		return
+	}
+
+	var n notification
+	err = json.Unmarshal(data, &n)
+	if err != nil {
+		t.logger.Error("Failed to decode notification", "topic", topic, "err", err)
+		return
+	}
+
+	if topic == TopicInvalidTx && !n.IsDoubleSpendDetected && !n.IsMempoolConflictDetected {
+		return
+	}
+
+	hashes := []string{n.TxID}
+	for _, collided := range n.collidedWith() {
+		hashes = append(hashes, collided.TxID)
+	}
+
+	t.mu.Lock()
+	var c *conflict
+	for _, hash := range hashes {
+		c = t.byHash[hash]
+		if c != nil {
+			break
+		}
+	}
+	if c == nil {
+		t.mu.Unlock()
+		return
+	}
+
+	if len(c.notified) == 0 {
+		t.stats.Notified++
+	}
+	if !slices.Contains(c.notified, topic) {
+		c.notified = append(c.notified, topic)
+	}
+	t.mu.Unlock()
+
+	reason := n.Reason
+	if reason == "" {
+		reason = n.RejectionReason
+	}
+
+	t.logger.Info(MsgDoubleSpendNotification,
+		slog.String("topic", topic),
+		slog.String("utxo", c.ds.Utxo),
+		slog.String("hash", n.TxID),
+		slog.String("reason", reason),
+		slog.Bool("double spend detected", n.IsDoubleSpendDetected),
+		slog.Bool("mempool conflict detected", n.IsMempoolConflictDetected),
+		slog.String("timestamp", at.Format(time.RFC3339Nano)),
+	)
+}
+
+// logUnresolved logs the conflicts of which no tx has been mined
+func (t *Tracker) logUnresolved() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, c := range t.conflicts {
+		t.logger.Warn(MsgDoubleSpendUnresolved,
+			slog.String("utxo", c.ds.Utxo),
+			slog.String("hash 1", c.ds.Hashes[0]),
+			slog.String("hash 2", c.ds.Hashes[1]),
+			slog.String("duration", time.Since(c.ds.SentAt).Round(time.Millisecond).String()),
+			slog.String("notified", strings.Join(c.notified, ",")),
+		)
+	}
+}
+
+func (n notification) collidedWith() []collidedTx {
+	if len(n.CollidedWith) == 0 {
+		return nil
+	}
+
+	var list []collidedTx
+	if json.Unmarshal(n.CollidedWith, &list) == nil {
+		return list
+	}
+
+	var single collidedTx
+	if json.Unmarshal(n.CollidedWith, &single) == nil {
+		return []collidedTx{single}
+	}
+
+	return nil
+}
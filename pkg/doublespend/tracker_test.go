@@ -0,0 +1,89 @@
+package doublespend
+
+import (
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+type spenderMock struct {
+	doubleSpends []broadcaster.DoubleSpend
+}
+
+func (s *spenderMock) SubmitDoubleSpend(_ broadcaster.TxOut) (broadcaster.DoubleSpend, error) {
+	ds := s.doubleSpends[0]
+	s.doubleSpends = s.doubleSpends[1:]
+	return ds, nil
+}
+
+type chainMock struct {
+	confirmed map[string]bool
+}
+
+func (c *chainMock) GetMiningInfo() (*node_client.GetMiningInfoResult, error) {
+	return &node_client.GetMiningInfoResult{}, nil
+}
+
+func (c *chainMock) GetTxOut(txHash string, _ uint32, _ bool) (*node_client.GetTxOutResult, error) {
+	if c.confirmed[txHash] {
+		return &node_client.GetTxOutResult{Confirmations: 1}, nil
+	}
+
+	// gettxout returns null for unknown outputs
+	return &node_client.GetTxOutResult{}, nil
+}
+
+func TestTracker(t *testing.T) {
+	sentAt := time.Now().Add(-time.Minute)
+	spender := &spenderMock{doubleSpends: []broadcaster.DoubleSpend{
+		{Utxo: "u1:0", Hashes: [2]string{"a1", "b1"}, Targets: [2]string{"node-a", "node-b"}, SentAt: sentAt},
+		{Utxo: "u2:0", Hashes: [2]string{"a2", "b2"}, Targets: [2]string{"node-b", "node-a"}, Errs: [2]error{nil, errors.New("txn-mempool-conflict")}, SentAt: sentAt},
+		{Utxo: "u3:0", Hashes: [2]string{"a3", "b3"}, Targets: [2]string{"node-a", "node-b"}, Errs: [2]error{errors.New("missing inputs"), errors.New("missing inputs")}, SentAt: sentAt},
+	}}
+	chain := &chainMock{confirmed: map[string]bool{}}
+
+	tracker := New(spender, chain)
+	tracker.logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	for range 3 {
+		_, err := tracker.SubmitDoubleSpend(broadcaster.TxOut{})
+		require.NoError(t, err)
+	}
+
+	// A BSV node discards the tx from its mempool which collides with the tx in the block
+	tracker.notify([]string{TopicDiscardedFromMempool, hex.EncodeToString([]byte(`{"txid":"a1","reason":"collision-in-block-tx","collidedWith":{"txid":"b1"}}`))}, time.Now())
+	tracker.notify([]string{TopicInvalidTx, hex.EncodeToString([]byte(`{"txid":"x","isDoubleSpendDetected":true,"collidedWith":[{"txid":"a1"}]}`))}, time.Now())
+	// Notifications of invalid txs which are not conflicts are ignored
+	tracker.notify([]string{TopicInvalidTx, hex.EncodeToString([]byte(`{"txid":"a2","isMissingInputs":true}`))}, time.Now())
+
+	chain.confirmed["b1"] = true
+	tracker.resolve()
+
+	stats := tracker.Stats()
+	require.Equal(t, int64(2), stats.Submitted)
+	require.Equal(t, int64(1), stats.Resolved)
+	require.Equal(t, int64(1), stats.Notified)
+	require.Equal(t, map[string]int64{"node-b": 1}, stats.Wins)
+	require.GreaterOrEqual(t, stats.Duration, time.Minute)
+
+	require.Len(t, tracker.conflicts, 1)
+	require.Equal(t, "u2:0", tracker.conflicts[0].ds.Utxo)
+	require.Empty(t, tracker.conflicts[0].notified)
+
+	chain.confirmed["a2"] = true
+	tracker.resolve()
+
+	stats = tracker.Stats()
+	require.Equal(t, int64(2), stats.Resolved)
+	require.Equal(t, map[string]int64{"node-b": 2}, stats.Wins)
+	require.Empty(t, tracker.conflicts)
+	require.Empty(t, tracker.byHash)
+}
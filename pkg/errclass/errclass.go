@@ -0,0 +1,51 @@
+package errclass
+
+import (
+	"regexp"
+	"strings"
+)
+
+// knownErrorClasses maps substrings of error messages returned by the nodes to a class
+var knownErrorClasses = []struct {
+	substring string
+	class     string
+}{
+	{substring: "already in utxo set", class: "outputs already in utxo set"},
+	{substring: "txn-mempool-conflict", class: "mempool conflict"},
+	{substring: "txn-double-spend-detected", class: "double spend detected"},
+	{substring: "missing-inputs", class: "missing inputs"},
+	{substring: "missingorspent", class: "missing inputs"},
+	{substring: "mempool full", class: "mempool full"},
+	{substring: "insufficient priority", class: "insufficient fee"},
+	{substring: "min relay fee not met", class: "insufficient fee"},
+	{substring: "too-long-mempool-chain", class: "too long mempool chain"},
+	{substring: "connection refused", class: "connection refused"},
+	{substring: "connection reset", class: "connection reset"},
+	{substring: "timeout", class: "timeout"},
+	{substring: "deadline exceeded", class: "timeout"},
+	{substring: "EOF", class: "EOF"},
+}
+
+var (
+	hexPattern    = regexp.MustCompile(`[0-9a-fA-F]{16,}`)
+	numberPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// Of groups error messages which only differ in hashes, numbers or addresses
+func Of(err string) string {
+	for _, known := range knownErrorClasses {
+		if strings.Contains(err, known.substring) {
+			return known.class
+		}
+	}
+
+	class := hexPattern.ReplaceAllString(err, "<hash>")
+	class = numberPattern.ReplaceAllString(class, "<n>")
+
+	const maxClassLength = 80
+	if len(class) > maxClassLength {
+		class = class[:maxClassLength]
+	}
+
+	return class
+}
@@ -0,0 +1,35 @@
+package errclass
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOf(t *testing.T) {
+	tt := []struct {
+		name string
+		err  string
+
+		expectedClass string
+	}{
+		{
+			name: "known error",
+			err:  `Post "http://localhost:18443": dial tcp 127.0.0.1:18443: connect: connection refused`,
+
+			expectedClass: "connection refused",
+		},
+		{
+			name: "unknown error",
+			err:  "failed to get block for hash 0000000000000000000b3a1c5e2f: code 12",
+
+			expectedClass: "failed to get block for hash <hash>: code <n>",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedClass, Of(tc.err))
+		})
+	}
+}
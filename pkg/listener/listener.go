@@ -4,40 +4,134 @@ import (
 	"context"
 	"log/slog"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+
+	"github.com/boecklim/node-analysis/pkg/metrics"
 )
 
 const (
 	pubhashblock = "hashblock"
+
+	// recentBlocksMax is the number of blocks kept for RecentBlocks
+	recentBlocksMax = 10
+
+	// awaitBlocksInterval is the interval in which AwaitBlocks checks the number of blocks seen
+	awaitBlocksInterval = 100 * time.Millisecond
 )
 
 type Processor interface {
-	GetBlockSize(blockHash *chainhash.Hash) (sizeBytes uint64, nrTxs uint64, err error)
+	GetBlockStats(blockHash *chainhash.Hash) (sizeBytes uint64, nrTxs uint64, height int64, err error)
 }
 
 type Listener struct {
 	rpcClient Processor
+	metrics   *metrics.Metrics
+
+	recentBlocksMu sync.Mutex
+	recentBlocks   []Block
+	blocksSeen     atomic.Int64
+
+	wg sync.WaitGroup
 }
 
-func New(rpcClient Processor) *Listener {
+// Block is a block reported by the node
+type Block struct {
+	Hash      string        `json:"hash"`
+	Height    int64         `json:"height"`
+	Timestamp time.Time     `json:"timestamp"`
+	Delta     time.Duration `json:"delta"`
+	SizeBytes uint64        `json:"size_bytes"`
+	Txs       uint64        `json:"txs"`
+}
+
+type Option func(l *Listener)
+
+// WithMetrics records the blocks seen with their size and the time since the previous block
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(l *Listener) {
+		l.metrics = m
+	}
+}
+
+func New(rpcClient Processor, opts ...Option) *Listener {
 	l := &Listener{
 		rpcClient: rpcClient,
 	}
 
+	for _, opt := range opts {
+		opt(l)
+	}
+
 	return l
 }
 
+// RecentBlocks returns the most recent blocks reported by the node, the latest first
+func (l *Listener) RecentBlocks() []Block {
+	l.recentBlocksMu.Lock()
+	defer l.recentBlocksMu.Unlock()
+
+	blocks := make([]Block, len(l.recentBlocks))
+	for i, block := range l.recentBlocks {
+		blocks[len(blocks)-1-i] = block
+	}
+
+	return blocks
+}
+
+func (l *Listener) addRecentBlock(block Block) {
+	l.recentBlocksMu.Lock()
+	defer l.recentBlocksMu.Unlock()
+
+	l.recentBlocks = append(l.recentBlocks, block)
+	if len(l.recentBlocks) > recentBlocksMax {
+		l.recentBlocks = l.recentBlocks[len(l.recentBlocks)-recentBlocksMax:]
+	}
+}
+
+// BlocksSeen returns the number of blocks reported by the node since the start
+func (l *Listener) BlocksSeen() int64 {
+	return l.blocksSeen.Load()
+}
+
+// AwaitBlocks waits until the node has reported at least the given number of blocks since the start or the context is
+// done
+func (l *Listener) AwaitBlocks(ctx context.Context, blocks int64) error {
+	ticker := time.NewTicker(awaitBlocksInterval)
+	defer ticker.Stop()
+
+	for l.blocksSeen.Load() < blocks {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Wait waits until the listener has stopped after the context has been canceled
+func (l *Listener) Wait() {
+	l.wg.Wait()
+}
+
 type ClientI interface {
 	Subscribe(string, chan []string) error
 }
 
+// Start logs the blocks received on the message channel after the given time until the context is canceled. The
+// messages are hashblock messages of ZMQ or of a P2P observer.
 func (l *Listener) Start(ctx context.Context, messageChan chan []string, newBlockCh chan string, logger *slog.Logger, logAfter time.Time) {
 	logger = logger.With(slog.String("service", "listener"))
 
 	lastBlockFound := time.Now()
+	l.wg.Add(1)
 	go func() {
+		defer l.wg.Done()
 
 		for {
 			select {
@@ -60,19 +154,39 @@ func (l *Listener) Start(ctx context.Context, messageChan chan []string, newBloc
 						continue
 					}
 
-					sizeBytes, nrTxs, err := l.rpcClient.GetBlockSize(blockHash)
+					sizeBytes, nrTxs, height, err := l.rpcClient.GetBlockStats(blockHash)
 					if err != nil {
 						logger.Error("Failed to get block for block hash", "hash", blockHash.String(), "err", err)
 						continue
 					}
 
 					timestamp := time.Now()
+					if len(c) > 3 {
+						// The source has recorded the time at which the block has arrived
+						arrivedAt, err := time.Parse(time.RFC3339Nano, c[3])
+						if err == nil {
+							timestamp = arrivedAt
+						}
+					}
 					timeSinceLastBlock := timestamp.Sub(lastBlockFound)
-					logger.Info("Block", "hash", hash, "timestamp", timestamp.Format(time.RFC3339Nano), "delta", timeSinceLastBlock.String(), "txs", nrTxs, "size", sizeBytes)
+					logger.Info("Block", "hash", hash, "timestamp", timestamp.Format(time.RFC3339Nano), "delta", timeSinceLastBlock.String(), "txs", nrTxs, "size", sizeBytes, "height", height)
+
+					l.metrics.BlockSeen(sizeBytes, timeSinceLastBlock)
+					l.addRecentBlock(Block{
+						Hash:      hash,
+						Height:    height,
+						Timestamp: timestamp,
+						Delta:     timeSinceLastBlock,
+						SizeBytes: sizeBytes,
+						Txs:       nrTxs,
+					})
 
 					lastBlockFound = timestamp
+					l.blocksSeen.Add(1)
 
-					newBlockCh <- hash
+					if newBlockCh != nil {
+						newBlockCh <- hash
+					}
 
 				default:
 					logger.Warn("Unhandled ZMQ message", "msg", strings.Join(c, ","))
@@ -0,0 +1,31 @@
+package listener
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListener_AwaitBlocks(t *testing.T) {
+	l := New(nil)
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(50 * time.Millisecond)
+			l.blocksSeen.Add(1)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	require.NoError(t, l.AwaitBlocks(ctx, 3))
+	require.Equal(t, int64(3), l.BlocksSeen())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, l.AwaitBlocks(ctx, 4), context.DeadlineExceeded)
+}
@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/boecklim/node-analysis/pkg/errclass"
+)
+
+const namespace = "node_analysis"
+
+// Metrics contains the prometheus collectors of all components. All methods can be called on a nil *Metrics in which
+// case nothing is recorded so that components don't have to check whether metrics are enabled.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	txsSubmitted    prometheus.Counter
+	txsFailed       *prometheus.CounterVec
+	utxos           prometheus.Gauge
+	mempoolTxs      prometheus.Gauge
+	blocksSeen      prometheus.Counter
+	blocksGenerated prometheus.Counter
+	blockSize       prometheus.Histogram
+	blockDelta      prometheus.Histogram
+	rpcLatency      *prometheus.HistogramVec
+	zmqReconnects   prometheus.Counter
+}
+
+type options struct {
+	registry *prometheus.Registry
+	labels   prometheus.Labels
+}
+
+type Option func(o *options)
+
+// WithNode labels all collectors with the name of the node, so that the metrics of several nodes can be registered at
+// the same registry
+func WithNode(name string) Option {
+	return func(o *options) {
+		o.labels = prometheus.Labels{"node": name}
+	}
+}
+
+// WithRegistry registers the collectors at the given registry - default is a new registry. If the registry is shared by
+// the metrics of several nodes, each of them has to be created with WithNode.
+func WithRegistry(registry *prometheus.Registry) Option {
+	return func(o *options) {
+		o.registry = registry
+	}
+}
+
+func New(opts ...Option) *Metrics {
+	o := options{registry: prometheus.NewRegistry()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	m := &Metrics{
+		registry: o.registry,
+		txsSubmitted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "txs_submitted_total",
+			Help:        "Number of successfully submitted txs including txs submitted as P2P peer which are counted as failed later",
+			ConstLabels: o.labels,
+		}),
+		txsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "txs_failed_total",
+			Help:        "Number of txs whose submission failed in all attempts by reason of the last attempt",
+			ConstLabels: o.labels,
+		}, []string{"reason"}),
+		utxos: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "utxos",
+			Help:        "Number of utxos available to the broadcaster",
+			ConstLabels: o.labels,
+		}),
+		mempoolTxs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "mempool_txs",
+			Help:        "Number of txs in the mempool of the node",
+			ConstLabels: o.labels,
+		}),
+		blocksSeen: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "blocks_seen_total",
+			Help:        "Number of blocks reported by the node",
+			ConstLabels: o.labels,
+		}),
+		blocksGenerated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "blocks_generated_total",
+			Help:        "Number of blocks generated by the miner",
+			ConstLabels: o.labels,
+		}),
+		blockSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "block_size_bytes",
+			Help:        "Size of the blocks reported by the node",
+			ConstLabels: o.labels,
+			Buckets:     prometheus.ExponentialBuckets(1000, 4, 12),
+		}),
+		blockDelta: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "block_delta_seconds",
+			Help:        "Time between consecutive blocks reported by the node",
+			ConstLabels: o.labels,
+			Buckets:     prometheus.ExponentialBuckets(1, 2, 12),
+		}),
+		rpcLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "rpc_duration_seconds",
+			Help:        "Duration of RPC calls to the node by method",
+			ConstLabels: o.labels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 16),
+		}, []string{"method"}),
+		zmqReconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "zmq_reconnects_total",
+			Help:        "Number of attempts to re-establish the ZMQ connection",
+			ConstLabels: o.labels,
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.txsSubmitted,
+		m.txsFailed,
+		m.utxos,
+		m.mempoolTxs,
+		m.blocksSeen,
+		m.blocksGenerated,
+		m.blockSize,
+		m.blockDelta,
+		m.rpcLatency,
+		m.zmqReconnects,
+	)
+
+	return m
+}
+
+func (m *Metrics) TxSubmitted() {
+	if m == nil {
+		return
+	}
+	m.txsSubmitted.Inc()
+}
+
+// TxFailed counts a tx whose submission failed in all attempts. The reason is the class of the error of the last attempt
+// to keep the number of label values small.
+func (m *Metrics) TxFailed(err error) {
+	if m == nil {
+		return
+	}
+	m.txsFailed.WithLabelValues(errclass.Of(err.Error())).Inc()
+}
+
+func (m *Metrics) SetUtxos(utxos int) {
+	if m == nil {
+		return
+	}
+	m.utxos.Set(float64(utxos))
+}
+
+func (m *Metrics) SetMempoolTxs(txs uint64) {
+	if m == nil {
+		return
+	}
+	m.mempoolTxs.Set(float64(txs))
+}
+
+func (m *Metrics) BlockSeen(sizeBytes uint64, delta time.Duration) {
+	if m == nil {
+		return
+	}
+	m.blocksSeen.Inc()
+	m.blockSize.Observe(float64(sizeBytes))
+	m.blockDelta.Observe(delta.Seconds())
+}
+
+func (m *Metrics) BlockGenerated() {
+	if m == nil {
+		return
+	}
+	m.blocksGenerated.Inc()
+}
+
+func (m *Metrics) ObserveRPC(method string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.rpcLatency.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ZMQReconnect() {
+	if m == nil {
+		return
+	}
+	m.zmqReconnects.Inc()
+}
+
+// Handler returns the HTTP handler exposing the metrics in the prometheus format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes the metrics at /metrics on the given address until the context is canceled
+func (m *Metrics) Serve(ctx context.Context, addr string, logger *slog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("Serving metrics", "addr", addr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Failed to serve metrics", "err", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+}
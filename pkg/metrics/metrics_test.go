@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("nil metrics", func(t *testing.T) {
+		var m *Metrics
+
+		require.NotPanics(t, func() {
+			m.TxSubmitted()
+			m.TxFailed(errors.New("failed"))
+			m.BlockSeen(1000, time.Second)
+			m.ObserveRPC("getblock", time.Millisecond)
+		})
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		m := New()
+		m.TxSubmitted()
+		m.TxFailed(errors.New("Transaction outputs already in utxo set"))
+		m.BlockSeen(2000, 10*time.Second)
+		m.ObserveRPC("getblock", 3*time.Millisecond)
+
+		recorder := httptest.NewRecorder()
+		m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		body, err := io.ReadAll(recorder.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), "node_analysis_txs_submitted_total 1")
+		require.Contains(t, string(body), `node_analysis_txs_failed_total{reason="outputs already in utxo set"} 1`)
+		require.Contains(t, string(body), "node_analysis_blocks_seen_total 1")
+		require.Contains(t, string(body), `node_analysis_rpc_duration_seconds_count{method="getblock"} 1`)
+	})
+
+	t.Run("shared registry", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		node1 := New(WithRegistry(registry), WithNode("node1"))
+		node2 := New(WithRegistry(registry), WithNode("node2"))
+
+		node1.SetUtxos(10)
+		node2.SetUtxos(20)
+		node2.BlockSeen(2000, 10*time.Second)
+
+		recorder := httptest.NewRecorder()
+		node1.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		body, err := io.ReadAll(recorder.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), `node_analysis_utxos{node="node1"} 10`)
+		require.Contains(t, string(body), `node_analysis_utxos{node="node2"} 20`)
+		require.Contains(t, string(body), `node_analysis_blocks_seen_total{node="node1"} 0`)
+		require.Contains(t, string(body), `node_analysis_blocks_seen_total{node="node2"} 1`)
+	})
+}
@@ -2,43 +2,128 @@ package miner
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"math"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/boecklim/node-analysis/pkg/metrics"
 )
 
+// confirmationsWon is the number of confirmations after which a mined block is considered won
+const confirmationsWon = 3
+
 type Processor interface {
 	GenerateBlock() (blockHash string, err error)
+	SetNetworkActive(active bool) error
+	GetBlockConfirmations(blockHash string) (confirmations int64, err error)
 }
 
 type Client struct {
 	client   Processor
+	strategy Strategy
+	retarget *Retarget
+	rng      *rand.Rand
+	metrics  *metrics.Metrics
 	shutdown chan struct{}
+	trigger  chan struct{}
+	running  atomic.Bool
+	wg       sync.WaitGroup
+
+	minedBlocks []string
+	won         int
+	orphaned    int
+}
+
+type Option func(c *Client)
+
+// WithStrategy sets the strategy of the miner - default is the honest strategy
+func WithStrategy(strategy Strategy) Option {
+	return func(c *Client) {
+		c.strategy = strategy
+	}
+}
+
+// WithRetarget lets the miner adapt its block interval to the observed block intervals
+func WithRetarget(retarget *Retarget) Option {
+	return func(c *Client) {
+		c.retarget = retarget
+	}
+}
+
+// WithRand sets the source of randomness from which block times are sampled
+func WithRand(rng *rand.Rand) Option {
+	return func(c *Client) {
+		c.rng = rng
+	}
+}
+
+// WithMetrics counts the generated blocks
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(c *Client) {
+		c.metrics = m
+	}
 }
 
 // New creates a new simulated miner
-func New(client Processor) *Client {
+func New(client Processor, opts ...Option) *Client {
 	c := &Client{
 		client:   client,
+		strategy: NewHonestStrategy(client),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
 		shutdown: make(chan struct{}, 1),
+		trigger:  make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
 
 	return c
 }
 
-func randomSampleExpDist(tau time.Duration) time.Duration {
+// checkMinedBlocks checks for all blocks mined by this miner whether they have been orphaned or whether they have enough confirmations to be considered won
+func (c *Client) checkMinedBlocks(logger *slog.Logger) {
+	pending := make([]string, 0, len(c.minedBlocks))
+
+	for _, blockHash := range c.minedBlocks {
+		confirmations, err := c.client.GetBlockConfirmations(blockHash)
+		if err != nil {
+			logger.Error("failed to get block confirmations", "hash", blockHash, "err", err)
+			pending = append(pending, blockHash)
+			continue
+		}
+
+		switch {
+		case confirmations < 0:
+			c.orphaned++
+			logger.Info("Block orphaned", "hash", blockHash, "strategy", c.strategy.Name(), "won", c.won, "orphaned", c.orphaned)
+		case confirmations >= confirmationsWon:
+			c.won++
+			logger.Info("Block won", "hash", blockHash, "strategy", c.strategy.Name(), "won", c.won, "orphaned", c.orphaned)
+		default:
+			pending = append(pending, blockHash)
+		}
+	}
+
+	c.minedBlocks = pending
+}
+
+func randomSampleExpDist(rng *rand.Rand, tau time.Duration) time.Duration {
 	lambda := 1 / float64(tau.Milliseconds())
 
-	interval := -1 * math.Log(rand.Float64()) / lambda
+	interval := -1 * math.Log(rng.Float64()) / lambda
 
 	return time.Duration(interval) * time.Millisecond
 }
 
 func (c *Client) Start(ctx context.Context, genBlocksInterval time.Duration, newBlockChan chan string, logger *slog.Logger, startAt time.Time) {
-	logger = logger.With(slog.String("service", "miner"))
+	logger = logger.With(slog.String("service", "miner"), slog.String("strategy", c.strategy.Name()))
 
-	durationUntilNextBlockMined := randomSampleExpDist(genBlocksInterval)
+	durationUntilNextBlockMined := randomSampleExpDist(c.rng, genBlocksInterval)
 
 	timer := time.NewTimer(durationUntilNextBlockMined)
 
@@ -46,29 +131,94 @@ func (c *Client) Start(ctx context.Context, genBlocksInterval time.Duration, new
 	logger.Info("Waiting to start", "until", startAt.String())
 	<-startTimer.C
 
+	if c.retarget != nil {
+		c.retarget.Start(time.Now())
+	}
+
+	c.running.Store(true)
+
+	c.wg.Add(1)
 	go func() {
+		defer c.wg.Done()
 		defer func() {
-			logger.Info("stopping broadcasting")
+			c.running.Store(false)
+			c.strategy.Stop(logger)
+			logger.Info("stopping miner", "won", c.won, "orphaned", c.orphaned, "pending", len(c.minedBlocks))
 		}()
 
 		for {
 			select {
 			case blockHash := <-newBlockChan: // A block has been found by another miner -> reset the timer
-				durationUntilNextBlockMined = randomSampleExpDist(genBlocksInterval)
+				interval := genBlocksInterval
+				if c.retarget != nil {
+					adjustment, adjusted := c.retarget.BlockObserved(time.Now())
+					if adjusted {
+						logger.Info("Difficulty adjusted",
+							slog.Float64("old difficulty", adjustment.OldDifficulty),
+							slog.Float64("new difficulty", adjustment.NewDifficulty),
+							slog.String("observed spacing", adjustment.ObservedSpacing.String()),
+							slog.String("interval", c.retarget.Interval(genBlocksInterval).String()),
+						)
+					}
+
+					interval = c.retarget.Interval(genBlocksInterval)
+				}
+
+				durationUntilNextBlockMined = randomSampleExpDist(c.rng, interval)
 				logger.Info("Block found", "hash", blockHash, slog.String("next block", durationUntilNextBlockMined.String()))
 
 				timer.Reset(durationUntilNextBlockMined)
-			case <-timer.C: // time is up -> miner has found a block
-				blockHash, err := c.client.GenerateBlock()
-				if err != nil {
-					logger.Error("failed to generate block", "err", err)
-					continue
-				}
 
-				logger.Info("Block generated", "hash", blockHash)
+				c.checkMinedBlocks(logger)
+			case <-timer.C: // time is up -> miner has found a block
+				c.mine(logger)
+			case <-c.trigger:
+				logger.Info("Block triggered")
+				c.mine(logger)
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 }
+
+func (c *Client) mine(logger *slog.Logger) {
+	blockHash, err := c.strategy.Mine(logger)
+	if err != nil {
+		logger.Error("failed to generate block", "err", err)
+		return
+	}
+
+	c.minedBlocks = append(c.minedBlocks, blockHash)
+
+	c.metrics.BlockGenerated()
+
+	logger.Info("Block generated", "hash", blockHash)
+}
+
+// Wait waits until the miner has stopped after the context has been canceled
+func (c *Client) Wait() {
+	c.wg.Wait()
+}
+
+// Trigger lets the running miner generate a block immediately. If the miner is not running e.g. because blocks are
+// only generated on request or by the central mining scheduler, the block is generated directly by the processor.
+func (c *Client) Trigger() error {
+	if !c.running.Load() {
+		_, err := c.client.GenerateBlock()
+		if err != nil {
+			return fmt.Errorf("failed to generate block: %w", err)
+		}
+
+		c.metrics.BlockGenerated()
+		return nil
+	}
+
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+		// a block is already pending
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package miner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Trigger(t *testing.T) {
+	processor := &processorMock{}
+	sut := New(processor)
+
+	// Without the interval miner the block is generated by the processor
+	require.NoError(t, sut.Trigger())
+	require.NoError(t, sut.Trigger())
+	require.Equal(t, 2, processor.blocks)
+}
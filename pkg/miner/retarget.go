@@ -0,0 +1,127 @@
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	RetargetNone = "none"
+	// RetargetBTC adjusts the difficulty once every window of blocks with the adjustment limited to a factor of 4 like BTC does every 2016 blocks
+	RetargetBTC = "btc"
+	// RetargetBSV adjusts the difficulty after each block based on a sliding window of blocks with the adjustment limited to a factor of 2 like the DAA of BSV
+	RetargetBSV = "bsv"
+
+	DefaultWindowBTC = 2016
+	DefaultWindowBSV = 144
+)
+
+// Retarget emulates a difficulty adjustment by scaling the expected time the miner needs to find a block
+type Retarget struct {
+	mode           string
+	window         int
+	targetInterval time.Duration
+
+	difficulty   float64
+	timestamps   []time.Time
+	difficulties []float64
+	blocks       int
+}
+
+// Adjustment describes a change of the difficulty
+type Adjustment struct {
+	OldDifficulty   float64
+	NewDifficulty   float64
+	ObservedSpacing time.Duration
+}
+
+// NewRetarget creates a new difficulty adjustment which tries to keep the observed block interval at the target interval
+func NewRetarget(mode string, window int, targetInterval time.Duration) (*Retarget, error) {
+	switch mode {
+	case RetargetBTC:
+		if window == 0 {
+			window = DefaultWindowBTC
+		}
+	case RetargetBSV:
+		if window == 0 {
+			window = DefaultWindowBSV
+		}
+	default:
+		return nil, fmt.Errorf("retarget mode %s not valid - has to be either %s or %s", mode, RetargetBTC, RetargetBSV)
+	}
+
+	if window < 1 {
+		return nil, errors.New("retarget window has to be at least 1")
+	}
+
+	if targetInterval <= 0 {
+		return nil, errors.New("retarget target interval has to be greater than 0")
+	}
+
+	return &Retarget{
+		mode:           mode,
+		window:         window,
+		targetInterval: targetInterval,
+		difficulty:     1,
+	}, nil
+}
+
+// Start sets the point in time from which on block intervals are measured
+func (r *Retarget) Start(at time.Time) {
+	r.timestamps = []time.Time{at}
+	r.difficulties = []float64{r.difficulty}
+}
+
+// Interval scales the base interval by the current difficulty
+func (r *Retarget) Interval(baseInterval time.Duration) time.Duration {
+	return time.Duration(float64(baseInterval) * r.difficulty)
+}
+
+// BlockObserved records a new block and returns the adjustment if the difficulty has been changed
+func (r *Retarget) BlockObserved(at time.Time) (*Adjustment, bool) {
+	r.blocks++
+	r.timestamps = append(r.timestamps, at)
+	r.difficulties = append(r.difficulties, r.difficulty)
+
+	if len(r.timestamps) > r.window+1 {
+		r.timestamps = r.timestamps[len(r.timestamps)-r.window-1:]
+		r.difficulties = r.difficulties[len(r.difficulties)-r.window-1:]
+	}
+
+	if len(r.timestamps) < r.window+1 {
+		return nil, false
+	}
+
+	if r.mode == RetargetBTC && r.blocks%r.window != 0 {
+		return nil, false
+	}
+
+	expectedSpan := time.Duration(r.window) * r.targetInterval
+	actualSpan := r.timestamps[len(r.timestamps)-1].Sub(r.timestamps[0])
+
+	maxFactor := time.Duration(4)
+	baseDifficulty := r.difficulty
+	if r.mode == RetargetBSV {
+		maxFactor = 2
+
+		var sum float64
+		for _, difficulty := range r.difficulties[1:] {
+			sum += difficulty
+		}
+		baseDifficulty = sum / float64(r.window)
+	}
+
+	actualSpan = max(actualSpan, expectedSpan/maxFactor)
+	actualSpan = min(actualSpan, expectedSpan*maxFactor)
+
+	adjustment := &Adjustment{
+		OldDifficulty:   r.difficulty,
+		NewDifficulty:   baseDifficulty * float64(expectedSpan) / float64(actualSpan),
+		ObservedSpacing: r.timestamps[len(r.timestamps)-1].Sub(r.timestamps[0]) / time.Duration(r.window),
+	}
+
+	r.difficulty = adjustment.NewDifficulty
+
+	return adjustment, true
+}
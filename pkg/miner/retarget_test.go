@@ -0,0 +1,70 @@
+package miner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetarget_BlockObserved(t *testing.T) {
+	tt := []struct {
+		name    string
+		mode    string
+		spacing time.Duration
+		blocks  int
+
+		expectedAdjustments int
+		expectedDifficulty  float64
+	}{
+		{
+			name:    "btc - blocks twice as fast",
+			mode:    RetargetBTC,
+			spacing: 5 * time.Second,
+			blocks:  8,
+
+			expectedAdjustments: 2,
+			expectedDifficulty:  4,
+		},
+		{
+			name:    "btc - blocks too slow - limited",
+			mode:    RetargetBTC,
+			spacing: 100 * time.Second,
+			blocks:  4,
+
+			expectedAdjustments: 1,
+			expectedDifficulty:  0.25,
+		},
+		{
+			name:    "bsv - blocks twice as fast",
+			mode:    RetargetBSV,
+			spacing: 5 * time.Second,
+			blocks:  4,
+
+			expectedAdjustments: 1,
+			expectedDifficulty:  2,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sut, err := NewRetarget(tc.mode, 4, 10*time.Second)
+			require.NoError(t, err)
+
+			now := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+			sut.Start(now)
+
+			adjustments := 0
+			for i := range tc.blocks {
+				_, adjusted := sut.BlockObserved(now.Add(time.Duration(i+1) * tc.spacing))
+				if adjusted {
+					adjustments++
+				}
+			}
+
+			require.Equal(t, tc.expectedAdjustments, adjustments)
+			require.InDelta(t, tc.expectedDifficulty, sut.difficulty, 0.0001)
+			require.Equal(t, time.Duration(float64(10*time.Second)*tc.expectedDifficulty), sut.Interval(10*time.Second))
+		})
+	}
+}
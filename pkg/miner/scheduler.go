@@ -0,0 +1,108 @@
+package miner
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// Node is a node which can be chosen by the scheduler to mine the next block
+type Node struct {
+	Name     string
+	Hashrate float64
+	Client   Processor
+}
+
+// Scheduler samples the block times of the whole network centrally and lets the node which won the block generate it
+type Scheduler struct {
+	nodes         []Node
+	totalHashrate float64
+	rng           *rand.Rand
+}
+
+// NewScheduler creates a new central mining scheduler which samples block times and winning nodes from the given source of randomness
+func NewScheduler(nodes []Node, rng *rand.Rand) (*Scheduler, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("no nodes given")
+	}
+
+	s := &Scheduler{
+		nodes: nodes,
+		rng:   rng,
+	}
+
+	for _, node := range nodes {
+		if node.Hashrate < 0 {
+			return nil, errors.New("hashrate must not be negative")
+		}
+
+		s.totalHashrate += node.Hashrate
+	}
+
+	if s.totalHashrate == 0 {
+		return nil, errors.New("total hashrate must be greater than 0")
+	}
+
+	return s, nil
+}
+
+// pickNode chooses a node with a probability proportional to its share of the total hashrate
+func (s *Scheduler) pickNode(sample float64) Node {
+	threshold := sample * s.totalHashrate
+
+	var cumulative float64
+	for _, node := range s.nodes {
+		cumulative += node.Hashrate
+		if threshold < cumulative {
+			return node
+		}
+	}
+
+	return s.nodes[len(s.nodes)-1]
+}
+
+func (s *Scheduler) Start(ctx context.Context, genBlocksInterval time.Duration, logger *slog.Logger, startAt time.Time) {
+	logger = logger.With(slog.String("service", "scheduler"))
+
+	startTimer := time.NewTimer(time.Until(startAt))
+	logger.Info("Waiting to start", "until", startAt.String())
+	<-startTimer.C
+
+	go func() {
+		defer func() {
+			logger.Info("stopping scheduler")
+		}()
+
+		// The block times are scheduled against absolute deadlines so that the time it takes to generate a block does not
+		// add to the following interval. If the generation takes longer than the next interval, the next block is
+		// generated immediately.
+		durationUntilNextBlockMined := randomSampleExpDist(s.rng, genBlocksInterval)
+		deadline := time.Now().Add(durationUntilNextBlockMined)
+		timer := time.NewTimer(durationUntilNextBlockMined)
+
+		for {
+			select {
+			case <-timer.C: // time is up -> the network has found a block
+				node := s.pickNode(s.rng.Float64())
+
+				blockHash, err := node.Client.GenerateBlock()
+				if err != nil {
+					logger.Error("failed to generate block", "node", node.Name, "err", err)
+				} else {
+					logger.Info("Block generated", "hash", blockHash, "node", node.Name, "interval", durationUntilNextBlockMined.String(), "delay", time.Since(deadline).String())
+				}
+
+				durationUntilNextBlockMined = randomSampleExpDist(s.rng, genBlocksInterval)
+				deadline = deadline.Add(durationUntilNextBlockMined)
+				logger.Info("Next block", slog.String("next block", durationUntilNextBlockMined.String()))
+
+				timer.Reset(time.Until(deadline))
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,131 @@
+package miner
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_pickNode(t *testing.T) {
+	tt := []struct {
+		name   string
+		sample float64
+
+		expectedNode string
+	}{
+		{
+			name:   "first node",
+			sample: 0.1,
+
+			expectedNode: "node1",
+		},
+		{
+			name:   "second node",
+			sample: 0.5,
+
+			expectedNode: "node2",
+		},
+		{
+			name:   "last node",
+			sample: 0.99,
+
+			expectedNode: "node3",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sut, err := NewScheduler([]Node{
+				{Name: "node1", Hashrate: 1},
+				{Name: "node2", Hashrate: 2},
+				{Name: "node3", Hashrate: 1},
+			}, rand.New(rand.NewSource(1)))
+			require.NoError(t, err)
+
+			node := sut.pickNode(tc.sample)
+
+			require.Equal(t, tc.expectedNode, node.Name)
+		})
+	}
+}
+
+func TestNewScheduler(t *testing.T) {
+	_, err := NewScheduler(nil, rand.New(rand.NewSource(1)))
+	require.Error(t, err)
+
+	_, err = NewScheduler([]Node{{Name: "node1", Hashrate: 0}}, rand.New(rand.NewSource(1)))
+	require.Error(t, err)
+}
+
+// slowProcessor takes the given time to generate a block and records when each generation has started
+type slowProcessor struct {
+	processorMock
+	duration time.Duration
+
+	mu        sync.Mutex
+	generated []time.Time
+}
+
+func (p *slowProcessor) GenerateBlock() (string, error) {
+	p.mu.Lock()
+	p.generated = append(p.generated, time.Now())
+	p.mu.Unlock()
+
+	time.Sleep(p.duration)
+
+	return p.processorMock.GenerateBlock()
+}
+
+func (p *slowProcessor) generatedAt() []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]time.Time(nil), p.generated...)
+}
+
+func TestScheduler_Start(t *testing.T) {
+	const (
+		seed     = 1
+		interval = 20 * time.Millisecond
+		blocks   = 20
+	)
+
+	processor := &slowProcessor{duration: 10 * time.Millisecond}
+	sut, err := NewScheduler([]Node{{Name: "node1", Hashrate: 1, Client: processor}}, rand.New(rand.NewSource(seed)))
+	require.NoError(t, err)
+
+	// The deadlines are sampled in the same order as by the scheduler
+	rng := rand.New(rand.NewSource(seed))
+	deadlines := make([]time.Duration, blocks)
+	var deadline time.Duration
+	for i := range deadlines {
+		deadline += randomSampleExpDist(rng, interval)
+		deadlines[i] = deadline
+		rng.Float64() // node pick
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	startAt := time.Now()
+	sut.Start(ctx, interval, slog.New(slog.NewTextHandler(os.Stdout, nil)), startAt)
+
+	require.Eventually(t, func() bool {
+		return len(processor.generatedAt()) >= blocks
+	}, 5*time.Second, 5*time.Millisecond)
+
+	generated := processor.generatedAt()
+	for i := range deadlines {
+		require.GreaterOrEqual(t, generated[i].Sub(startAt), deadlines[i], "block %d", i)
+	}
+
+	// If the generation time added to each interval, the last block would be late by the generation time of all
+	// previous blocks
+	require.Less(t, generated[blocks-1].Sub(startAt), deadlines[blocks-1]+(blocks/2)*processor.duration)
+}
@@ -0,0 +1,215 @@
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	StrategyHonest   = "honest"
+	StrategySelfish  = "selfish"
+	StrategyWithhold = "withhold"
+)
+
+// Strategy decides what a miner does with the blocks it finds
+type Strategy interface {
+	// Name returns the name of the strategy
+	Name() string
+	// Mine is called whenever the miner has found a block
+	Mine(logger *slog.Logger) (blockHash string, err error)
+	// Stop releases all blocks which are still withheld
+	Stop(logger *slog.Logger)
+}
+
+// HonestStrategy publishes every block immediately
+type HonestStrategy struct {
+	client Processor
+}
+
+func NewHonestStrategy(client Processor) *HonestStrategy {
+	return &HonestStrategy{client: client}
+}
+
+func (s *HonestStrategy) Name() string {
+	return StrategyHonest
+}
+
+func (s *HonestStrategy) Mine(_ *slog.Logger) (string, error) {
+	return s.client.GenerateBlock()
+}
+
+func (s *HonestStrategy) Stop(_ *slog.Logger) {}
+
+// WithholdingStrategy isolates the node from the network while mining and only publishes the block after a delay.
+// Blocks which are found while a block is withheld are released together with it.
+type WithholdingStrategy struct {
+	client Processor
+	delay  time.Duration
+
+	mu           sync.Mutex
+	releaseTimer *time.Timer
+}
+
+func NewWithholdingStrategy(client Processor, delay time.Duration) *WithholdingStrategy {
+	return &WithholdingStrategy{
+		client: client,
+		delay:  delay,
+	}
+}
+
+func (s *WithholdingStrategy) Name() string {
+	return StrategyWithhold
+}
+
+func (s *WithholdingStrategy) Mine(logger *slog.Logger) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.releaseTimer == nil {
+		err := s.client.SetNetworkActive(false)
+		if err != nil {
+			return "", fmt.Errorf("failed to isolate node: %v", err)
+		}
+
+		s.releaseTimer = time.AfterFunc(s.delay, func() {
+			s.release(logger)
+		})
+	}
+
+	blockHash, err := s.client.GenerateBlock()
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("Block withheld", "hash", blockHash, "delay", s.delay.String())
+
+	return blockHash, nil
+}
+
+func (s *WithholdingStrategy) release(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.releaseTimer == nil {
+		return
+	}
+	s.releaseTimer.Stop()
+	s.releaseTimer = nil
+
+	err := s.client.SetNetworkActive(true)
+	if err != nil {
+		logger.Error("Failed to release withheld blocks", "err", err)
+		return
+	}
+
+	logger.Info("Withheld blocks released")
+}
+
+func (s *WithholdingStrategy) Stop(logger *slog.Logger) {
+	s.release(logger)
+}
+
+// SelfishStrategy mines on a private chain while the node is isolated from the network. The private chain is released
+// as soon as it is a given number of blocks ahead or once the maximum withholding time has passed.
+type SelfishStrategy struct {
+	client      Processor
+	releaseLead int
+	maxWithhold time.Duration
+
+	mu           sync.Mutex
+	lead         int
+	releaseTimer *time.Timer
+}
+
+func NewSelfishStrategy(client Processor, releaseLead int, maxWithhold time.Duration) *SelfishStrategy {
+	return &SelfishStrategy{
+		client:      client,
+		releaseLead: releaseLead,
+		maxWithhold: maxWithhold,
+	}
+}
+
+func (s *SelfishStrategy) Name() string {
+	return StrategySelfish
+}
+
+func (s *SelfishStrategy) Mine(logger *slog.Logger) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lead == 0 {
+		err := s.client.SetNetworkActive(false)
+		if err != nil {
+			return "", fmt.Errorf("failed to isolate node: %v", err)
+		}
+
+		if s.maxWithhold > 0 {
+			s.releaseTimer = time.AfterFunc(s.maxWithhold, func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+
+				s.release(logger)
+			})
+		}
+	}
+
+	blockHash, err := s.client.GenerateBlock()
+	if err != nil {
+		if s.lead == 0 {
+			// The node must not stay isolated without a private chain as neither release nor Stop would reconnect it
+			s.stopReleaseTimer()
+
+			activateErr := s.client.SetNetworkActive(true)
+			if activateErr != nil {
+				return "", errors.Join(err, fmt.Errorf("failed to reconnect node: %v", activateErr))
+			}
+		}
+
+		return "", err
+	}
+
+	s.lead++
+	logger.Info("Block mined privately", "hash", blockHash, "lead", s.lead)
+
+	if s.lead >= s.releaseLead {
+		s.release(logger)
+	}
+
+	return blockHash, nil
+}
+
+// release publishes the private chain - the caller has to hold the lock
+func (s *SelfishStrategy) release(logger *slog.Logger) {
+	if s.lead == 0 {
+		return
+	}
+
+	s.stopReleaseTimer()
+
+	err := s.client.SetNetworkActive(true)
+	if err != nil {
+		logger.Error("Failed to release private chain", "err", err)
+		return
+	}
+
+	logger.Info("Private chain released", "blocks", s.lead)
+	s.lead = 0
+}
+
+// stopReleaseTimer stops the timer releasing the private chain - the caller has to hold the lock
+func (s *SelfishStrategy) stopReleaseTimer() {
+	if s.releaseTimer != nil {
+		s.releaseTimer.Stop()
+		s.releaseTimer = nil
+	}
+}
+
+func (s *SelfishStrategy) Stop(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.release(logger)
+}
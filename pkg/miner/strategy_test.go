@@ -0,0 +1,310 @@
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type processorMock struct {
+	mu            sync.Mutex
+	blocks        int
+	networkActive []bool
+
+	generateErr      error
+	networkActiveErr error
+	confirmations    map[string]int64
+	confirmationsErr map[string]error
+}
+
+func (p *processorMock) GenerateBlock() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.generateErr != nil {
+		return "", p.generateErr
+	}
+
+	p.blocks++
+	return fmt.Sprintf("block%d", p.blocks), nil
+}
+
+func (p *processorMock) SetNetworkActive(active bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.networkActiveErr != nil {
+		return p.networkActiveErr
+	}
+
+	p.networkActive = append(p.networkActive, active)
+	return nil
+}
+
+func (p *processorMock) GetBlockConfirmations(blockHash string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.confirmationsErr[blockHash]; err != nil {
+		return 0, err
+	}
+
+	return p.confirmations[blockHash], nil
+}
+
+func (p *processorMock) networkActiveCalls() []bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]bool(nil), p.networkActive...)
+}
+
+func TestSelfishStrategy_Mine(t *testing.T) {
+	tt := []struct {
+		name             string
+		releaseLead      int
+		maxWithhold      time.Duration
+		blocks           int
+		stop             bool
+		generateErr      error
+		networkActiveErr error
+
+		expectedErr           string
+		expectedNetworkActive []bool
+		expectedBlocks        int
+	}{
+		{
+			name:        "lead not reached",
+			releaseLead: 3,
+			blocks:      2,
+
+			expectedNetworkActive: []bool{false},
+			expectedBlocks:        2,
+		},
+		{
+			name:        "lead reached",
+			releaseLead: 2,
+			blocks:      3,
+
+			expectedNetworkActive: []bool{false, true, false},
+			expectedBlocks:        3,
+		},
+		{
+			name:        "max withhold passed",
+			releaseLead: 3,
+			maxWithhold: 20 * time.Millisecond,
+			blocks:      1,
+
+			expectedNetworkActive: []bool{false, true},
+			expectedBlocks:        1,
+		},
+		{
+			name:        "stopped",
+			releaseLead: 3,
+			blocks:      2,
+			stop:        true,
+
+			expectedNetworkActive: []bool{false, true},
+			expectedBlocks:        2,
+		},
+		{
+			name:        "stopped without private chain",
+			releaseLead: 3,
+			stop:        true,
+		},
+		{
+			name:             "isolation fails",
+			releaseLead:      2,
+			blocks:           1,
+			networkActiveErr: errors.New("rpc failed"),
+
+			expectedErr: "failed to isolate node: rpc failed",
+		},
+		{
+			name:        "generation fails",
+			releaseLead: 2,
+			blocks:      1,
+			generateErr: errors.New("rpc failed"),
+
+			// The node is reconnected as there is no private chain which would be released later
+			expectedErr:           "rpc failed",
+			expectedNetworkActive: []bool{false, true},
+		},
+		{
+			name:        "generation fails with max withhold",
+			releaseLead: 2,
+			maxWithhold: 20 * time.Millisecond,
+			blocks:      2,
+			generateErr: errors.New("rpc failed"),
+
+			expectedErr:           "rpc failed",
+			expectedNetworkActive: []bool{false, true, false, true},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			processor := &processorMock{generateErr: tc.generateErr, networkActiveErr: tc.networkActiveErr}
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			sut := NewSelfishStrategy(processor, tc.releaseLead, tc.maxWithhold)
+
+			for range tc.blocks {
+				_, err := sut.Mine(logger)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+					continue
+				}
+				require.NoError(t, err)
+			}
+
+			if tc.stop {
+				sut.Stop(logger)
+			}
+
+			require.Eventually(t, func() bool {
+				return fmt.Sprint(processor.networkActiveCalls()) == fmt.Sprint(tc.expectedNetworkActive)
+			}, time.Second, 5*time.Millisecond)
+			require.Equal(t, tc.expectedBlocks, processor.blocks)
+		})
+	}
+}
+
+func TestWithholdingStrategy_Mine(t *testing.T) {
+	tt := []struct {
+		name             string
+		delay            time.Duration
+		blocks           int
+		stop             bool
+		networkActiveErr error
+
+		expectedErr           string
+		expectedWithheld      []bool
+		expectedNetworkActive []bool
+		expectedBlocks        int
+	}{
+		{
+			name:   "released after delay",
+			delay:  50 * time.Millisecond,
+			blocks: 2,
+
+			expectedWithheld:      []bool{false},
+			expectedNetworkActive: []bool{false, true},
+			expectedBlocks:        2,
+		},
+		{
+			name:   "stopped",
+			delay:  time.Hour,
+			blocks: 1,
+			stop:   true,
+
+			expectedWithheld:      []bool{false},
+			expectedNetworkActive: []bool{false, true},
+			expectedBlocks:        1,
+		},
+		{
+			name:  "stopped without withheld block",
+			delay: time.Hour,
+			stop:  true,
+		},
+		{
+			name:             "isolation fails",
+			delay:            time.Hour,
+			blocks:           1,
+			networkActiveErr: errors.New("rpc failed"),
+
+			expectedErr: "failed to isolate node: rpc failed",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			processor := &processorMock{networkActiveErr: tc.networkActiveErr}
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			sut := NewWithholdingStrategy(processor, tc.delay)
+
+			for range tc.blocks {
+				_, err := sut.Mine(logger)
+				if tc.expectedErr != "" {
+					require.ErrorContains(t, err, tc.expectedErr)
+					continue
+				}
+				require.NoError(t, err)
+			}
+
+			// All blocks found within the delay are withheld together
+			require.Equal(t, tc.expectedWithheld, processor.networkActiveCalls())
+
+			if tc.stop {
+				sut.Stop(logger)
+			}
+
+			require.Eventually(t, func() bool {
+				return fmt.Sprint(processor.networkActiveCalls()) == fmt.Sprint(tc.expectedNetworkActive)
+			}, time.Second, 5*time.Millisecond)
+			require.Equal(t, tc.expectedBlocks, processor.blocks)
+		})
+	}
+}
+
+func TestClient_checkMinedBlocks(t *testing.T) {
+	tt := []struct {
+		name             string
+		minedBlocks      []string
+		confirmations    map[string]int64
+		confirmationsErr map[string]error
+
+		expectedPending  []string
+		expectedWon      int
+		expectedOrphaned int
+	}{
+		{
+			name:        "no mined blocks",
+			minedBlocks: []string{},
+
+			expectedPending: []string{},
+		},
+		{
+			name:        "won, orphaned and pending",
+			minedBlocks: []string{"block1", "block2", "block3", "block4"},
+			confirmations: map[string]int64{
+				"block1": confirmationsWon,
+				"block2": -1,
+				"block3": confirmationsWon - 1,
+				"block4": confirmationsWon + 5,
+			},
+
+			expectedPending:  []string{"block3"},
+			expectedWon:      2,
+			expectedOrphaned: 1,
+		},
+		{
+			name:             "confirmations unknown",
+			minedBlocks:      []string{"block1", "block2"},
+			confirmations:    map[string]int64{"block2": -1},
+			confirmationsErr: map[string]error{"block1": errors.New("rpc failed")},
+
+			expectedPending:  []string{"block1"},
+			expectedOrphaned: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			processor := &processorMock{confirmations: tc.confirmations, confirmationsErr: tc.confirmationsErr}
+			sut := New(processor)
+			sut.minedBlocks = tc.minedBlocks
+
+			sut.checkMinedBlocks(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+			require.Equal(t, tc.expectedPending, sut.minedBlocks)
+			require.Equal(t, tc.expectedWon, sut.won)
+			require.Equal(t, tc.expectedOrphaned, sut.orphaned)
+		})
+	}
+}
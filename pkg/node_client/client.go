@@ -9,6 +9,8 @@ import (
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/boecklim/node-analysis/pkg/metrics"
 )
 
 type RPCRequest struct {
@@ -25,6 +27,12 @@ type RPCResponse struct {
 }
 
 func sendJsonRPCCall[T any](method string, params []interface{}, nodeHost string, nodePort int, nodeUser, nodePassword string) (*T, error) {
+	result, _, err := sendJsonRPCCallWithHeader[T](method, params, nodeHost, nodePort, nodeUser, nodePassword)
+	return result, err
+}
+
+// sendJsonRPCCallWithHeader sends the RPC call and additionally returns the header of the HTTP response
+func sendJsonRPCCallWithHeader[T any](method string, params []interface{}, nodeHost string, nodePort int, nodeUser, nodePassword string) (*T, http.Header, error) {
 	c := http.Client{}
 
 	rpcRequest := RPCRequest{method, params, time.Now().UnixNano(), "1.0"}
@@ -33,7 +41,7 @@ func sendJsonRPCCall[T any](method string, params []interface{}, nodeHost string
 
 	err := jsonEncoder.Encode(rpcRequest)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	req, err := http.NewRequest(
@@ -42,7 +50,7 @@ func sendJsonRPCCall[T any](method string, params []interface{}, nodeHost string
 		payloadBuffer,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	req.SetBasicAuth(nodeUser, nodePassword)
@@ -51,13 +59,13 @@ func sendJsonRPCCall[T any](method string, params []interface{}, nodeHost string
 
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var rpcResponse RPCResponse
@@ -71,31 +79,31 @@ func sendJsonRPCCall[T any](method string, params []interface{}, nodeHost string
 			err = errors.New("HTTP error: " + resp.Status)
 		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	err = json.Unmarshal(data, &rpcResponse)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if rpcResponse.Err != nil {
 		e, ok := rpcResponse.Err.(error)
 		if ok {
-			return nil, e
+			return nil, nil, e
 		}
-		return nil, errors.New("unknown error returned from node in rpc response")
+		return nil, nil, errors.New("unknown error returned from node in rpc response")
 	}
 
 	var responseResult T
 
 	err = json.Unmarshal(rpcResponse.Result, &responseResult)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarhsal response: %v", err)
+		return nil, nil, fmt.Errorf("failed to unmarhsal response: %v", err)
 	}
 
-	return &responseResult, nil
+	return &responseResult, resp.Header, nil
 }
 
 type Client struct {
@@ -104,10 +112,20 @@ type Client struct {
 	user     string
 	password string
 
-	logger *slog.Logger
+	logger  *slog.Logger
+	metrics *metrics.Metrics
 }
 
-func New(host string, port int, user, password string, logger *slog.Logger) (*Client, error) {
+type ClientOption func(c *Client)
+
+// WithMetrics records the duration of each RPC call
+func WithMetrics(m *metrics.Metrics) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+func New(host string, port int, user, password string, logger *slog.Logger, opts ...ClientOption) (*Client, error) {
 	c := &Client{
 		logger:   logger,
 		host:     host,
@@ -116,38 +134,52 @@ func New(host string, port int, user, password string, logger *slog.Logger) (*Cl
 		password: password,
 	}
 
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	return c, nil
 }
 
+// call sends the RPC call to the node and records its duration
+func call[T any](c *Client, method string, params []interface{}) (*T, error) {
+	start := time.Now()
+	defer func() {
+		c.metrics.ObserveRPC(method, time.Since(start))
+	}()
+
+	return sendJsonRPCCall[T](method, params, c.host, c.port, c.user, c.password)
+}
+
 func (c *Client) SendRawTransaction(hexString string, isBSV bool) (*string, error) {
 	if isBSV {
-		return sendJsonRPCCall[string]("sendrawtransaction", []interface{}{hexString, true, true}, c.host, c.port, c.user, c.password)
+		return call[string](c, "sendrawtransaction", []interface{}{hexString, true, true})
 	}
-	return sendJsonRPCCall[string]("sendrawtransaction", []interface{}{hexString, 0}, c.host, c.port, c.user, c.password)
+	return call[string](c, "sendrawtransaction", []interface{}{hexString, 0})
 }
 
 func (c *Client) GetMiningInfo() (*GetMiningInfoResult, error) {
-	return sendJsonRPCCall[GetMiningInfoResult]("getmininginfo", nil, c.host, c.port, c.user, c.password)
+	return call[GetMiningInfoResult](c, "getmininginfo", nil)
 }
 
 func (c *Client) GetBlock(blockHash string) (*GetBlockVerboseResult, error) {
-	return sendJsonRPCCall[GetBlockVerboseResult]("getblock", []interface{}{blockHash}, c.host, c.port, c.user, c.password)
+	return call[GetBlockVerboseResult](c, "getblock", []interface{}{blockHash})
 }
 
 func (c *Client) GetBlockHash(blockHeight int64) (*string, error) {
-	return sendJsonRPCCall[string]("getblockhash", []interface{}{blockHeight}, c.host, c.port, c.user, c.password)
+	return call[string](c, "getblockhash", []interface{}{blockHeight})
 }
 
 func (c *Client) GetTxOut(txHash string, index uint32, mempool bool) (*GetTxOutResult, error) {
-	return sendJsonRPCCall[GetTxOutResult]("gettxout", []interface{}{txHash, index, mempool}, c.host, c.port, c.user, c.password)
+	return call[GetTxOutResult](c, "gettxout", []interface{}{txHash, index, mempool})
 }
 
 func (c *Client) GetNetworkInfo() (*GetNetworkInfoResult, error) {
-	return sendJsonRPCCall[GetNetworkInfoResult]("getnetworkinfo", nil, c.host, c.port, c.user, c.password)
+	return call[GetNetworkInfoResult](c, "getnetworkinfo", nil)
 }
 
 func (c *Client) GenerateToAddress(nBlocks int64, address string) ([]string, error) {
-	hashes, err := sendJsonRPCCall[[]string]("generatetoaddress", []interface{}{nBlocks, address}, c.host, c.port, c.user, c.password)
+	hashes, err := call[[]string](c, "generatetoaddress", []interface{}{nBlocks, address})
 	if err != nil {
 		return nil, err
 	}
@@ -156,10 +188,86 @@ func (c *Client) GenerateToAddress(nBlocks int64, address string) ([]string, err
 }
 
 func (c *Client) GetRawMempool() ([]string, error) {
-	hashes, err := sendJsonRPCCall[[]string]("getrawmempool", nil, c.host, c.port, c.user, c.password)
+	hashes, err := call[[]string](c, "getrawmempool", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	return *hashes, nil
 }
+
+func (c *Client) GetMempoolInfo() (*GetMempoolInfoResult, error) {
+	return call[GetMempoolInfoResult](c, "getmempoolinfo", nil)
+}
+
+func (c *Client) SetNetworkActive(state bool) error {
+	_, err := call[bool](c, "setnetworkactive", []interface{}{state})
+	return err
+}
+
+// AddNode adds (command add), removes (command remove) or tries once to connect to (command onetry) the peer with the
+// given address
+func (c *Client) AddNode(addr string, command string) error {
+	_, err := call[any](c, "addnode", []interface{}{addr, command})
+	return err
+}
+
+// DisconnectNode disconnects the peer with the given address as shown by getpeerinfo
+func (c *Client) DisconnectNode(addr string) error {
+	_, err := call[any](c, "disconnectnode", []interface{}{addr})
+	return err
+}
+
+func (c *Client) GetPeerInfo() ([]PeerInfo, error) {
+	peers, err := call[[]PeerInfo](c, "getpeerinfo", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return *peers, nil
+}
+
+// NodeTime returns the time of the node clock truncated to seconds as given by the Date header of the RPC response
+func (c *Client) NodeTime() (time.Time, error) {
+	start := time.Now()
+	defer func() {
+		c.metrics.ObserveRPC("getmininginfo", time.Since(start))
+	}()
+
+	_, header, err := sendJsonRPCCallWithHeader[GetMiningInfoResult]("getmininginfo", nil, c.host, c.port, c.user, c.password)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return http.ParseTime(header.Get("Date"))
+}
+
+func (c *Client) GetRawMempoolVerbose() (map[string]RawMempoolVerboseResult, error) {
+	txs, err := call[map[string]RawMempoolVerboseResult](c, "getrawmempool", []interface{}{true})
+	if err != nil {
+		return nil, err
+	}
+
+	return *txs, nil
+}
+
+func (c *Client) GenerateBlock(address string, txs []string) (*GenerateBlockResult, error) {
+	return call[GenerateBlockResult](c, "generateblock", []interface{}{address, txs})
+}
+
+func (c *Client) GetBlockTemplate() (*GetBlockTemplateResult, error) {
+	return call[GetBlockTemplateResult](c, "getblocktemplate", nil)
+}
+
+func (c *Client) SubmitBlock(blockHex string) error {
+	result, err := call[string](c, "submitblock", []interface{}{blockHex})
+	if err != nil {
+		return err
+	}
+
+	if *result != "" {
+		return fmt.Errorf("block rejected: %s", *result)
+	}
+
+	return nil
+}
@@ -8,9 +8,12 @@ import (
 	"log/slog"
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/listener"
+	"github.com/boecklim/node-analysis/pkg/miner"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -26,7 +29,11 @@ const (
 	blocksGenerated = 200
 )
 
-var _ broadcaster.Processor = &Processor{}
+var (
+	_ broadcaster.Processor = &Processor{}
+	_ miner.Processor       = &Processor{}
+	_ listener.Processor    = &Processor{}
+)
 
 type GetMiningInfoResult struct {
 	Blocks             int64   `json:"blocks"`
@@ -79,6 +86,16 @@ type GetNetworkInfoResult struct {
 	//Warnings        StringOrArray          `json:"warnings"`
 }
 
+// PeerInfo is a peer connected to the node as returned by getpeerinfo
+type PeerInfo struct {
+	ID             int64  `json:"id"`
+	Addr           string `json:"addr"`
+	Inbound        bool   `json:"inbound"`
+	TimeOffset     int64  `json:"timeoffset"`
+	SubVer         string `json:"subver"`
+	StartingHeight int64  `json:"startingheight"`
+}
+
 type StringOrArray []string
 
 type LocalAddressesResult struct {
@@ -111,6 +128,50 @@ type ScriptPubKeyResult struct {
 	Addresses []string `json:"addresses,omitempty"` // Deprecated: removed in Bitcoin Core
 }
 
+type RawMempoolVerboseResult struct {
+	Size          int64      `json:"size"`  // BSV
+	Fee           float64    `json:"fee"`   // BSV
+	Vsize         int64      `json:"vsize"` // BTC
+	Fees          FeesResult `json:"fees"`  // BTC
+	Depends       []string   `json:"depends"`
+	AncestorCount int64      `json:"ancestorcount"` // BTC
+}
+
+// GetMempoolInfoResult is the state of the mempool. Usage and MaxMempool are in bytes of memory.
+type GetMempoolInfoResult struct {
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	Usage         int64   `json:"usage"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+}
+
+type FeesResult struct {
+	Base float64 `json:"base"`
+}
+
+type GenerateBlockResult struct {
+	Hash string `json:"hash"`
+}
+
+type GetBlockTemplateResult struct {
+	Version           int32                      `json:"version"`
+	PreviousBlockHash string                     `json:"previousblockhash"`
+	Transactions      []GetBlockTemplateResultTx `json:"transactions"`
+	CoinbaseValue     int64                      `json:"coinbasevalue"`
+	CurTime           int64                      `json:"curtime"`
+	Bits              string                     `json:"bits"`
+	Height            int64                      `json:"height"`
+}
+
+type GetBlockTemplateResultTx struct {
+	Data    string  `json:"data"`
+	TxID    string  `json:"txid"`
+	Hash    string  `json:"hash"`
+	Depends []int64 `json:"depends"`
+	Fee     int64   `json:"fee"`
+}
+
 type RPCClient interface {
 	GenerateToAddress(nBlocks int64, address string) ([]string, error)
 	GetMiningInfo() (*GetMiningInfoResult, error)
@@ -120,15 +181,57 @@ type RPCClient interface {
 	GetTxOut(txHash string, index uint32, mempool bool) (*GetTxOutResult, error)
 	SendRawTransaction(hexString string, isBSV bool) (*string, error)
 	GetRawMempool() ([]string, error)
+	GetMempoolInfo() (*GetMempoolInfoResult, error)
+	SetNetworkActive(state bool) error
+	GetRawMempoolVerbose() (map[string]RawMempoolVerboseResult, error)
+	GenerateBlock(address string, txs []string) (*GenerateBlockResult, error)
+	GetBlockTemplate() (*GetBlockTemplateResult, error)
+	SubmitBlock(blockHex string) error
+	AddNode(addr string, command string) error
+	DisconnectNode(addr string) error
+	GetPeerInfo() ([]PeerInfo, error)
+}
+
+// Submitter submits raw txs to one or more nodes
+type Submitter interface {
+	SendRawTransaction(hexString string, isBSV bool) (*string, error)
+}
+
+// ConflictSubmitter submits two conflicting txs to two different nodes at the same time
+type ConflictSubmitter interface {
+	SendConflicting(hexStrings [2]string, isBSV bool) (targets [2]string, errs [2]error)
 }
 
 type Processor struct {
 	client             RPCClient
+	submitter          Submitter
 	logger             *slog.Logger
 	isBSV              bool
 	splitToAddressFunc func(txOut *broadcaster.TxOut, outputs int) (res *splitResult, err error)
 	addressString      string
 	privKey            *btcec.PrivateKey
+
+	rng           *rand.Rand
+	blockTemplate BlockTemplateOptions
+	ownTxsMu      sync.Mutex
+	ownTxs        map[string]struct{}
+}
+
+type Option func(p *Processor)
+
+// WithRand sets the source of randomness from which coinbase outputs are selected
+func WithRand(rng *rand.Rand) Option {
+	return func(p *Processor) {
+		p.rng = rng
+	}
+}
+
+// WithSubmitter sets the submitter of the txs broadcast at the given rate - default is the RPC client. The txs by which
+// the utxos are prepared are always sent via the RPC client.
+func WithSubmitter(submitter Submitter) Option {
+	return func(p *Processor) {
+		p.submitter = submitter
+	}
 }
 
 func (p *Processor) setAddress() error {
@@ -154,11 +257,18 @@ func (p *Processor) setAddress() error {
 	return nil
 }
 
-func NewProcessor(client RPCClient, logger *slog.Logger, isBSV bool) (*Processor, error) {
+func NewProcessor(client RPCClient, logger *slog.Logger, isBSV bool, opts ...Option) (*Processor, error) {
 	p := &Processor{
-		client: client,
-		logger: logger,
-		isBSV:  isBSV,
+		client:    client,
+		submitter: client,
+		logger:    logger,
+		isBSV:     isBSV,
+		ownTxs:    make(map[string]struct{}),
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	err := p.setAddress()
@@ -191,7 +301,7 @@ func (p *Processor) getCoinbaseTxOut() (*broadcaster.TxOut, error) {
 			return nil, fmt.Errorf("failed to get info: %v", err)
 		}
 
-		randomHeightOfGeneratedBlock := currentBlockHeight - blocksGenerated + int64(rand.Intn(100))
+		randomHeightOfGeneratedBlock := currentBlockHeight - blocksGenerated + int64(p.rng.Intn(100))
 		blockHash, err := p.client.GetBlockHash(randomHeightOfGeneratedBlock)
 		if err != nil {
 			return nil, fmt.Errorf("failed go get block hash at height %d: %v", randomHeightOfGeneratedBlock, err)
@@ -254,24 +364,62 @@ func (p *Processor) SubmitSelfPayingSingleOutputTx(txOut broadcaster.TxOut) (txH
 		return nil, 0, err
 	}
 
-	_, err = p.client.SendRawTransaction(txResult.hexString, p.isBSV)
+	_, err = p.submitter.SendRawTransaction(txResult.hexString, p.isBSV)
 	if err != nil {
 		if strings.Contains(err.Error(), "Transaction outputs already in utxo set") {
 			p.logger.Error("Submitting tx failed", "txOut.hash", txOut.Hash.String(), "txOut.value", txOut.ValueSat, "txOut.vout", txOut.VOut, "hash", txResult.hash.String(), "err", err)
 		}
-		return nil, 0, err
+		// The hash is returned so that the failed submission can be recorded with the hash of the tx
+		return txResult.hash, 0, err
 	}
 
+	p.addOwnTx(txResult.hash.String())
+
 	return txResult.hash, txResult.outputs[0].satoshis, nil
 }
 
-func (p *Processor) GetBlockSize(blockHash *chainhash.Hash) (sizeBytes uint64, nrTxs uint64, err error) {
+// SubmitDoubleSpend spends the utxo by two txs which differ in their outputs and sends them to two different nodes at the
+// same time. The submitter has to be able to send conflicting txs e.g. a router with at least two targets.
+func (p *Processor) SubmitDoubleSpend(txOut broadcaster.TxOut) (broadcaster.DoubleSpend, error) {
+	conflictSubmitter, ok := p.submitter.(ConflictSubmitter)
+	if !ok {
+		return broadcaster.DoubleSpend{}, errors.New("submitter cannot send conflicting txs")
+	}
+
+	first, err := p.splitToAddressFunc(&txOut, 0)
+	if err != nil {
+		return broadcaster.DoubleSpend{}, err
+	}
+
+	second, err := p.splitToAddressFunc(&txOut, 1)
+	if err != nil {
+		return broadcaster.DoubleSpend{}, err
+	}
+
+	ds := broadcaster.DoubleSpend{
+		Utxo:   fmt.Sprintf("%s:%d", txOut.Hash.String(), txOut.VOut),
+		Hashes: [2]string{first.hash.String(), second.hash.String()},
+		SentAt: time.Now(),
+	}
+
+	ds.Targets, ds.Errs = conflictSubmitter.SendConflicting([2]string{first.hexString, second.hexString}, p.isBSV)
+
+	for i, hash := range ds.Hashes {
+		if ds.Errs[i] == nil {
+			p.addOwnTx(hash)
+		}
+	}
+
+	return ds, nil
+}
+
+func (p *Processor) GetBlockStats(blockHash *chainhash.Hash) (sizeBytes uint64, nrTxs uint64, height int64, err error) {
 	blockMsg, err := p.client.GetBlock(blockHash.String())
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, 0, err
 	}
 
-	return uint64(blockMsg.Size), uint64(len(blockMsg.Tx)), nil
+	return uint64(blockMsg.Size), uint64(len(blockMsg.Tx)), blockMsg.Height, nil
 }
 
 func (p *Processor) GetMempoolSize() (nrTxs uint64, err error) {
@@ -283,6 +431,10 @@ func (p *Processor) GetMempoolSize() (nrTxs uint64, err error) {
 	return uint64(len(rawMempool)), nil
 }
 
+func (p *Processor) GetMempoolInfo() (*GetMempoolInfoResult, error) {
+	return p.client.GetMempoolInfo()
+}
+
 func (p *Processor) PrepareUtxos(utxoChannel chan broadcaster.TxOut, targetUtxos int) (err error) {
 	signalFinish := make(chan struct{})
 	loggingStopped := make(chan struct{})
@@ -402,6 +554,14 @@ type splitResult struct {
 }
 
 func (p *Processor) GenerateBlock() (blockHash string, err error) {
+	if !p.blockTemplate.isDefault() {
+		if p.isBSV {
+			return p.generateBlockBSV()
+		}
+
+		return p.generateBlockBTC()
+	}
+
 	blockHashes, err := p.client.GenerateToAddress(1, p.addressString)
 	if err != nil {
 		return "", err
@@ -409,3 +569,17 @@ func (p *Processor) GenerateBlock() (blockHash string, err error) {
 
 	return blockHashes[0], nil
 }
+
+func (p *Processor) SetNetworkActive(active bool) error {
+	return p.client.SetNetworkActive(active)
+}
+
+// GetBlockConfirmations returns the number of confirmations of the block - a value of -1 means that the block is not in the main chain
+func (p *Processor) GetBlockConfirmations(blockHash string) (confirmations int64, err error) {
+	block, err := p.client.GetBlock(blockHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return block.Confirmations, nil
+}
@@ -0,0 +1,387 @@
+package node_client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// TxsAll includes all transactions of the mempool in the block
+	TxsAll = "all"
+	// TxsOwn includes only transactions which were submitted by this processor in the block
+	TxsOwn = "own"
+	// TxsForeign includes only transactions which were not submitted by this processor in the block
+	TxsForeign = "foreign"
+
+	// blockOverheadBytes is reserved for the block header and the coinbase tx when filling a block up to its maximum size
+	blockOverheadBytes = 1000
+	coinbaseTag        = "/node-analysis/"
+)
+
+// BlockTemplateOptions control which transactions the processor includes in the blocks it generates
+type BlockTemplateOptions struct {
+	// Empty generates blocks which only contain the coinbase tx
+	Empty bool
+	// MaxSizeBytes caps the size of generated blocks - 0 means no cap
+	MaxSizeBytes uint64
+	// Txs is one of TxsAll, TxsOwn or TxsForeign
+	Txs string
+}
+
+func (o BlockTemplateOptions) isDefault() bool {
+	return !o.Empty && o.MaxSizeBytes == 0 && (o.Txs == "" || o.Txs == TxsAll)
+}
+
+// WithBlockTemplate sets the options which control the content of generated blocks
+func WithBlockTemplate(options BlockTemplateOptions) Option {
+	return func(p *Processor) {
+		p.blockTemplate = options
+	}
+}
+
+type templateTx struct {
+	id      string
+	data    string
+	size    uint64
+	fee     int64
+	depends []string
+}
+
+// selectTxs selects the transactions which fit into the block. Transactions have to be ordered such that parents come
+// before their children. A transaction is only selected if all its parents which are part of the given transactions
+// have been selected.
+func selectTxs(txs []templateTx, maxSizeBytes uint64, include func(txID string) bool) []templateTx {
+	txIDs := make(map[string]struct{}, len(txs))
+	for _, tx := range txs {
+		txIDs[tx.id] = struct{}{}
+	}
+
+	selectedIDs := make(map[string]struct{}, len(txs))
+	selected := make([]templateTx, 0, len(txs))
+
+	var blockSize uint64 = blockOverheadBytes
+
+txLoop:
+	for _, tx := range txs {
+		if !include(tx.id) {
+			continue
+		}
+
+		if maxSizeBytes > 0 && blockSize+tx.size > maxSizeBytes {
+			continue
+		}
+
+		for _, parent := range tx.depends {
+			_, isTemplateTx := txIDs[parent]
+			_, isSelected := selectedIDs[parent]
+			if isTemplateTx && !isSelected {
+				continue txLoop
+			}
+		}
+
+		blockSize += tx.size
+		selectedIDs[tx.id] = struct{}{}
+		selected = append(selected, tx)
+	}
+
+	return selected
+}
+
+func (p *Processor) addOwnTx(txID string) {
+	if p.blockTemplate.isDefault() {
+		return
+	}
+
+	p.ownTxsMu.Lock()
+	defer p.ownTxsMu.Unlock()
+
+	p.ownTxs[txID] = struct{}{}
+}
+
+func (p *Processor) isOwnTx(txID string) bool {
+	p.ownTxsMu.Lock()
+	defer p.ownTxsMu.Unlock()
+
+	_, found := p.ownTxs[txID]
+	return found
+}
+
+// pruneOwnTxs forgets all own transactions which are not in the mempool any more. The block template is not used for
+// this as it may not contain all transactions of the mempool.
+func (p *Processor) pruneOwnTxs() error {
+	// Only transactions which were added before the mempool is requested are pruned
+	p.ownTxsMu.Lock()
+	ownTxIDs := make([]string, 0, len(p.ownTxs))
+	for txID := range p.ownTxs {
+		ownTxIDs = append(ownTxIDs, txID)
+	}
+	p.ownTxsMu.Unlock()
+
+	if len(ownTxIDs) == 0 {
+		return nil
+	}
+
+	rawMempool, err := p.client.GetRawMempool()
+	if err != nil {
+		return fmt.Errorf("failed to get raw mempool: %v", err)
+	}
+
+	mempoolTxIDs := make(map[string]struct{}, len(rawMempool))
+	for _, txID := range rawMempool {
+		mempoolTxIDs[txID] = struct{}{}
+	}
+
+	p.ownTxsMu.Lock()
+	defer p.ownTxsMu.Unlock()
+
+	for _, txID := range ownTxIDs {
+		if _, found := mempoolTxIDs[txID]; !found {
+			delete(p.ownTxs, txID)
+		}
+	}
+
+	return nil
+}
+
+func (p *Processor) includeTx(txID string) bool {
+	switch p.blockTemplate.Txs {
+	case TxsOwn:
+		return p.isOwnTx(txID)
+	case TxsForeign:
+		return !p.isOwnTx(txID)
+	default:
+		return true
+	}
+}
+
+func (p *Processor) selectTemplateTxs(txs []templateTx) ([]templateTx, error) {
+	err := p.pruneOwnTxs()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.blockTemplate.Empty {
+		return nil, nil
+	}
+
+	return selectTxs(txs, p.blockTemplate.MaxSizeBytes, p.includeTx), nil
+}
+
+// generateBlockBTC generates a block with an explicit list of transactions using the generateblock RPC
+func (p *Processor) generateBlockBTC() (blockHash string, err error) {
+	mempool, err := p.client.GetRawMempoolVerbose()
+	if err != nil {
+		return "", fmt.Errorf("failed to get raw mempool: %v", err)
+	}
+
+	txIDs := make([]string, 0, len(mempool))
+	for txID := range mempool {
+		txIDs = append(txIDs, txID)
+	}
+
+	// Parents have less ancestors than their children
+	sort.Slice(txIDs, func(i, j int) bool {
+		return mempool[txIDs[i]].AncestorCount < mempool[txIDs[j]].AncestorCount
+	})
+
+	txs := make([]templateTx, len(txIDs))
+	for i, txID := range txIDs {
+		entry := mempool[txID]
+		txs[i] = templateTx{
+			id:      txID,
+			size:    uint64(entry.Vsize),
+			fee:     int64(entry.Fees.Base * satPerBtc),
+			depends: entry.Depends,
+		}
+	}
+
+	selected, err := p.selectTemplateTxs(txs)
+	if err != nil {
+		return "", err
+	}
+
+	selectedIDs := make([]string, len(selected))
+	for i, tx := range selected {
+		selectedIDs[i] = tx.id
+	}
+
+	result, err := p.client.GenerateBlock(p.addressString, selectedIDs)
+	if err != nil {
+		return "", err
+	}
+
+	p.logger.Debug("Generated block from template", "hash", result.Hash, "txs", len(selected), "mempool txs", len(txs))
+
+	return result.Hash, nil
+}
+
+// generateBlockBSV assembles and mines a block from the transactions of the block template and submits it
+func (p *Processor) generateBlockBSV() (blockHash string, err error) {
+	template, err := p.client.GetBlockTemplate()
+	if err != nil {
+		return "", fmt.Errorf("failed to get block template: %v", err)
+	}
+
+	var totalFees int64
+	txs := make([]templateTx, len(template.Transactions))
+	for i, templateTransaction := range template.Transactions {
+		depends := make([]string, 0, len(templateTransaction.Depends))
+		for _, index := range templateTransaction.Depends {
+			if index < 1 || int(index) > len(template.Transactions) {
+				return "", fmt.Errorf("invalid dependency index %d in block template", index)
+			}
+			depends = append(depends, template.Transactions[index-1].TxID)
+		}
+
+		txs[i] = templateTx{
+			id:      templateTransaction.TxID,
+			data:    templateTransaction.Data,
+			size:    uint64(len(templateTransaction.Data) / 2),
+			fee:     templateTransaction.Fee,
+			depends: depends,
+		}
+
+		totalFees += templateTransaction.Fee
+	}
+
+	selected, err := p.selectTemplateTxs(txs)
+	if err != nil {
+		return "", err
+	}
+
+	blockHex, hash, err := p.buildBlock(template, selected, totalFees)
+	if err != nil {
+		return "", err
+	}
+
+	err = p.client.SubmitBlock(blockHex)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit block: %v", err)
+	}
+
+	p.logger.Debug("Generated block from template", "hash", hash, "txs", len(selected), "template txs", len(txs))
+
+	return hash, nil
+}
+
+func (p *Processor) buildBlock(template *GetBlockTemplateResult, selected []templateTx, totalFees int64) (blockHex string, blockHash string, err error) {
+	address, err := btcutil.NewAddressPubKey(p.privKey.PubKey().SerializeCompressed(), &chaincfg.RegressionNetParams)
+	if err != nil {
+		return "", "", err
+	}
+
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return "", "", err
+	}
+
+	sigScript, err := txscript.NewScriptBuilder().AddInt64(template.Height).AddData([]byte(coinbaseTag)).Script()
+	if err != nil {
+		return "", "", err
+	}
+
+	coinbaseValue := template.CoinbaseValue - totalFees
+	for _, tx := range selected {
+		coinbaseValue += tx.fee
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, math.MaxUint32), sigScript, nil))
+	coinbase.AddTxOut(wire.NewTxOut(coinbaseValue, pkScript))
+
+	hashes := make([]chainhash.Hash, 0, len(selected)+1)
+	hashes = append(hashes, coinbase.TxHash())
+	for _, tx := range selected {
+		hash, err := chainhash.NewHashFromStr(tx.id)
+		if err != nil {
+			return "", "", err
+		}
+		hashes = append(hashes, *hash)
+	}
+
+	prevHash, err := chainhash.NewHashFromStr(template.PreviousBlockHash)
+	if err != nil {
+		return "", "", err
+	}
+
+	bits, err := strconv.ParseUint(template.Bits, 16, 32)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid bits %s: %v", template.Bits, err)
+	}
+
+	merkleRoot := calcMerkleRoot(hashes)
+	header := wire.NewBlockHeader(template.Version, prevHash, &merkleRoot, uint32(bits), 0)
+	header.Timestamp = time.Unix(template.CurTime, 0)
+
+	err = solveBlockHeader(header)
+	if err != nil {
+		return "", "", err
+	}
+
+	buf := &bytes.Buffer{}
+	err = header.Serialize(buf)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = wire.WriteVarInt(buf, 0, uint64(len(selected)+1))
+	if err != nil {
+		return "", "", err
+	}
+
+	err = coinbase.SerializeNoWitness(buf)
+	if err != nil {
+		return "", "", err
+	}
+
+	blockHexBuilder := bytes.NewBufferString(hex.EncodeToString(buf.Bytes()))
+	for _, tx := range selected {
+		blockHexBuilder.WriteString(tx.data)
+	}
+
+	return blockHexBuilder.String(), header.BlockHash().String(), nil
+}
+
+func calcMerkleRoot(hashes []chainhash.Hash) chainhash.Hash {
+	for len(hashes) > 1 {
+		if len(hashes)%2 == 1 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+
+		next := make([]chainhash.Hash, len(hashes)/2)
+		for i := 0; i < len(hashes); i += 2 {
+			next[i/2] = blockchain.HashMerkleBranches(&hashes[i], &hashes[i+1])
+		}
+		hashes = next
+	}
+
+	return hashes[0]
+}
+
+// solveBlockHeader searches a nonce for which the header hash meets the target - on regtest this takes only a few tries
+func solveBlockHeader(header *wire.BlockHeader) error {
+	target := blockchain.CompactToBig(header.Bits)
+
+	for nonce := uint32(0); nonce < math.MaxUint32; nonce++ {
+		header.Nonce = nonce
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return nil
+		}
+	}
+
+	return errors.New("failed to find nonce for block header")
+}
@@ -0,0 +1,302 @@
+package node_client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectTxs(t *testing.T) {
+	txs := []templateTx{
+		{id: "a", size: 300},
+		{id: "b", size: 300, depends: []string{"a"}},
+		{id: "c", size: 300},
+		{id: "d", size: 300, depends: []string{"b", "confirmed"}},
+	}
+
+	tt := []struct {
+		name         string
+		maxSizeBytes uint64
+		include      func(txID string) bool
+
+		expectedTxIDs []string
+	}{
+		{
+			name:    "all txs",
+			include: func(_ string) bool { return true },
+
+			expectedTxIDs: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:         "max size",
+			maxSizeBytes: blockOverheadBytes + 700,
+			include:      func(_ string) bool { return true },
+
+			expectedTxIDs: []string{"a", "b"},
+		},
+		{
+			name:    "parent excluded",
+			include: func(txID string) bool { return txID != "a" },
+
+			expectedTxIDs: []string{"c"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			selected := selectTxs(txs, tc.maxSizeBytes, tc.include)
+
+			txIDs := make([]string, len(selected))
+			for i, tx := range selected {
+				txIDs[i] = tx.id
+			}
+
+			require.Equal(t, tc.expectedTxIDs, txIDs)
+		})
+	}
+}
+
+type rpcClientMock struct {
+	RPCClient
+
+	rawMempool    []string
+	rawMempoolErr error
+}
+
+func (c *rpcClientMock) GetRawMempool() ([]string, error) {
+	return c.rawMempool, c.rawMempoolErr
+}
+
+func TestProcessor_pruneOwnTxs(t *testing.T) {
+	tt := []struct {
+		name          string
+		ownTxs        []string
+		rawMempool    []string
+		rawMempoolErr error
+
+		expectedOwnTxs []string
+		expectedErr    string
+	}{
+		{
+			name:       "txs missing from the template are kept",
+			ownTxs:     []string{"a", "b"},
+			rawMempool: []string{"a", "b", "c"},
+
+			expectedOwnTxs: []string{"a", "b"},
+		},
+		{
+			name:       "txs not in the mempool any more",
+			ownTxs:     []string{"a", "b"},
+			rawMempool: []string{"b", "c"},
+
+			expectedOwnTxs: []string{"b"},
+		},
+		{
+			name:          "no own txs",
+			rawMempoolErr: errors.New("rpc failed"),
+
+			expectedOwnTxs: []string{},
+		},
+		{
+			name:          "mempool not available",
+			ownTxs:        []string{"a"},
+			rawMempoolErr: errors.New("rpc failed"),
+
+			expectedOwnTxs: []string{"a"},
+			expectedErr:    "failed to get raw mempool: rpc failed",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sut := &Processor{
+				client: &rpcClientMock{rawMempool: tc.rawMempool, rawMempoolErr: tc.rawMempoolErr},
+				ownTxs: map[string]struct{}{},
+			}
+			for _, txID := range tc.ownTxs {
+				sut.ownTxs[txID] = struct{}{}
+			}
+
+			err := sut.pruneOwnTxs()
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			ownTxs := make([]string, 0, len(sut.ownTxs))
+			for txID := range sut.ownTxs {
+				ownTxs = append(ownTxs, txID)
+			}
+			require.ElementsMatch(t, tc.expectedOwnTxs, ownTxs)
+		})
+	}
+}
+
+func TestCalcMerkleRoot(t *testing.T) {
+	// Txs of mainnet block 100000
+	block100000 := []string{
+		"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+		"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+		"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+		"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+	}
+
+	tt := []struct {
+		name  string
+		txIDs []string
+
+		expectedMerkleRoot string
+	}{
+		{
+			name:  "1 tx - genesis block",
+			txIDs: []string{"4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"},
+
+			expectedMerkleRoot: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		},
+		{
+			name: "2 txs - block 170",
+			txIDs: []string{
+				"b1fea52486ce0c62bb442b530a3f0132b826c74e473d1f2c220bfa78111c5082",
+				"f4184fc596403b9d638783cf57adfe4c75c605f6356fbc91338530e9831e9e16",
+			},
+
+			expectedMerkleRoot: "7dac2c5666815c17a3b36427de37bb9d2e2c5ccec3f8633eb91a4205cb4c10ff",
+		},
+		{
+			name:  "3 txs - last hash duplicated",
+			txIDs: block100000[:3],
+
+			expectedMerkleRoot: "fa435470825de273081dcc706b25514c936fa6dc80ab965ce6970d68ddd0b553",
+		},
+		{
+			name:  "4 txs - block 100000",
+			txIDs: block100000,
+
+			expectedMerkleRoot: "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			hashes := make([]chainhash.Hash, len(tc.txIDs))
+			for i, txID := range tc.txIDs {
+				hash, err := chainhash.NewHashFromStr(txID)
+				require.NoError(t, err)
+				hashes[i] = *hash
+			}
+
+			merkleRoot := calcMerkleRoot(hashes)
+
+			require.Equal(t, tc.expectedMerkleRoot, merkleRoot.String())
+		})
+	}
+}
+
+func newTemplateTx(t *testing.T, index uint32, fee int64) templateTx {
+	t.Helper()
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, index), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, tx.SerializeNoWitness(buf))
+
+	return templateTx{
+		id:   tx.TxHash().String(),
+		data: hex.EncodeToString(buf.Bytes()),
+		size: uint64(buf.Len()),
+		fee:  fee,
+	}
+}
+
+func TestProcessor_buildBlock(t *testing.T) {
+	txs := []templateTx{newTemplateTx(t, 0, 100), newTemplateTx(t, 1, 200), newTemplateTx(t, 2, 300)}
+
+	template := &GetBlockTemplateResult{
+		Version:           0x20000000,
+		PreviousBlockHash: chaincfg.RegressionNetParams.GenesisHash.String(),
+		CoinbaseValue:     5000000000 + 600,
+		CurTime:           1700000000,
+		Bits:              "207fffff",
+		Height:            101,
+	}
+
+	tt := []struct {
+		name     string
+		selected []templateTx
+
+		expectedCoinbaseValue int64
+	}{
+		{
+			name:     "all txs",
+			selected: txs,
+
+			expectedCoinbaseValue: 5000000000 + 600,
+		},
+		{
+			name:     "tx dropped",
+			selected: []templateTx{txs[0], txs[2]},
+
+			expectedCoinbaseValue: 5000000000 + 600 - 200,
+		},
+		{
+			name: "empty block",
+
+			expectedCoinbaseValue: 5000000000,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			privKey, err := btcec.NewPrivateKey()
+			require.NoError(t, err)
+			sut := &Processor{privKey: privKey}
+
+			blockHex, blockHash, err := sut.buildBlock(template, tc.selected, 600)
+			require.NoError(t, err)
+
+			blockBytes, err := hex.DecodeString(blockHex)
+			require.NoError(t, err)
+			block, err := btcutil.NewBlockFromBytes(blockBytes)
+			require.NoError(t, err)
+
+			require.Equal(t, blockHash, block.Hash().String())
+			require.Equal(t, template.PreviousBlockHash, block.MsgBlock().Header.PrevBlock.String())
+			require.Len(t, block.Transactions(), len(tc.selected)+1)
+			for i, tx := range tc.selected {
+				require.Equal(t, tx.id, block.Transactions()[i+1].Hash().String())
+			}
+
+			coinbase := block.MsgBlock().Transactions[0]
+			require.Len(t, coinbase.TxOut, 1)
+			require.Equal(t, tc.expectedCoinbaseValue, coinbase.TxOut[0].Value)
+
+			height, err := blockchain.ExtractCoinbaseHeight(block.Transactions()[0])
+			require.NoError(t, err)
+			require.Equal(t, int32(template.Height), height)
+
+			require.Equal(t, blockchain.CalcMerkleRoot(block.Transactions(), false), block.MsgBlock().Header.MerkleRoot)
+			require.NoError(t, blockchain.CheckProofOfWork(block, chaincfg.RegressionNetParams.PowLimit))
+		})
+	}
+}
+
+func TestSolveBlockHeader(t *testing.T) {
+	header := wire.NewBlockHeader(1, chaincfg.RegressionNetParams.GenesisHash, &chainhash.Hash{1}, 0x207fffff, 0)
+
+	require.NoError(t, solveBlockHeader(header))
+
+	require.NoError(t, blockchain.CheckProofOfWork(btcutil.NewBlock(wire.NewMsgBlock(header)), chaincfg.RegressionNetParams.PowLimit))
+}
@@ -0,0 +1,249 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// TopicHashBlock is the topic of the block hashes which is also used by the ZMQ interface of the nodes
+	TopicHashBlock = "hashblock"
+
+	MsgBlockAnnouncement = "Block announcement"
+
+	// ViaInv and ViaHeaders are the messages by which a block has been announced
+	ViaInv     = "inv"
+	ViaHeaders = "headers"
+
+	defaultReconnectInterval = 5 * time.Second
+)
+
+type announcement struct {
+	peer int
+	hash chainhash.Hash
+}
+
+// Observer connects as passive P2P peer to one or more nodes and records when each node announces a block. The blocks
+// announced by the first node are sent to the subscribers in the same format as the hashblock messages of ZMQ with the
+// time of arrival as additional element.
+type Observer struct {
+	addrs             []string
+	chainParams       *chaincfg.Params
+	handshakeTimeout  time.Duration
+	reconnectInterval time.Duration
+
+	logger   *slog.Logger
+	logAfter time.Time
+
+	mu          sync.Mutex
+	subscribers []chan []string
+	seen        map[announcement]struct{}
+	sequence    int64
+	wg          sync.WaitGroup
+}
+
+type ObserverOption func(o *Observer)
+
+// WithObserverChainParams sets the parameters of the network of the nodes - default is regtest
+func WithObserverChainParams(params *chaincfg.Params) ObserverOption {
+	return func(o *Observer) {
+		o.chainParams = params
+	}
+}
+
+// NewObserver creates an observer for the nodes with the given P2P addresses. The first node is the node whose blocks
+// are sent to the subscribers.
+func NewObserver(addrs []string, opts ...ObserverOption) (*Observer, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("at least one address has to be given")
+	}
+
+	o := &Observer{
+		addrs:             addrs,
+		chainParams:       &chaincfg.RegressionNetParams,
+		handshakeTimeout:  defaultHandshakeTimeout,
+		reconnectInterval: defaultReconnectInterval,
+		logger:            slog.Default(),
+		seen:              make(map[announcement]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o, nil
+}
+
+// Subscribe sends the blocks announced by the first node to the channel. The only topic is TopicHashBlock.
+func (o *Observer) Subscribe(topic string, ch chan []string) error {
+	if topic != TopicHashBlock {
+		return fmt.Errorf("topic %s not supported - has to be %s", topic, TopicHashBlock)
+	}
+
+	o.mu.Lock()
+	o.subscribers = append(o.subscribers, ch)
+	o.mu.Unlock()
+
+	return nil
+}
+
+// Start connects to the nodes and logs each block announcement after the given time until the context is canceled. An
+// error is returned if the first node cannot be connected, the other nodes are reconnected in the background.
+func (o *Observer) Start(ctx context.Context, logger *slog.Logger, logAfter time.Time) error {
+	o.logger = logger.With(slog.String("service", "p2p"))
+	o.logAfter = logAfter
+
+	for i, addr := range o.addrs {
+		p, err := o.connect(i, addr)
+		if err != nil {
+			if i == 0 {
+				return err
+			}
+			o.logger.Warn("Failed to connect to peer", "peer", addr, "err", err)
+		}
+
+		o.wg.Add(1)
+		go func() {
+			defer o.wg.Done()
+			o.observe(ctx, i, addr, p)
+		}()
+	}
+
+	return nil
+}
+
+// Wait waits until all peers have been disconnected after the context has been canceled
+func (o *Observer) Wait() {
+	o.wg.Wait()
+}
+
+// observe keeps the connection to the node until the context is canceled
+func (o *Observer) observe(ctx context.Context, index int, addr string, p *peer.Peer) {
+	for {
+		if p != nil {
+			disconnected := make(chan struct{})
+			go func() {
+				p.WaitForDisconnect()
+				close(disconnected)
+			}()
+
+			select {
+			case <-ctx.Done():
+				p.Disconnect()
+				<-disconnected
+				return
+			case <-disconnected:
+				o.logger.Warn("Peer disconnected", "peer", addr)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(o.reconnectInterval):
+		}
+
+		var err error
+		p, err = o.connect(index, addr)
+		if err != nil {
+			o.logger.Warn("Failed to connect to peer", "peer", addr, "err", err)
+		}
+	}
+}
+
+func (o *Observer) connect(index int, addr string) (*peer.Peer, error) {
+	verAck := make(chan struct{})
+
+	cfg := &peer.Config{
+		UserAgentName:    userAgentName,
+		UserAgentVersion: userAgentVersion,
+		ChainParams:      o.chainParams,
+		ProtocolVersion:  protocolVersion,
+		DisableRelayTx:   true,
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(_ *peer.Peer, _ *wire.MsgVerAck) {
+				close(verAck)
+			},
+			OnInv: func(_ *peer.Peer, msg *wire.MsgInv) {
+				at := time.Now()
+				for _, inv := range msg.InvList {
+					if inv.Type == wire.InvTypeBlock || inv.Type == wire.InvTypeWitnessBlock {
+						o.announce(index, inv.Hash, ViaInv, at)
+					}
+				}
+			},
+			OnHeaders: func(_ *peer.Peer, msg *wire.MsgHeaders) {
+				at := time.Now()
+				for _, header := range msg.Headers {
+					o.announce(index, header.BlockHash(), ViaHeaders, at)
+				}
+			},
+		},
+	}
+
+	p, err := peer.NewOutboundPeer(cfg, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, o.handshakeTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to peer %s: %w", addr, err)
+	}
+	p.AssociateConnection(conn)
+
+	select {
+	case <-verAck:
+	case <-time.After(o.handshakeTimeout):
+		p.Disconnect()
+		return nil, fmt.Errorf("handshake with peer %s timed out", addr)
+	}
+
+	// New blocks are announced by headers instead of inv messages
+	p.QueueMessage(wire.NewMsgSendHeaders(), nil)
+
+	o.logger.Info("Observing peer", "peer", addr, "user agent", p.UserAgent())
+
+	return p, nil
+}
+
+// announce logs the first announcement of the block by the node and sends it to the subscribers if the node is the
+// first node
+func (o *Observer) announce(index int, hash chainhash.Hash, via string, at time.Time) {
+	if at.Before(o.logAfter) {
+		return
+	}
+
+	o.mu.Lock()
+	key := announcement{peer: index, hash: hash}
+	if _, found := o.seen[key]; found {
+		o.mu.Unlock()
+		return
+	}
+	o.seen[key] = struct{}{}
+
+	var subscribers []chan []string
+	if index == 0 {
+		o.sequence++
+		subscribers = o.subscribers
+	}
+	sequence := o.sequence
+	o.mu.Unlock()
+
+	o.logger.Info(MsgBlockAnnouncement, "hash", hash.String(), "peer", o.addrs[index], "via", via, "timestamp", at.Format(time.RFC3339Nano))
+
+	for _, subscriber := range subscribers {
+		subscriber <- []string{TopicHashBlock, hash.String(), strconv.FormatInt(sequence, 10), at.Format(time.RFC3339Nano)}
+	}
+}
@@ -0,0 +1,57 @@
+package p2p
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserver_Start(t *testing.T) {
+	header := wire.NewBlockHeader(1, &chainhash.Hash{1}, &chainhash.Hash{2}, 0x207fffff, 0)
+	headers := wire.NewMsgHeaders()
+	require.NoError(t, headers.AddBlockHeader(header))
+
+	blockHash := header.BlockHash()
+	inv := wire.NewMsgInv()
+	require.NoError(t, inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &blockHash)))
+
+	// The block is announced twice by the node and once by the second node
+	addr1 := fakeNode(t, nil, headers, inv)
+	addr2 := fakeNode(t, nil, inv)
+
+	observer, err := NewObserver([]string{addr1, addr2})
+	require.NoError(t, err)
+
+	blocks := make(chan []string, 10)
+	require.NoError(t, observer.Subscribe(TopicHashBlock, blocks))
+	require.ErrorContains(t, observer.Subscribe("hashtx", blocks), "topic hashtx not supported")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, observer.Start(ctx, slog.Default(), time.Time{}))
+
+	select {
+	case block := <-blocks:
+		require.Equal(t, []string{TopicHashBlock, blockHash.String(), "1"}, block[:3])
+
+		arrivedAt, err := time.Parse(time.RFC3339Nano, block[3])
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), arrivedAt, time.Second)
+	case <-time.After(5 * time.Second):
+		t.Fatal("block not received")
+	}
+
+	require.Eventually(t, func() bool {
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+		return len(observer.seen) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, blocks)
+
+	cancel()
+	observer.Wait()
+}
@@ -0,0 +1,333 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// protocolVersion is below the version of wtxid relay so that txs are announced and requested by txid by both BTC
+	// and BSV nodes
+	protocolVersion = 70015
+
+	userAgentName    = "node-analysis"
+	userAgentVersion = "0.1.0"
+
+	defaultHandshakeTimeout = 10 * time.Second
+	defaultPendingTimeout   = time.Minute
+)
+
+// SubmitterStats are the txs announced to the node since the start
+type SubmitterStats struct {
+	// Announced is the number of txs announced by inv messages
+	Announced int64 `json:"announced"`
+	// Requested is the number of announced txs which have been requested by the node and sent
+	Requested int64 `json:"requested"`
+	// Rejected is the number of txs for which the node has sent a reject message
+	Rejected int64 `json:"rejected"`
+	// Expired is the number of announced txs which have not been requested within the pending timeout
+	Expired int64 `json:"expired"`
+	// RequestDelay is the sum of the times from the announcement until the request of the requested txs
+	RequestDelay time.Duration `json:"request_delay"`
+}
+
+type pendingTx struct {
+	tx          *wire.MsgTx
+	announcedAt time.Time
+}
+
+// Submitter submits txs to a node as P2P peer. Each tx is announced by an inv message and sent once the node requests it
+// by a getdata message. As the wire format of txs is the same, the submitter is used for BTC and BSV nodes.
+type Submitter struct {
+	addr             string
+	logger           *slog.Logger
+	chainParams      *chaincfg.Params
+	handshakeTimeout time.Duration
+	pendingTimeout   time.Duration
+
+	mu        sync.Mutex
+	onFailure func(hash string, err error)
+	startLoop sync.Once
+	quit      chan struct{}
+	peer      *peer.Peer
+	pending   map[chainhash.Hash]pendingTx
+	// announced contains the hashes of the pending txs in the order in which they have been announced
+	announced []chainhash.Hash
+	stats     SubmitterStats
+}
+
+type Option func(s *Submitter)
+
+// WithChainParams sets the parameters of the network of the node - default is regtest
+func WithChainParams(params *chaincfg.Params) Option {
+	return func(s *Submitter) {
+		s.chainParams = params
+	}
+}
+
+// WithPendingTimeout sets the time after which an announced tx which has not been requested by the node is dropped -
+// default is 1m
+func WithPendingTimeout(timeout time.Duration) Option {
+	return func(s *Submitter) {
+		s.pendingTimeout = timeout
+	}
+}
+
+// NewSubmitter creates a submitter for the node with the given P2P address e.g. localhost:18444
+func NewSubmitter(addr string, logger *slog.Logger, opts ...Option) *Submitter {
+	s := &Submitter{
+		addr:             addr,
+		logger:           logger.With(slog.String("service", "p2p"), slog.String("peer", addr)),
+		chainParams:      &chaincfg.RegressionNetParams,
+		handshakeTimeout: defaultHandshakeTimeout,
+		pendingTimeout:   defaultPendingTimeout,
+		pending:          make(map[chainhash.Hash]pendingTx),
+		quit:             make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// SetFailureHandler sets the function which is called for each announced tx which is not requested by the node within
+// the pending timeout or which is rejected by the node. As the submission of a tx returns once the tx is announced,
+// this is the only way in which these failures reach the caller.
+func (s *Submitter) SetFailureHandler(onFailure func(hash string, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onFailure = onFailure
+}
+
+// Connect connects to the node, waits for the handshake to complete and starts to expire the pending txs
+func (s *Submitter) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	s.startLoop.Do(func() { go s.expireLoop() })
+
+	return nil
+}
+
+// connect connects to the node. The mutex has to be held by the caller.
+func (s *Submitter) connect() error {
+	verAck := make(chan struct{})
+
+	cfg := &peer.Config{
+		UserAgentName:    userAgentName,
+		UserAgentVersion: userAgentVersion,
+		ChainParams:      s.chainParams,
+		ProtocolVersion:  protocolVersion,
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(_ *peer.Peer, _ *wire.MsgVerAck) {
+				close(verAck)
+			},
+			OnGetData: s.onGetData,
+			OnReject:  s.onReject,
+		},
+	}
+
+	p, err := peer.NewOutboundPeer(cfg, s.addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", s.addr, s.handshakeTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to peer %s: %w", s.addr, err)
+	}
+	p.AssociateConnection(conn)
+
+	select {
+	case <-verAck:
+	case <-time.After(s.handshakeTimeout):
+		p.Disconnect()
+		return fmt.Errorf("handshake with peer %s timed out", s.addr)
+	}
+
+	s.peer = p
+	s.logger.Info("Connected to peer", "user agent", p.UserAgent(), "protocol version", p.ProtocolVersion())
+
+	return nil
+}
+
+// SendRawTransaction announces the tx to the node and returns its hash. The tx is sent once the node requests it. If the
+// node does not request the tx within the pending timeout or rejects it, the failure handler is called.
+func (s *Submitter) SendRawTransaction(hexString string, _ bool) (*string, error) {
+	data, err := hex.DecodeString(hexString)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	err = tx.Deserialize(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tx: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.peer == nil || !s.peer.Connected() {
+		s.logger.Warn("Reconnecting to peer")
+
+		err = s.connect()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hash := tx.TxHash()
+	s.pending[hash] = pendingTx{tx: tx, announcedAt: time.Now()}
+	s.announced = append(s.announced, hash)
+
+	inv := wire.NewMsgInv()
+	err = inv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &hash))
+	if err != nil {
+		return nil, err
+	}
+	s.peer.QueueMessage(inv, nil)
+	s.stats.Announced++
+
+	hashString := hash.String()
+	return &hashString, nil
+}
+
+// Stats returns the txs announced to the node
+func (s *Submitter) Stats() SubmitterStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+// Close disconnects from the node. The txs which are still pending are not reported as failed.
+func (s *Submitter) Close() {
+	s.mu.Lock()
+	p := s.peer
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+	}
+	s.mu.Unlock()
+
+	if p == nil {
+		return
+	}
+
+	p.Disconnect()
+	p.WaitForDisconnect()
+}
+
+// expireLoop periodically expires the pending txs until the submitter is closed
+func (s *Submitter) expireLoop() {
+	ticker := time.NewTicker(max(s.pendingTimeout/10, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			expired := s.expire()
+			onFailure := s.onFailure
+			s.mu.Unlock()
+
+			for _, hash := range expired {
+				err := fmt.Errorf("tx not requested by peer within %s", s.pendingTimeout)
+				s.logger.Warn("Tx expired", "hash", hash.String(), "err", err)
+
+				if onFailure != nil {
+					onFailure(hash.String(), err)
+				}
+			}
+		}
+	}
+}
+
+// expire drops and returns the pending txs which have not been requested within the pending timeout. The mutex has to
+// be held by the caller.
+func (s *Submitter) expire() []chainhash.Hash {
+	var expired []chainhash.Hash
+
+	for len(s.announced) > 0 {
+		pending, found := s.pending[s.announced[0]]
+		if found && time.Since(pending.announcedAt) < s.pendingTimeout {
+			break
+		}
+
+		if found {
+			delete(s.pending, s.announced[0])
+			s.stats.Expired++
+			expired = append(expired, s.announced[0])
+		}
+		s.announced = s.announced[1:]
+	}
+
+	return expired
+}
+
+func (s *Submitter) onGetData(p *peer.Peer, msg *wire.MsgGetData) {
+	notFound := wire.NewMsgNotFound()
+
+	for _, inv := range msg.InvList {
+		if inv.Type != wire.InvTypeTx && inv.Type != wire.InvTypeWitnessTx {
+			_ = notFound.AddInvVect(inv)
+			continue
+		}
+
+		s.mu.Lock()
+		pending, found := s.pending[inv.Hash]
+		if found {
+			delete(s.pending, inv.Hash)
+			s.stats.Requested++
+			s.stats.RequestDelay += time.Since(pending.announcedAt)
+		}
+		s.mu.Unlock()
+
+		if !found {
+			_ = notFound.AddInvVect(inv)
+			continue
+		}
+
+		p.QueueMessageWithEncoding(pending.tx, nil, wire.BaseEncoding)
+	}
+
+	if len(notFound.InvList) > 0 {
+		p.QueueMessage(notFound, nil)
+	}
+}
+
+func (s *Submitter) onReject(_ *peer.Peer, msg *wire.MsgReject) {
+	s.mu.Lock()
+	s.stats.Rejected++
+	onFailure := s.onFailure
+	s.mu.Unlock()
+
+	err := fmt.Errorf("%s: %s", msg.Code.String(), msg.Reason)
+	s.logger.Warn("Tx rejected", "hash", msg.Hash.String(), "err", err, "cmd", msg.Cmd)
+
+	if onFailure != nil && msg.Cmd == wire.CmdTx {
+		onFailure(msg.Hash.String(), err)
+	}
+}
@@ -0,0 +1,149 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode accepts one peer, sends the announcements after the handshake and, if the channel is not nil, requests each
+// announced tx and sends the received txs to the channel
+func fakeNode(t *testing.T, received chan *wire.MsgTx, announcements ...wire.Message) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	btcNet := chaincfg.RegressionNetParams.Net
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		write := func(msg wire.Message) {
+			_ = wire.WriteMessage(conn, msg, protocolVersion, btcNet)
+		}
+
+		for {
+			msg, _, err := wire.ReadMessage(conn, protocolVersion, btcNet)
+			if err != nil {
+				if errors.Is(err, wire.ErrUnknownMessage) {
+					continue
+				}
+				return
+			}
+
+			switch m := msg.(type) {
+			case *wire.MsgVersion:
+				version := wire.NewMsgVersion(&m.AddrMe, &m.AddrYou, 1, 0)
+				version.ProtocolVersion = protocolVersion
+				write(version)
+				write(wire.NewMsgVerAck())
+			case *wire.MsgVerAck:
+				for _, announcement := range announcements {
+					write(announcement)
+				}
+			case *wire.MsgInv:
+				if received == nil {
+					continue
+				}
+
+				getData := wire.NewMsgGetData()
+				for _, inv := range m.InvList {
+					_ = getData.AddInvVect(inv)
+				}
+				write(getData)
+			case *wire.MsgTx:
+				received <- m
+			}
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func testTx(t *testing.T) (*wire.MsgTx, string) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), []byte{0x51}, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, tx.Serialize(&buf))
+
+	return tx, hex.EncodeToString(buf.Bytes())
+}
+
+func TestSubmitter_SendRawTransaction(t *testing.T) {
+	received := make(chan *wire.MsgTx, 1)
+	addr := fakeNode(t, received)
+
+	s := NewSubmitter(addr, slog.Default())
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	tx, txHex := testTx(t)
+
+	hash, err := s.SendRawTransaction(txHex, false)
+	require.NoError(t, err)
+	require.Equal(t, tx.TxHash().String(), *hash)
+
+	select {
+	case receivedTx := <-received:
+		require.Equal(t, tx.TxHash(), receivedTx.TxHash())
+	case <-time.After(5 * time.Second):
+		t.Fatal("tx not received")
+	}
+
+	stats := s.Stats()
+	require.Equal(t, int64(1), stats.Announced)
+	require.Equal(t, int64(1), stats.Requested)
+}
+
+func TestSubmitter_Expired(t *testing.T) {
+	// The node does not request the announced tx
+	addr := fakeNode(t, nil)
+
+	s := NewSubmitter(addr, slog.Default(), WithPendingTimeout(100*time.Millisecond))
+
+	type failure struct {
+		hash string
+		err  error
+	}
+	failures := make(chan failure, 1)
+	s.SetFailureHandler(func(hash string, err error) {
+		failures <- failure{hash: hash, err: err}
+	})
+
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	tx, txHex := testTx(t)
+
+	hash, err := s.SendRawTransaction(txHex, false)
+	require.NoError(t, err)
+
+	select {
+	case f := <-failures:
+		require.Equal(t, tx.TxHash().String(), f.hash)
+		require.Equal(t, *hash, f.hash)
+		require.ErrorContains(t, f.err, "tx not requested by peer within 100ms")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expired tx not reported")
+	}
+
+	stats := s.Stats()
+	require.Equal(t, int64(1), stats.Announced)
+	require.Equal(t, int64(0), stats.Requested)
+	require.Equal(t, int64(1), stats.Expired)
+}
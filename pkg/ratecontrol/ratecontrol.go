@@ -0,0 +1,309 @@
+package ratecontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+const (
+	// ModeNone keeps the configured rate
+	ModeNone = "none"
+	// ModeTarget adjusts the rate such that the mempool stays at the target
+	ModeTarget = "target"
+	// ModeSearch increases the rate until the mempool exceeds the target or txs are rejected and backs off - the
+	// highest rate without congestion is the maximum sustainable rate
+	ModeSearch = "search"
+
+	MsgRateAdjusted = "Rate adjusted"
+
+	defaultInterval     = 30 * time.Second
+	defaultTargetUsage  = 0.5
+	defaultMaxRejection = 0.05
+	defaultMinRate      = 1
+
+	// gain is the change of the rate relative to the distance of the mempool from the target in target mode
+	gain = 0.5
+	// backoff is the factor by which the rate is decreased on congestion
+	backoff = 0.7
+	// step is the relative increase of the rate per interval in search mode
+	step = 0.1
+	// tolerance is the relative deviation of the achieved rate from the rate up to which the broadcaster is considered
+	// to keep up with the rate in search mode
+	tolerance = 0.1
+)
+
+var Modes = []string{ModeNone, ModeTarget, ModeSearch}
+
+type Broadcaster interface {
+	SetRate(rateTxsPerSecond int64) error
+	Status() broadcaster.Status
+}
+
+type Mempool interface {
+	GetMempoolInfo() (*node_client.GetMempoolInfoResult, error)
+}
+
+// Sample is the state of the mempool and the submissions observed at the end of an interval
+type Sample struct {
+	MempoolTxs int64
+	// Usage is the memory used by the mempool as fraction of its maximum size
+	Usage float64
+	// Rejection is the ratio of failed txs to all submitted txs during the interval. A tx is counted as failed once, after
+	// its last attempt has failed.
+	Rejection float64
+	// AchievedRate is the number of successfully submitted txs per second during the interval
+	AchievedRate float64
+}
+
+// Controller adjusts the rate of the broadcaster in each interval based on the mempool of the node and the
+// rejected txs
+type Controller struct {
+	mode        string
+	broadcaster Broadcaster
+	mempool     Mempool
+
+	interval     time.Duration
+	targetTxs    int64
+	targetUsage  float64
+	maxRejection float64
+	minRate      int64
+	maxRate      int64
+
+	lastTotal   int64
+	lastFailed  int64
+	lastSampled time.Time
+	sustainable atomic.Int64
+}
+
+type Option func(c *Controller)
+
+// WithInterval sets the interval in which the rate is adjusted - default is 30s
+func WithInterval(interval time.Duration) Option {
+	return func(c *Controller) {
+		c.interval = interval
+	}
+}
+
+// WithTargetTxs sets the target number of txs in the mempool. If given, it is used instead of the target usage.
+func WithTargetTxs(targetTxs int64) Option {
+	return func(c *Controller) {
+		c.targetTxs = targetTxs
+	}
+}
+
+// WithTargetUsage sets the target memory usage of the mempool as fraction of its maximum size - default is 0.5
+func WithTargetUsage(targetUsage float64) Option {
+	return func(c *Controller) {
+		c.targetUsage = targetUsage
+	}
+}
+
+// WithMaxRejection sets the ratio of rejected txs above which the rate is decreased - default is 0.05
+func WithMaxRejection(maxRejection float64) Option {
+	return func(c *Controller) {
+		c.maxRejection = maxRejection
+	}
+}
+
+// WithRateLimits sets the range within which the rate is adjusted. For a maximum rate of 0 the rate is not capped.
+func WithRateLimits(minRate int64, maxRate int64) Option {
+	return func(c *Controller) {
+		c.minRate = minRate
+		c.maxRate = maxRate
+	}
+}
+
+func New(mode string, b Broadcaster, mempool Mempool, opts ...Option) (*Controller, error) {
+	c := &Controller{
+		mode:         mode,
+		broadcaster:  b,
+		mempool:      mempool,
+		interval:     defaultInterval,
+		targetUsage:  defaultTargetUsage,
+		maxRejection: defaultMaxRejection,
+		minRate:      defaultMinRate,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	switch c.mode {
+	case ModeTarget, ModeSearch:
+	default:
+		return nil, fmt.Errorf("rate control mode %s not valid - has to be either %s or %s", c.mode, ModeTarget, ModeSearch)
+	}
+
+	if c.interval <= 0 {
+		return nil, errors.New("rate control interval has to be greater than 0")
+	}
+
+	if c.targetTxs <= 0 && (c.targetUsage <= 0 || c.targetUsage > 1) {
+		return nil, fmt.Errorf("target usage %.2f not valid - has to be within (0, 1]", c.targetUsage)
+	}
+
+	if c.minRate <= 0 || (c.maxRate > 0 && c.maxRate < c.minRate) {
+		return nil, fmt.Errorf("rate limits %d - %d not valid", c.minRate, c.maxRate)
+	}
+
+	return c, nil
+}
+
+// Start adjusts the rate in each interval after the start time until the context is canceled
+func (c *Controller) Start(ctx context.Context, startAt time.Time, logger *slog.Logger) {
+	logger = logger.With(slog.String("service", "ratecontrol"))
+
+	go func() {
+		startTimer := time.NewTimer(time.Until(startAt))
+		select {
+		case <-ctx.Done():
+			startTimer.Stop()
+			return
+		case <-startTimer.C:
+		}
+
+		status := c.broadcaster.Status()
+		c.lastTotal, c.lastFailed, c.lastSampled = status.TotalTxs, status.FailedTxs, time.Now()
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			sample, err := c.sample()
+			if err != nil {
+				logger.Error("Failed to sample mempool", "err", err)
+				continue
+			}
+
+			status := c.broadcaster.Status()
+			if status.Paused {
+				continue
+			}
+
+			rate, reason := c.next(status.Rate, sample)
+			if rate == status.Rate {
+				continue
+			}
+
+			err = c.broadcaster.SetRate(rate)
+			if err != nil {
+				logger.Error("Failed to set rate", "err", err)
+				continue
+			}
+
+			attrs := []any{
+				slog.String("mode", c.mode),
+				slog.String("reason", reason),
+				slog.Int64("old rate", status.Rate),
+				slog.Int64("rate", rate),
+				slog.Int64("mempool txs", sample.MempoolTxs),
+				slog.Float64("mempool usage", sample.Usage),
+				slog.Float64("rejection", sample.Rejection),
+				slog.Float64("achieved rate", sample.AchievedRate),
+			}
+			if c.mode == ModeSearch {
+				attrs = append(attrs, slog.Int64("max sustainable rate", c.sustainable.Load()))
+			}
+
+			logger.Info(MsgRateAdjusted, attrs...)
+		}
+	}()
+}
+
+// MaxSustainableRate returns the highest rate which has been achieved without congestion in search mode
+func (c *Controller) MaxSustainableRate() int64 {
+	return c.sustainable.Load()
+}
+
+// sample gets the state of the mempool, the ratio of rejected txs and the achieved rate since the last sample
+func (c *Controller) sample() (Sample, error) {
+	info, err := c.mempool.GetMempoolInfo()
+	if err != nil {
+		return Sample{}, err
+	}
+
+	s := Sample{MempoolTxs: info.Size}
+	if info.MaxMempool > 0 {
+		s.Usage = float64(info.Usage) / float64(info.MaxMempool)
+	}
+
+	now := time.Now()
+	status := c.broadcaster.Status()
+	submitted := status.TotalTxs - c.lastTotal
+	failed := status.FailedTxs - c.lastFailed
+
+	if submitted+failed > 0 {
+		s.Rejection = float64(failed) / float64(submitted+failed)
+	}
+
+	elapsed := now.Sub(c.lastSampled)
+	if !c.lastSampled.IsZero() && elapsed > 0 {
+		s.AchievedRate = float64(submitted) / elapsed.Seconds()
+	}
+
+	c.lastTotal, c.lastFailed, c.lastSampled = status.TotalTxs, status.FailedTxs, now
+
+	return s, nil
+}
+
+// load returns the size of the mempool relative to the target
+func (c *Controller) load(s Sample) float64 {
+	if c.targetTxs > 0 {
+		return float64(s.MempoolTxs) / float64(c.targetTxs)
+	}
+
+	return s.Usage / c.targetUsage
+}
+
+// next returns the rate for the next interval and the reason of the adjustment
+func (c *Controller) next(rate int64, s Sample) (int64, string) {
+	load := c.load(s)
+
+	var next float64
+	var reason string
+	switch {
+	case s.Rejection > c.maxRejection:
+		next, reason = float64(rate)*backoff, "rejections above maximum"
+	case c.mode == ModeSearch && load > 1:
+		next, reason = float64(rate)*backoff, "mempool above target"
+	case c.mode == ModeSearch && s.AchievedRate < float64(rate)*(1-tolerance):
+		// The broadcaster does not keep up with the rate e.g. as txs are submitted one after another, so a higher rate
+		// would not increase the throughput
+		next, reason = float64(rate), "achieved rate below rate"
+	case c.mode == ModeSearch:
+		if rate > c.sustainable.Load() {
+			c.sustainable.Store(rate)
+		}
+		next, reason = float64(rate)+max(float64(rate)*step, 1), "no congestion"
+	default:
+		// The rate is changed in proportion to the distance from the target but at most by half
+		factor := min(max(1+gain*(1-load), 0.5), 1.5)
+		next = float64(rate) * factor
+
+		reason = "mempool below target"
+		if load > 1 {
+			reason = "mempool above target"
+		}
+	}
+
+	adjusted := max(int64(math.Round(next)), c.minRate)
+	if c.maxRate > 0 {
+		adjusted = min(adjusted, c.maxRate)
+	}
+
+	return adjusted, reason
+}
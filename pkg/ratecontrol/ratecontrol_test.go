@@ -0,0 +1,202 @@
+package ratecontrol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+type broadcasterMock struct {
+	status broadcaster.Status
+}
+
+func (b *broadcasterMock) SetRate(rate int64) error {
+	b.status.Rate = rate
+	return nil
+}
+
+func (b *broadcasterMock) Status() broadcaster.Status {
+	return b.status
+}
+
+type mempoolMock struct {
+	info node_client.GetMempoolInfoResult
+}
+
+func (m *mempoolMock) GetMempoolInfo() (*node_client.GetMempoolInfoResult, error) {
+	return &m.info, nil
+}
+
+func TestController_Next(t *testing.T) {
+	tt := []struct {
+		name   string
+		mode   string
+		opts   []Option
+		rate   int64
+		sample Sample
+
+		expectedRate   int64
+		expectedReason string
+	}{
+		{
+			name:   "target - below target",
+			mode:   ModeTarget,
+			rate:   100,
+			sample: Sample{Usage: 0.25},
+
+			expectedRate:   125,
+			expectedReason: "mempool below target",
+		},
+		{
+			name:   "target - above target",
+			mode:   ModeTarget,
+			opts:   []Option{WithTargetTxs(1000)},
+			rate:   100,
+			sample: Sample{MempoolTxs: 1500, Usage: 0.01},
+
+			expectedRate:   75,
+			expectedReason: "mempool above target",
+		},
+		{
+			name:   "target - capped",
+			mode:   ModeTarget,
+			opts:   []Option{WithRateLimits(1, 120)},
+			rate:   100,
+			sample: Sample{Usage: 0},
+
+			expectedRate:   120,
+			expectedReason: "mempool below target",
+		},
+		{
+			name:   "target - rejections",
+			mode:   ModeTarget,
+			rate:   100,
+			sample: Sample{Usage: 0.1, Rejection: 0.2},
+
+			expectedRate:   70,
+			expectedReason: "rejections above maximum",
+		},
+		{
+			name:   "search - no congestion",
+			mode:   ModeSearch,
+			rate:   5,
+			sample: Sample{Usage: 0.1, AchievedRate: 4.8},
+
+			expectedRate:   6,
+			expectedReason: "no congestion",
+		},
+		{
+			name:   "search - achieved rate below rate",
+			mode:   ModeSearch,
+			rate:   1000,
+			sample: Sample{Usage: 0.1, AchievedRate: 300},
+
+			expectedRate:   1000,
+			expectedReason: "achieved rate below rate",
+		},
+		{
+			name:   "search - capped",
+			mode:   ModeSearch,
+			opts:   []Option{WithRateLimits(1, 100)},
+			rate:   100,
+			sample: Sample{Usage: 0.1, AchievedRate: 100},
+
+			expectedRate:   100,
+			expectedReason: "no congestion",
+		},
+		{
+			name:   "search - congestion",
+			mode:   ModeSearch,
+			rate:   100,
+			sample: Sample{Usage: 0.6},
+
+			expectedRate:   70,
+			expectedReason: "mempool above target",
+		},
+		{
+			name:   "search - minimum rate",
+			mode:   ModeSearch,
+			opts:   []Option{WithRateLimits(10, 0)},
+			rate:   10,
+			sample: Sample{Rejection: 0.5},
+
+			expectedRate:   10,
+			expectedReason: "rejections above maximum",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(tc.mode, &broadcasterMock{}, &mempoolMock{}, tc.opts...)
+			require.NoError(t, err)
+
+			rate, reason := c.next(tc.rate, tc.sample)
+
+			require.Equal(t, tc.expectedRate, rate)
+			require.Equal(t, tc.expectedReason, reason)
+		})
+	}
+}
+
+func TestController_Sample(t *testing.T) {
+	tt := []struct {
+		name      string
+		rate      int64
+		submitted int64
+		failed    int64
+
+		expectedRejection   float64
+		expectedAchieved    float64
+		expectedRate        int64
+		expectedSustainable int64
+	}{
+		{
+			name:      "rate achieved",
+			rate:      10,
+			submitted: 95,
+			failed:    5,
+
+			expectedRejection:   0.05,
+			expectedAchieved:    9.5,
+			expectedRate:        11,
+			expectedSustainable: 10,
+		},
+		{
+			name:      "broadcaster lags the rate",
+			rate:      1000,
+			submitted: 3000,
+
+			expectedAchieved: 300,
+			expectedRate:     1000,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &broadcasterMock{status: broadcaster.Status{Rate: tc.rate, TotalTxs: 100, FailedTxs: 5}}
+			m := &mempoolMock{info: node_client.GetMempoolInfoResult{Size: 300, Usage: 50, MaxMempool: 200}}
+
+			c, err := New(ModeSearch, b, m, WithMaxRejection(0.2))
+			require.NoError(t, err)
+			c.lastTotal, c.lastFailed, c.lastSampled = 100, 5, time.Now().Add(-10*time.Second)
+
+			b.status.TotalTxs += tc.submitted
+			b.status.FailedTxs += tc.failed
+
+			s, err := c.sample()
+			require.NoError(t, err)
+			require.Equal(t, int64(300), s.MempoolTxs)
+			require.Equal(t, 0.25, s.Usage)
+			require.InDelta(t, tc.expectedRejection, s.Rejection, 1e-9)
+			require.InDelta(t, tc.expectedAchieved, s.AchievedRate, tc.expectedAchieved*0.01)
+
+			rate, _ := c.next(tc.rate, s)
+			require.Equal(t, tc.expectedRate, rate)
+			require.Equal(t, tc.expectedSustainable, c.MaxSustainableRate())
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package router
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+const (
+	// PolicyRoundRobin sends the txs to the targets in turn
+	PolicyRoundRobin = "round-robin"
+	// PolicyRandom sends each tx to a random target
+	PolicyRandom = "random"
+	// PolicyWeighted sends each tx to a random target with a probability proportional to its weight
+	PolicyWeighted = "weighted"
+	// PolicyAll sends each tx to all targets at the same time
+	PolicyAll = "all"
+)
+
+var Policies = []string{PolicyRoundRobin, PolicyRandom, PolicyWeighted, PolicyAll}
+
+// Target is a node to which txs are submitted
+type Target struct {
+	Name   string
+	Client node_client.Submitter
+	Weight float64
+}
+
+// TargetStats are the submissions to a target since the start
+type TargetStats struct {
+	Name      string        `json:"name"`
+	Submitted int64         `json:"submitted"`
+	Failed    int64         `json:"failed"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Router submits txs to several nodes according to a routing policy
+type Router struct {
+	policy  string
+	targets []Target
+	sticky  bool
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	next   int
+	stats  []TargetStats
+	routed map[string]int
+}
+
+type Option func(r *Router)
+
+// WithRand sets the source of randomness from which the targets of the random and weighted policies are drawn
+func WithRand(rng *rand.Rand) Option {
+	return func(r *Router) {
+		r.rng = rng
+	}
+}
+
+// WithSticky sends a tx which spends an output of a tx submitted by the router to the same target as that tx, so that
+// chains of unconfirmed txs are not rejected by targets which have not yet received the parent tx. Only the first tx of
+// a chain is routed by the policy.
+func WithSticky() Option {
+	return func(r *Router) {
+		r.sticky = true
+	}
+}
+
+func New(policy string, targets []Target, opts ...Option) (*Router, error) {
+	r := &Router{
+		policy:  policy,
+		targets: targets,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		stats:   make([]TargetStats, len(targets)),
+		routed:  make(map[string]int),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if !slices.Contains(Policies, r.policy) {
+		return nil, fmt.Errorf("routing policy %s not valid - has to be one of %v", r.policy, Policies)
+	}
+
+	if len(r.targets) == 0 {
+		return nil, errors.New("at least one target has to be given")
+	}
+
+	var totalWeight float64
+	for i, target := range r.targets {
+		if target.Weight < 0 {
+			return nil, fmt.Errorf("weight of target %s must not be negative", target.Name)
+		}
+		totalWeight += target.Weight
+		r.stats[i].Name = target.Name
+	}
+
+	if r.policy == PolicyWeighted && totalWeight == 0 {
+		return nil, errors.New("at least one target has to have a weight greater than 0")
+	}
+
+	return r, nil
+}
+
+// SendRawTransaction submits the tx to the target selected by the routing policy. With policy all the tx is submitted
+// to all targets and an error is only returned if no target has accepted it.
+func (r *Router) SendRawTransaction(hexString string, isBSV bool) (*string, error) {
+	if r.policy == PolicyAll {
+		return r.sendAll(hexString, isBSV)
+	}
+
+	var txID, parentID string
+	if r.sticky {
+		txID, parentID = txIDs(hexString)
+	}
+
+	r.mu.Lock()
+	index, found := r.routed[parentID]
+	if found {
+		delete(r.routed, parentID)
+	} else {
+		index = r.selectTarget()
+	}
+	r.mu.Unlock()
+
+	hash, err := r.send(index, hexString, isBSV)
+	if err != nil {
+		if found {
+			// The tx is retried at the same target
+			r.mu.Lock()
+			r.routed[parentID] = index
+			r.mu.Unlock()
+		}
+
+		return nil, fmt.Errorf("%s: %w", r.targets[index].Name, err)
+	}
+
+	if r.sticky && txID != "" {
+		r.mu.Lock()
+		r.routed[txID] = index
+		r.mu.Unlock()
+	}
+
+	return hash, nil
+}
+
+// SendConflicting submits two conflicting txs to two different targets at the same time. The first target is selected by
+// the routing policy, the second target is the next target after it. The names of the targets are returned together with
+// the error of each submission.
+func (r *Router) SendConflicting(hexStrings [2]string, isBSV bool) (targets [2]string, errs [2]error) {
+	if len(r.targets) < 2 {
+		errs[0] = errors.New("at least two targets are required for conflicting txs")
+		errs[1] = errs[0]
+		return targets, errs
+	}
+
+	r.mu.Lock()
+	first := 0
+	if r.policy != PolicyAll {
+		first = r.selectTarget()
+	}
+	r.mu.Unlock()
+
+	indices := [2]int{first, (first + 1) % len(r.targets)}
+
+	var wg sync.WaitGroup
+	for i, index := range indices {
+		targets[i] = r.targets[index].Name
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, errs[i] = r.send(index, hexStrings[i], isBSV)
+		}()
+	}
+	wg.Wait()
+
+	return targets, errs
+}
+
+// Stats returns the submissions to each target
+func (r *Router) Stats() []TargetStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.stats)
+}
+
+func (r *Router) sendAll(hexString string, isBSV bool) (*string, error) {
+	hashes := make([]*string, len(r.targets))
+	errs := make([]error, len(r.targets))
+
+	var wg sync.WaitGroup
+	for i := range r.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			hashes[i], errs[i] = r.send(i, hexString, isBSV)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", r.targets[i].Name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, hash := range hashes {
+		if hash != nil {
+			return hash, nil
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// send submits the tx to the target with the given index and records the submission
+func (r *Router) send(index int, hexString string, isBSV bool) (*string, error) {
+	start := time.Now()
+	hash, err := r.targets[index].Client.SendRawTransaction(hexString, isBSV)
+	duration := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats[index].Duration += duration
+	if err != nil {
+		r.stats[index].Failed++
+		return nil, err
+	}
+	r.stats[index].Submitted++
+
+	return hash, nil
+}
+
+// selectTarget returns the index of the target of the next tx. The mutex has to be held by the caller.
+func (r *Router) selectTarget() int {
+	switch r.policy {
+	case PolicyRandom:
+		return r.rng.Intn(len(r.targets))
+	case PolicyWeighted:
+		var totalWeight float64
+		for _, target := range r.targets {
+			totalWeight += target.Weight
+		}
+
+		x := r.rng.Float64() * totalWeight
+		for i, target := range r.targets {
+			x -= target.Weight
+			if x < 0 {
+				return i
+			}
+		}
+
+		return len(r.targets) - 1
+	default:
+		index := r.next
+		r.next = (r.next + 1) % len(r.targets)
+		return index
+	}
+}
+
+// txIDs returns the ID of the tx and the ID of the tx whose output is spent by its first input
+func txIDs(hexString string) (txID string, parentID string) {
+	data, err := hex.DecodeString(hexString)
+	if err != nil {
+		return "", ""
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	err = tx.Deserialize(bytes.NewReader(data))
+	if err != nil || len(tx.TxIn) == 0 {
+		return "", ""
+	}
+
+	return tx.TxHash().String(), tx.TxIn[0].PreviousOutPoint.Hash.String()
+}
@@ -0,0 +1,180 @@
+package router
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+type submitterMock struct {
+	mu  sync.Mutex
+	txs []string
+	err error
+}
+
+func (s *submitterMock) SendRawTransaction(hexString string, _ bool) (*string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.txs = append(s.txs, hexString)
+
+	return &hexString, nil
+}
+
+// newTx returns a tx spending the first output of the given parent tx and its hex string
+func newTx(t *testing.T, parent chainhash.Hash) (chainhash.Hash, string) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&parent, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, tx.Serialize(&buf))
+
+	return tx.TxHash(), hex.EncodeToString(buf.Bytes())
+}
+
+func TestRouter_Policies(t *testing.T) {
+	tt := []struct {
+		name    string
+		policy  string
+		weights []float64
+
+		expectedCounts []int
+	}{
+		{
+			name:    "round-robin",
+			policy:  PolicyRoundRobin,
+			weights: []float64{0, 0, 0},
+
+			expectedCounts: []int{4, 4, 4},
+		},
+		{
+			name:    "weighted",
+			policy:  PolicyWeighted,
+			weights: []float64{1, 0, 2},
+
+			expectedCounts: []int{3, 0, 9},
+		},
+		{
+			name:    "all",
+			policy:  PolicyAll,
+			weights: []float64{0, 0, 0},
+
+			expectedCounts: []int{12, 12, 12},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			submitters := make([]*submitterMock, len(tc.weights))
+			targets := make([]Target, len(tc.weights))
+			for i, weight := range tc.weights {
+				submitters[i] = &submitterMock{}
+				targets[i] = Target{Name: string(rune('a' + i)), Client: submitters[i], Weight: weight}
+			}
+
+			r, err := New(tc.policy, targets, WithRand(rand.New(rand.NewSource(4))))
+			require.NoError(t, err)
+
+			for range 12 {
+				_, err = r.SendRawTransaction("00", false)
+				require.NoError(t, err)
+			}
+
+			for i, submitter := range submitters {
+				require.Len(t, submitter.txs, tc.expectedCounts[i], "target %d", i)
+				require.Equal(t, int64(tc.expectedCounts[i]), r.Stats()[i].Submitted)
+			}
+		})
+	}
+}
+
+func TestRouter_Sticky(t *testing.T) {
+	a, b := &submitterMock{}, &submitterMock{}
+	r, err := New(PolicyRoundRobin, []Target{{Name: "a", Client: a}, {Name: "b", Client: b}}, WithSticky())
+	require.NoError(t, err)
+
+	// Two chains of three txs each starting from confirmed outputs
+	parents := []chainhash.Hash{{1}, {2}}
+	for range 3 {
+		for i, parent := range parents {
+			var txHex string
+			parents[i], txHex = newTx(t, parent)
+
+			_, err = r.SendRawTransaction(txHex, false)
+			require.NoError(t, err)
+		}
+	}
+
+	require.Len(t, a.txs, 3)
+	require.Len(t, b.txs, 3)
+
+	_, parentA := txIDs(a.txs[2])
+	require.Equal(t, parentA, mustTxID(t, a.txs[1]))
+}
+
+func TestRouter_AllFailed(t *testing.T) {
+	a, b := &submitterMock{err: errors.New("missing inputs")}, &submitterMock{}
+	r, err := New(PolicyAll, []Target{{Name: "a", Client: a}, {Name: "b", Client: b}})
+	require.NoError(t, err)
+
+	_, err = r.SendRawTransaction("00", false)
+	require.NoError(t, err)
+
+	b.err = errors.New("txn-mempool-conflict")
+	_, err = r.SendRawTransaction("00", false)
+	require.ErrorContains(t, err, "a: missing inputs\nb: txn-mempool-conflict")
+
+	require.Equal(t, []TargetStats{{Name: "a", Failed: 2}, {Name: "b", Submitted: 1, Failed: 1}}, zeroDurations(r.Stats()))
+}
+
+func TestRouter_SendConflicting(t *testing.T) {
+	a, b, c := &submitterMock{}, &submitterMock{err: errors.New("txn-mempool-conflict")}, &submitterMock{}
+	r, err := New(PolicyRoundRobin, []Target{{Name: "a", Client: a}, {Name: "b", Client: b}, {Name: "c", Client: c}})
+	require.NoError(t, err)
+
+	targets, errs := r.SendConflicting([2]string{"01", "02"}, true)
+	require.Equal(t, [2]string{"a", "b"}, targets)
+	require.NoError(t, errs[0])
+	require.ErrorContains(t, errs[1], "txn-mempool-conflict")
+
+	targets, errs = r.SendConflicting([2]string{"03", "04"}, true)
+	require.Equal(t, [2]string{"b", "c"}, targets)
+	require.Error(t, errs[0])
+	require.NoError(t, errs[1])
+
+	targets, _ = r.SendConflicting([2]string{"05", "06"}, true)
+	require.Equal(t, [2]string{"c", "a"}, targets)
+
+	require.Equal(t, []string{"01", "06"}, a.txs)
+	require.Equal(t, []string{"04", "05"}, c.txs)
+
+	r, err = New(PolicyRoundRobin, []Target{{Name: "a", Client: a}})
+	require.NoError(t, err)
+
+	_, errs = r.SendConflicting([2]string{"07", "08"}, true)
+	require.ErrorContains(t, errs[0], "at least two targets")
+}
+
+func mustTxID(t *testing.T, txHex string) string {
+	txID, _ := txIDs(txHex)
+	require.NotEmpty(t, txID)
+	return txID
+}
+
+func zeroDurations(stats []TargetStats) []TargetStats {
+	for i := range stats {
+		stats[i].Duration = 0
+	}
+	return stats
+}
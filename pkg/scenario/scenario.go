@@ -0,0 +1,574 @@
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/clock"
+	"github.com/boecklim/node-analysis/pkg/miner"
+	"github.com/boecklim/node-analysis/pkg/node_client"
+	"github.com/boecklim/node-analysis/pkg/ratecontrol"
+	"github.com/boecklim/node-analysis/pkg/router"
+	"github.com/boecklim/node-analysis/pkg/topology"
+)
+
+const (
+	BlockchainBTC = "btc"
+	BlockchainBSV = "bsv"
+
+	// SubmitRPC submits txs via the sendrawtransaction RPC
+	SubmitRPC = "rpc"
+	// SubmitP2P submits txs as P2P peer of the node
+	SubmitP2P = "p2p"
+
+	// BlockSourceZMQ receives the blocks of a node via its ZMQ interface
+	BlockSourceZMQ = "zmq"
+	// BlockSourceP2P receives the blocks of a node as P2P peer
+	BlockSourceP2P = "p2p"
+
+	// TxSelfPaying is a tx which spends a single output to a single output paying to the same key
+	TxSelfPaying = "self-paying"
+
+	defaultHost    = "localhost"
+	defaultRPCPort = 18443
+	defaultZMQPort = 29000
+	defaultP2PPort = 18444
+	defaultMaxSkew = 2 * time.Second
+
+	defaultDrainTimeout = time.Minute
+)
+
+// Scenario describes a full experiment. The same scenario can be run locally for all nodes at once or on each remote
+// instance for one node only.
+type Scenario struct {
+	Name        string             `yaml:"name"`
+	Blockchain  string             `yaml:"blockchain"`
+	StartAt     string             `yaml:"start_at"`
+	Wait        time.Duration      `yaml:"wait"`
+	Duration    time.Duration      `yaml:"duration"`
+	Seed        int64              `yaml:"seed"`
+	Arrival     string             `yaml:"arrival"`
+	Submit      string             `yaml:"submit"`
+	BlockSource string             `yaml:"block_source"`
+	TxMix       map[string]float64 `yaml:"tx_mix"`
+	Miner       Miner              `yaml:"miner"`
+	Output      Output             `yaml:"output"`
+	Clock       Clock              `yaml:"clock"`
+	Drain       Drain              `yaml:"drain"`
+	RateControl RateControl        `yaml:"rate_control"`
+	Nodes       []Node             `yaml:"nodes"`
+	Topology    Topology           `yaml:"topology"`
+	Events      []Event            `yaml:"events"`
+}
+
+// Topology is the graph by which the nodes are connected to each other. For an empty graph the connections of the
+// nodes are not changed.
+type Topology struct {
+	Graph  string `yaml:"graph"`
+	Degree int    `yaml:"degree"`
+}
+
+// Node is a node to which txs are broadcast. Wait and miner override the settings of the scenario if given.
+type Node struct {
+	Name        string         `yaml:"name"`
+	Host        string         `yaml:"host"`
+	RPCPort     int            `yaml:"rpc_port"`
+	ZMQPort     int            `yaml:"zmq_port"`
+	P2PAddr     string         `yaml:"p2p_addr"`
+	Rate        int64          `yaml:"rate"`
+	RateProfile []RateStep     `yaml:"rate_profile"`
+	Wait        *time.Duration `yaml:"wait"`
+	Miner       *Miner         `yaml:"miner"`
+	MetricsAddr string         `yaml:"metrics_addr"`
+	APIAddr     string         `yaml:"api_addr"`
+	Route       *Route         `yaml:"route"`
+}
+
+// Route sends the txs of a node to the given nodes of the scenario instead of the node itself. The given ratio of the
+// utxos is double spent by two txs sent to two different targets at the same time.
+type Route struct {
+	Policy           string        `yaml:"policy"`
+	Targets          []RouteTarget `yaml:"targets"`
+	Sticky           *bool         `yaml:"sticky"`
+	DoubleSpendRatio float64       `yaml:"double_spend_ratio"`
+}
+
+// RouteTarget is a node of the scenario to which txs are routed. The weight is only used by the weighted policy.
+type RouteTarget struct {
+	Node   string  `yaml:"node"`
+	Weight float64 `yaml:"weight"`
+}
+
+// RateStep changes the rate of txs per second at the given time after the start
+type RateStep struct {
+	At   time.Duration `yaml:"at"`
+	Rate int64         `yaml:"rate"`
+}
+
+// Miner describes the simulated miner of a node. For an interval of 0 the node does not generate blocks.
+type Miner struct {
+	Interval           time.Duration `yaml:"interval"`
+	Strategy           string        `yaml:"strategy"`
+	WithholdDelay      time.Duration `yaml:"withhold_delay"`
+	SelfishLead        int           `yaml:"selfish_lead"`
+	SelfishMaxWithhold time.Duration `yaml:"selfish_max_withhold"`
+	Retarget           Retarget      `yaml:"retarget"`
+	Block              Block         `yaml:"block"`
+}
+
+type Retarget struct {
+	Mode   string        `yaml:"mode"`
+	Window int           `yaml:"window"`
+	Target time.Duration `yaml:"target"`
+}
+
+type Block struct {
+	Empty   bool   `yaml:"empty"`
+	MaxSize uint64 `yaml:"max_size"`
+	Txs     string `yaml:"txs"`
+}
+
+// Clock describes the maximum offset of the local clock against the node and what happens if it is exceeded. For a
+// maximum skew of 0 the offset is not measured.
+type Clock struct {
+	MaxSkew    *time.Duration `yaml:"max_skew"`
+	SkewAction string         `yaml:"skew_action"`
+}
+
+// Drain describes how long the nodes keep listening after submission has stopped. Each node waits for the given number
+// of blocks so that in-flight txs are confirmed, but at most for the timeout.
+type Drain struct {
+	Blocks  int64         `yaml:"blocks"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RateControl describes how the rate of each node is adjusted based on the mempool of the node and rejected txs. For
+// mode none or an empty mode the rate is not adjusted.
+type RateControl struct {
+	Mode         string        `yaml:"mode"`
+	Interval     time.Duration `yaml:"interval"`
+	TargetTxs    int64         `yaml:"target_txs"`
+	TargetUsage  float64       `yaml:"target_usage"`
+	MaxRejection float64       `yaml:"max_rejection"`
+	MinRate      int64         `yaml:"min_rate"`
+	MaxRate      int64         `yaml:"max_rate"`
+}
+
+// Output describes where the output of each node is written. The output of a node is written to <dir>/<node name>.log.
+type Output struct {
+	Dir            string `yaml:"dir"`
+	LogSubmissions bool   `yaml:"log_submissions"`
+}
+
+// Load reads a scenario from a YAML or JSON file, applies the defaults and validates it
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse parses a scenario in YAML or JSON format, applies the defaults and validates it. Unknown fields are rejected.
+func Parse(data []byte) (*Scenario, error) {
+	s := &Scenario{}
+
+	// JSON is a subset of YAML so that both formats can be decoded by the YAML decoder
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %v", err)
+	}
+
+	s.applyDefaults()
+
+	err = s.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *Scenario) applyDefaults() {
+	if s.Arrival == "" {
+		s.Arrival = broadcaster.ArrivalConstant
+	}
+
+	if s.Submit == "" {
+		s.Submit = SubmitRPC
+	}
+	if s.BlockSource == "" {
+		s.BlockSource = BlockSourceZMQ
+	}
+
+	if len(s.TxMix) == 0 {
+		s.TxMix = map[string]float64{TxSelfPaying: 1}
+	}
+
+	s.Miner.applyDefaults()
+
+	if s.Clock.MaxSkew == nil {
+		maxSkew := defaultMaxSkew
+		s.Clock.MaxSkew = &maxSkew
+	}
+	if s.Clock.SkewAction == "" {
+		s.Clock.SkewAction = clock.ActionWarn
+	}
+
+	if s.Drain.Timeout == 0 {
+		s.Drain.Timeout = defaultDrainTimeout
+	}
+
+	s.RateControl.applyDefaults()
+
+	for i := range s.Nodes {
+		node := &s.Nodes[i]
+		if node.Host == "" {
+			node.Host = defaultHost
+		}
+		if node.RPCPort == 0 {
+			node.RPCPort = defaultRPCPort
+		}
+		if node.ZMQPort == 0 {
+			node.ZMQPort = defaultZMQPort
+		}
+		if node.P2PAddr == "" {
+			node.P2PAddr = net.JoinHostPort(node.Host, strconv.Itoa(defaultP2PPort))
+		}
+		if node.Miner != nil {
+			node.Miner.applyDefaults()
+		}
+		if node.Route != nil {
+			node.Route.applyDefaults()
+		}
+	}
+}
+
+func (m *Miner) applyDefaults() {
+	if m.Strategy == "" {
+		m.Strategy = miner.StrategyHonest
+	}
+	if m.WithholdDelay == 0 {
+		m.WithholdDelay = 30 * time.Second
+	}
+	if m.SelfishLead == 0 {
+		m.SelfishLead = 2
+	}
+	if m.Retarget.Mode == "" {
+		m.Retarget.Mode = miner.RetargetNone
+	}
+	if m.Block.Txs == "" {
+		m.Block.Txs = node_client.TxsAll
+	}
+}
+
+// Validate checks all fields of the scenario and returns all violations
+func (s *Scenario) Validate() error {
+	var errs []error
+
+	switch s.Blockchain {
+	case BlockchainBTC, BlockchainBSV:
+	default:
+		errs = append(errs, fmt.Errorf("blockchain %q not valid - has to be either %s or %s", s.Blockchain, BlockchainBTC, BlockchainBSV))
+	}
+
+	if s.StartAt != "" {
+		_, err := time.Parse(time.RFC3339, s.StartAt)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("start_at %q not valid - has to be in RFC3339 format", s.StartAt))
+		}
+	}
+
+	if s.Duration <= 0 {
+		errs = append(errs, errors.New("duration has to be greater than 0"))
+	}
+
+	if s.Wait < 0 {
+		errs = append(errs, errors.New("wait must not be negative"))
+	}
+
+	switch s.Arrival {
+	case broadcaster.ArrivalConstant, broadcaster.ArrivalPoisson:
+	default:
+		errs = append(errs, fmt.Errorf("arrival %q not valid - has to be either %s or %s", s.Arrival, broadcaster.ArrivalConstant, broadcaster.ArrivalPoisson))
+	}
+
+	for txType, weight := range s.TxMix {
+		if txType != TxSelfPaying {
+			errs = append(errs, fmt.Errorf("tx_mix: tx type %q not supported", txType))
+		}
+		if weight <= 0 {
+			errs = append(errs, fmt.Errorf("tx_mix: weight of %s has to be greater than 0", txType))
+		}
+	}
+
+	errs = append(errs, s.Miner.validate("miner")...)
+
+	if *s.Clock.MaxSkew < 0 {
+		errs = append(errs, errors.New("clock: max_skew must not be negative"))
+	}
+
+	switch s.Clock.SkewAction {
+	case clock.ActionWarn, clock.ActionRefuse:
+	default:
+		errs = append(errs, fmt.Errorf("clock: skew_action %q not valid - has to be either %s or %s", s.Clock.SkewAction, clock.ActionWarn, clock.ActionRefuse))
+	}
+
+	if s.Drain.Blocks < 0 {
+		errs = append(errs, errors.New("drain: blocks must not be negative"))
+	}
+	if s.Drain.Timeout < 0 {
+		errs = append(errs, errors.New("drain: timeout must not be negative"))
+	}
+
+	errs = append(errs, s.RateControl.validate("rate_control")...)
+
+	switch s.Submit {
+	case SubmitRPC, SubmitP2P:
+	default:
+		errs = append(errs, fmt.Errorf("submit %q not valid - has to be either %s or %s", s.Submit, SubmitRPC, SubmitP2P))
+	}
+
+	switch s.BlockSource {
+	case BlockSourceZMQ, BlockSourceP2P:
+	default:
+		errs = append(errs, fmt.Errorf("block_source %q not valid - has to be either %s or %s", s.BlockSource, BlockSourceZMQ, BlockSourceP2P))
+	}
+
+	if len(s.Nodes) == 0 {
+		errs = append(errs, errors.New("at least one node has to be given"))
+	}
+
+	names := map[string]struct{}{}
+	for i, node := range s.Nodes {
+		field := fmt.Sprintf("nodes[%d]", i)
+
+		if node.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name has to be given", field))
+		}
+		if _, found := names[node.Name]; found {
+			errs = append(errs, fmt.Errorf("%s: name %q is not unique", field, node.Name))
+		}
+		names[node.Name] = struct{}{}
+
+		startsWithProfile := len(node.RateProfile) > 0 && node.RateProfile[0].At == 0
+		if node.Rate <= 0 && !startsWithProfile {
+			errs = append(errs, fmt.Errorf("%s: rate has to be greater than 0", field))
+		}
+
+		for j, step := range node.RateProfile {
+			if step.Rate <= 0 {
+				errs = append(errs, fmt.Errorf("%s.rate_profile[%d]: rate has to be greater than 0", field, j))
+			}
+			if step.At < 0 || step.At >= s.Duration {
+				errs = append(errs, fmt.Errorf("%s.rate_profile[%d]: at has to be within the duration", field, j))
+			}
+			if j > 0 && step.At <= node.RateProfile[j-1].At {
+				errs = append(errs, fmt.Errorf("%s.rate_profile[%d]: steps have to be in increasing order of at", field, j))
+			}
+		}
+
+		if node.Wait != nil && *node.Wait < 0 {
+			errs = append(errs, fmt.Errorf("%s: wait must not be negative", field))
+		}
+
+		if node.Miner != nil {
+			errs = append(errs, node.Miner.validate(field+".miner")...)
+		}
+	}
+
+	for i, node := range s.Nodes {
+		if node.Route != nil {
+			errs = append(errs, node.Route.validate(fmt.Sprintf("nodes[%d].route", i), names)...)
+		}
+	}
+
+	if s.Topology.Graph != "" {
+		_, err := s.Edges()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("topology: %w", err))
+		}
+	}
+
+	for i, event := range s.Events {
+		errs = append(errs, event.validate(fmt.Sprintf("events[%d]", i), s.Duration, names)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Miner) validate(field string) []error {
+	var errs []error
+
+	if m.Interval < 0 {
+		errs = append(errs, fmt.Errorf("%s: interval must not be negative", field))
+	}
+
+	switch m.Strategy {
+	case miner.StrategyHonest, miner.StrategySelfish, miner.StrategyWithhold:
+	default:
+		errs = append(errs, fmt.Errorf("%s: strategy %q not valid - has to be one of %s, %s or %s", field, m.Strategy, miner.StrategyHonest, miner.StrategySelfish, miner.StrategyWithhold))
+	}
+
+	switch m.Retarget.Mode {
+	case miner.RetargetNone, miner.RetargetBTC, miner.RetargetBSV:
+	default:
+		errs = append(errs, fmt.Errorf("%s.retarget: mode %q not valid - has to be one of %s, %s or %s", field, m.Retarget.Mode, miner.RetargetNone, miner.RetargetBTC, miner.RetargetBSV))
+	}
+
+	switch m.Block.Txs {
+	case node_client.TxsAll, node_client.TxsOwn, node_client.TxsForeign:
+	default:
+		errs = append(errs, fmt.Errorf("%s.block: txs %q not valid - has to be one of %s, %s or %s", field, m.Block.Txs, node_client.TxsAll, node_client.TxsOwn, node_client.TxsForeign))
+	}
+
+	return errs
+}
+
+func (r *Route) applyDefaults() {
+	if r.Policy == "" {
+		r.Policy = router.PolicyRoundRobin
+	}
+	if r.Sticky == nil {
+		sticky := true
+		r.Sticky = &sticky
+	}
+	for i := range r.Targets {
+		if r.Targets[i].Weight == 0 {
+			r.Targets[i].Weight = 1
+		}
+	}
+}
+
+func (r *Route) validate(field string, names map[string]struct{}) []error {
+	var errs []error
+
+	if !slices.Contains(router.Policies, r.Policy) {
+		errs = append(errs, fmt.Errorf("%s: policy %q not valid - has to be one of %v", field, r.Policy, router.Policies))
+	}
+
+	if len(r.Targets) == 0 {
+		errs = append(errs, fmt.Errorf("%s: at least one target has to be given", field))
+	}
+
+	for i, target := range r.Targets {
+		if _, found := names[target.Node]; !found {
+			errs = append(errs, fmt.Errorf("%s.targets[%d]: node %s not found", field, i, target.Node))
+		}
+		if target.Weight < 0 {
+			errs = append(errs, fmt.Errorf("%s.targets[%d]: weight must not be negative", field, i))
+		}
+	}
+
+	if r.DoubleSpendRatio < 0 || r.DoubleSpendRatio > 1 {
+		errs = append(errs, fmt.Errorf("%s: double_spend_ratio has to be within [0, 1]", field))
+	}
+	if r.DoubleSpendRatio > 0 && len(r.Targets) < 2 {
+		errs = append(errs, fmt.Errorf("%s: at least two targets have to be given for double spends", field))
+	}
+
+	return errs
+}
+
+func (r *RateControl) applyDefaults() {
+	if r.Mode == "" {
+		r.Mode = ratecontrol.ModeNone
+	}
+	if r.Interval == 0 {
+		r.Interval = 30 * time.Second
+	}
+	if r.TargetUsage == 0 {
+		r.TargetUsage = 0.5
+	}
+	if r.MaxRejection == 0 {
+		r.MaxRejection = 0.05
+	}
+	if r.MinRate == 0 {
+		r.MinRate = 1
+	}
+}
+
+func (r *RateControl) validate(field string) []error {
+	var errs []error
+
+	if !slices.Contains(ratecontrol.Modes, r.Mode) {
+		errs = append(errs, fmt.Errorf("%s: mode %q not valid - has to be one of %v", field, r.Mode, ratecontrol.Modes))
+	}
+
+	if r.Interval < 0 {
+		errs = append(errs, fmt.Errorf("%s: interval must not be negative", field))
+	}
+
+	if r.TargetUsage < 0 || r.TargetUsage > 1 {
+		errs = append(errs, fmt.Errorf("%s: target_usage has to be within (0, 1]", field))
+	}
+
+	if r.MinRate < 0 || (r.MaxRate != 0 && r.MaxRate < r.MinRate) {
+		errs = append(errs, fmt.Errorf("%s: max_rate must not be less than min_rate", field))
+	}
+
+	return errs
+}
+
+// MinerOf returns the miner of the node which is the miner of the scenario unless the node overrides it
+func (s *Scenario) MinerOf(node Node) Miner {
+	if node.Miner != nil {
+		return *node.Miner
+	}
+
+	return s.Miner
+}
+
+// WaitOf returns the time before the start at which the node prepares its utxos
+func (s *Scenario) WaitOf(node Node) time.Duration {
+	if node.Wait != nil {
+		return *node.Wait
+	}
+
+	return s.Wait
+}
+
+// SeedOf returns the seed of the node with the given index. Each node uses the seed of the scenario plus its index
+// so that the nodes draw different random sequences. For seed 0 each node uses a random seed.
+func (s *Scenario) SeedOf(index int) int64 {
+	if s.Seed == 0 {
+		return 0
+	}
+
+	return s.Seed + int64(index)
+}
+
+// Edges returns the edges of the topology graph. A random graph is drawn from the seed of the scenario so that each
+// invocation returns the same graph.
+func (s *Scenario) Edges() ([]topology.Edge, error) {
+	names := make([]string, len(s.Nodes))
+	for i, node := range s.Nodes {
+		names[i] = node.Name
+	}
+
+	return topology.Edges(s.Topology.Graph, names, s.Topology.Degree, rand.New(rand.NewSource(s.Seed)))
+}
+
+// Node returns the node with the given name
+func (s *Scenario) Node(name string) (int, Node, error) {
+	for i, node := range s.Nodes {
+		if node.Name == name {
+			return i, node, nil
+		}
+	}
+
+	return 0, Node{}, fmt.Errorf("node %s not found in scenario", name)
+}
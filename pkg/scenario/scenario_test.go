@@ -0,0 +1,170 @@
+package scenario
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	s, err := Load("../../config/scenarios/btc.yaml")
+	require.NoError(t, err)
+
+	require.Equal(t, BlockchainBTC, s.Blockchain)
+	require.Equal(t, 10*time.Minute, s.Duration)
+	require.Len(t, s.Nodes, 2)
+	require.Equal(t, 18443, s.Nodes[1].RPCPort)
+	require.Equal(t, "selfish", s.MinerOf(s.Nodes[1]).Strategy)
+	require.Equal(t, "honest", s.MinerOf(s.Nodes[0]).Strategy)
+	require.Equal(t, 20*time.Second, s.WaitOf(s.Nodes[1]))
+	require.Equal(t, int64(43), s.SeedOf(1))
+}
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name     string
+		scenario string
+
+		expectedErr string
+	}{
+		{
+			name:     "valid json",
+			scenario: `{"blockchain": "bsv", "duration": "1m", "nodes": [{"name": "node1", "rate": 5}]}`,
+		},
+		{
+			name:     "unknown field",
+			scenario: `{"blockchain": "bsv", "duration": "1m", "rates": 5, "nodes": [{"name": "node1", "rate": 5}]}`,
+
+			expectedErr: "field rates not found",
+		},
+		{
+			name: "invalid values",
+			scenario: `
+blockchain: eth
+duration: 1m
+miner:
+  strategy: lazy
+nodes:
+  - name: node1
+    rate_profile:
+      - at: 30s
+        rate: 10
+      - at: 10s
+        rate: 10
+  - name: node1
+    rate: 5
+`,
+
+			expectedErr: `blockchain "eth" not valid - has to be either btc or bsv
+miner: strategy "lazy" not valid - has to be one of honest, selfish or withhold
+nodes[0]: rate has to be greater than 0
+nodes[0].rate_profile[1]: steps have to be in increasing order of at
+nodes[1]: name "node1" is not unique`,
+		},
+		{
+			name: "invalid events",
+			scenario: `
+blockchain: btc
+duration: 1m
+nodes:
+  - name: node1
+    rate: 5
+events:
+  - at: 2m
+    action: stop
+  - at: 10s
+    action: scale_rate
+    nodes: [node2]
+  - at: 20s
+    action: explode
+`,
+
+			expectedErr: `events[0]: at has to be within the duration
+events[1]: factor has to be greater than 0
+events[1]: node node2 not found
+events[2]: action "explode" not valid`,
+		},
+		{
+			name: "invalid topology",
+			scenario: `
+blockchain: btc
+duration: 1m
+topology:
+  graph: random
+  degree: 3
+nodes:
+  - name: node1
+    rate: 5
+  - name: node2
+    rate: 5
+`,
+
+			expectedErr: "topology: degree has to be between 1 and 1",
+		},
+		{
+			name: "invalid rate control",
+			scenario: `
+blockchain: btc
+duration: 1m
+rate_control:
+  mode: pid
+  min_rate: 10
+  max_rate: 5
+nodes:
+  - name: node1
+    rate: 5
+`,
+
+			expectedErr: `rate_control: mode "pid" not valid - has to be one of [none target search]
+rate_control: max_rate must not be less than min_rate`,
+		},
+		{
+			name: "invalid route",
+			scenario: `
+blockchain: btc
+duration: 1m
+nodes:
+  - name: node1
+    rate: 5
+    route:
+      policy: broadcast
+      targets:
+        - node: node1
+        - node: node3
+`,
+
+			expectedErr: `nodes[0].route: policy "broadcast" not valid - has to be one of [round-robin random weighted all]
+nodes[0].route.targets[1]: node node3 not found`,
+		},
+		{
+			name: "double spends with one target",
+			scenario: `
+blockchain: bsv
+duration: 1m
+nodes:
+  - name: node1
+    rate: 5
+    route:
+      targets:
+        - node: node1
+      double_spend_ratio: 0.1
+`,
+
+			expectedErr: "nodes[0].route: at least two targets have to be given for double spends",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.scenario))
+
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+)
+
+const (
+	ActionSetRate   = "set_rate"
+	ActionScaleRate = "scale_rate"
+	ActionPause     = "pause"
+	ActionResume    = "resume"
+	ActionPartition = "partition"
+	ActionHeal      = "heal"
+	ActionMine      = "mine"
+	ActionStop      = "stop"
+
+	MsgScenarioEvent = "Scenario event"
+)
+
+var actions = []string{ActionSetRate, ActionScaleRate, ActionPause, ActionResume, ActionPartition, ActionHeal, ActionMine, ActionStop}
+
+// Event is an action which is executed at the given time after the start on the given nodes. If no nodes are given
+// the action is executed on all nodes.
+type Event struct {
+	At     time.Duration `yaml:"at"`
+	Action string        `yaml:"action"`
+	Nodes  []string      `yaml:"nodes"`
+	Rate   int64         `yaml:"rate"`
+	Factor float64       `yaml:"factor"`
+}
+
+func (e Event) validate(field string, duration time.Duration, names map[string]struct{}) []error {
+	var errs []error
+
+	if !slices.Contains(actions, e.Action) {
+		errs = append(errs, fmt.Errorf("%s: action %q not valid - has to be one of %v", field, e.Action, actions))
+	}
+
+	if e.At < 0 || e.At > duration {
+		errs = append(errs, fmt.Errorf("%s: at has to be within the duration", field))
+	}
+
+	if e.Action == ActionSetRate && e.Rate <= 0 {
+		errs = append(errs, fmt.Errorf("%s: rate has to be greater than 0", field))
+	}
+
+	if e.Action == ActionScaleRate && e.Factor <= 0 {
+		errs = append(errs, fmt.Errorf("%s: factor has to be greater than 0", field))
+	}
+
+	for _, node := range e.Nodes {
+		if _, found := names[node]; !found {
+			errs = append(errs, fmt.Errorf("%s: node %s not found", field, node))
+		}
+	}
+
+	return errs
+}
+
+// EventsOf returns the events which are executed on the node ordered by time including the steps of its rate profile
+func (s *Scenario) EventsOf(node Node) []Event {
+	events := make([]Event, 0)
+
+	for _, step := range node.RateProfile {
+		if step.At == 0 {
+			// The rate at the start is passed to the broadcaster directly
+			continue
+		}
+
+		events = append(events, Event{At: step.At, Action: ActionSetRate, Rate: step.Rate})
+	}
+
+	for _, event := range s.Events {
+		if len(event.Nodes) == 0 || slices.Contains(event.Nodes, node.Name) {
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At < events[j].At
+	})
+
+	return events
+}
+
+type Broadcaster interface {
+	Status() broadcaster.Status
+	SetRate(rateTxsPerSecond int64) error
+	Pause()
+	Resume()
+}
+
+type BlockTrigger interface {
+	Trigger() error
+}
+
+type Network interface {
+	SetNetworkActive(active bool) error
+}
+
+// Scheduler executes the events of a node at their time after the start
+type Scheduler struct {
+	events      []Event
+	broadcaster Broadcaster
+	miner       BlockTrigger
+	network     Network
+	stop        func()
+}
+
+// NewScheduler creates a scheduler for the events. The stop function is called to stop the run gracefully.
+func NewScheduler(events []Event, b Broadcaster, m BlockTrigger, n Network, stop func()) *Scheduler {
+	return &Scheduler{
+		events:      events,
+		broadcaster: b,
+		miner:       m,
+		network:     n,
+		stop:        stop,
+	}
+}
+
+// Start executes the events in order until all events have been executed or the context is canceled
+func (s *Scheduler) Start(ctx context.Context, startAt time.Time, logger *slog.Logger) {
+	logger = logger.With(slog.String("service", "scenario"))
+
+	go func() {
+		for _, event := range s.events {
+			timer := time.NewTimer(time.Until(startAt.Add(event.At)))
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			attrs, err := s.execute(event)
+			if err != nil {
+				logger.Error("Failed to execute scenario event", "action", event.Action, "at", event.At.String(), "err", err)
+				continue
+			}
+
+			logger.Info(MsgScenarioEvent, append([]any{"action", event.Action, "at", event.At.String()}, attrs...)...)
+		}
+	}()
+}
+
+// execute executes the action of the event and returns the attributes with which the event is logged
+func (s *Scheduler) execute(event Event) ([]any, error) {
+	switch event.Action {
+	case ActionSetRate, ActionScaleRate:
+		oldRate := s.broadcaster.Status().Rate
+
+		rate := event.Rate
+		if event.Action == ActionScaleRate {
+			rate = max(int64(math.Round(float64(oldRate)*event.Factor)), 1)
+		}
+
+		err := s.broadcaster.SetRate(rate)
+		if err != nil {
+			return nil, err
+		}
+
+		return []any{"old rate", oldRate, "rate", rate}, nil
+	case ActionPause:
+		s.broadcaster.Pause()
+	case ActionResume:
+		s.broadcaster.Resume()
+	case ActionPartition:
+		err := s.network.SetNetworkActive(false)
+		if err != nil {
+			return nil, err
+		}
+	case ActionHeal:
+		err := s.network.SetNetworkActive(true)
+		if err != nil {
+			return nil, err
+		}
+	case ActionMine:
+		// If the node has no interval miner, the block is generated directly by the node
+		err := s.miner.Trigger()
+		if err != nil {
+			return nil, err
+		}
+	case ActionStop:
+		s.stop()
+	default:
+		return nil, fmt.Errorf("action %s not valid", event.Action)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,130 @@
+package scenario
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boecklim/node-analysis/pkg/broadcaster"
+	"github.com/boecklim/node-analysis/pkg/miner"
+)
+
+type broadcasterMock struct {
+	rates  []int64
+	paused bool
+}
+
+func (b *broadcasterMock) Status() broadcaster.Status {
+	rate := int64(10)
+	if len(b.rates) > 0 {
+		rate = b.rates[len(b.rates)-1]
+	}
+	return broadcaster.Status{Rate: rate, Paused: b.paused}
+}
+
+func (b *broadcasterMock) SetRate(rate int64) error {
+	b.rates = append(b.rates, rate)
+	return nil
+}
+
+func (b *broadcasterMock) Pause()  { b.paused = true }
+func (b *broadcasterMock) Resume() { b.paused = false }
+
+type networkMock struct {
+	active []bool
+	blocks int
+}
+
+func (n *networkMock) SetNetworkActive(active bool) error {
+	n.active = append(n.active, active)
+	return nil
+}
+
+func (n *networkMock) GenerateBlock() (string, error) {
+	n.blocks++
+	return "aa", nil
+}
+
+func (n *networkMock) GetBlockConfirmations(_ string) (int64, error) {
+	return 0, nil
+}
+
+type triggerMock struct {
+	triggered int
+}
+
+func (m *triggerMock) Trigger() error {
+	m.triggered++
+	return nil
+}
+
+func TestScenario_EventsOf(t *testing.T) {
+	s := &Scenario{
+		Events: []Event{
+			{At: 3 * time.Minute, Action: ActionStop},
+			{At: time.Minute, Action: ActionPartition, Nodes: []string{"node2"}},
+		},
+	}
+
+	node1 := Node{Name: "node1", RateProfile: []RateStep{{At: 0, Rate: 5}, {At: 2 * time.Minute, Rate: 10}}}
+	require.Equal(t, []Event{
+		{At: 2 * time.Minute, Action: ActionSetRate, Rate: 10},
+		{At: 3 * time.Minute, Action: ActionStop},
+	}, s.EventsOf(node1))
+
+	node2 := Node{Name: "node2"}
+	require.Equal(t, []Event{
+		{At: time.Minute, Action: ActionPartition, Nodes: []string{"node2"}},
+		{At: 3 * time.Minute, Action: ActionStop},
+	}, s.EventsOf(node2))
+}
+
+func TestScheduler_Start(t *testing.T) {
+	b := &broadcasterMock{}
+	n := &networkMock{}
+	m := &triggerMock{}
+	stopped := make(chan struct{})
+
+	events := []Event{
+		{Action: ActionScaleRate, Factor: 2},
+		{Action: ActionPartition},
+		{Action: ActionMine},
+		{Action: ActionPause},
+		{Action: ActionHeal},
+		{Action: ActionSetRate, Rate: 7},
+		{Action: ActionStop},
+	}
+
+	NewScheduler(events, b, m, n, func() { close(stopped) }).Start(context.Background(), time.Now(), slog.Default())
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+
+	require.Equal(t, []int64{20, 7}, b.rates)
+	require.True(t, b.paused)
+	require.Equal(t, []bool{false, true}, n.active)
+	require.Equal(t, 1, m.triggered)
+}
+
+func TestScheduler_MineWithoutIntervalMiner(t *testing.T) {
+	n := &networkMock{}
+	stopped := make(chan struct{})
+
+	// The miner is not started as the node does not generate blocks in intervals
+	events := []Event{{Action: ActionMine}, {Action: ActionMine}, {Action: ActionStop}}
+	NewScheduler(events, &broadcasterMock{}, miner.New(n), n, func() { close(stopped) }).Start(context.Background(), time.Now(), slog.Default())
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+
+	require.Equal(t, 2, n.blocks)
+}
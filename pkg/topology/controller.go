@@ -0,0 +1,333 @@
+package topology
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+type Client interface {
+	AddNode(addr string, command string) error
+	DisconnectNode(addr string) error
+	SetNetworkActive(state bool) error
+	GetPeerInfo() ([]node_client.PeerInfo, error)
+}
+
+// Node is a node whose peers are controlled via RPC. Other nodes connect to the node at its P2P address.
+type Node struct {
+	Name    string
+	P2PAddr string
+	Client  Client
+}
+
+// Verification is the difference between the declared edges and the connections reported by the nodes
+type Verification struct {
+	Missing    []Edge
+	Unexpected []Edge
+}
+
+// OK returns true if the connections of the nodes match the declared edges
+func (v Verification) OK() bool {
+	return len(v.Missing) == 0 && len(v.Unexpected) == 0
+}
+
+func (v Verification) String() string {
+	if v.OK() {
+		return "topology matches"
+	}
+
+	return fmt.Sprintf("missing edges %v, unexpected edges %v", v.Missing, v.Unexpected)
+}
+
+// Controller connects and disconnects the nodes so that their connections match a declared graph
+type Controller struct {
+	nodes  []Node
+	graph  []Edge
+	logger *slog.Logger
+	lookup func(host string) ([]string, error)
+}
+
+type Option func(c *Controller)
+
+// WithLookup sets the function which resolves the hosts of the P2P addresses to IPs. Default is net.LookupHost.
+func WithLookup(lookup func(host string) ([]string, error)) Option {
+	return func(c *Controller) {
+		c.lookup = lookup
+	}
+}
+
+// New creates a controller which connects the nodes according to the declared graph
+func New(nodes []Node, graph []Edge, logger *slog.Logger, opts ...Option) *Controller {
+	c := &Controller{
+		nodes:  nodes,
+		graph:  graph,
+		logger: logger.With(slog.String("service", "topology")),
+		lookup: net.LookupHost,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Apply connects and disconnects the nodes according to the declared graph
+func (c *Controller) Apply() error {
+	return c.connect(c.graph)
+}
+
+// Partition disconnects the nodes of different groups from each other. Edges within a group are kept.
+func (c *Controller) Partition(groups [][]string) error {
+	c.logger.Info("Applying partition", "groups", fmt.Sprint(groups))
+
+	return c.connect(Partition(c.graph, groups))
+}
+
+// Isolate disables all P2P network activity of the node
+func (c *Controller) Isolate(name string) error {
+	node, err := c.node(name)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Info("Isolating node", "node", name)
+
+	return node.Client.SetNetworkActive(false)
+}
+
+// Heal enables the network activity of all nodes and restores the declared graph
+func (c *Controller) Heal() error {
+	c.logger.Info("Healing partition")
+
+	for _, node := range c.nodes {
+		err := node.Client.SetNetworkActive(true)
+		if err != nil {
+			return fmt.Errorf("failed to activate network of node %s: %w", node.Name, err)
+		}
+	}
+
+	return c.connect(c.graph)
+}
+
+// Verify compares the connections reported by the nodes with the given edges
+func (c *Controller) Verify(edges []Edge) (Verification, error) {
+	actual, err := c.connections()
+	if err != nil {
+		return Verification{}, err
+	}
+
+	expected := map[Edge]struct{}{}
+	for _, edge := range edges {
+		expected[normalize(edge)] = struct{}{}
+	}
+
+	v := Verification{}
+	for _, edge := range edges {
+		if _, found := actual[normalize(edge)]; !found {
+			v.Missing = append(v.Missing, edge)
+		}
+	}
+	for edge := range actual {
+		if _, found := expected[edge]; !found {
+			v.Unexpected = append(v.Unexpected, edge)
+		}
+	}
+	sortEdges(v.Unexpected)
+
+	return v, nil
+}
+
+// connect disconnects all peers which are not connected by an edge and adds the peers which are
+func (c *Controller) connect(edges []Edge) error {
+	wanted := map[Edge]struct{}{}
+	for _, edge := range edges {
+		wanted[normalize(edge)] = struct{}{}
+	}
+
+	identify, err := c.identifier()
+	if err != nil {
+		return err
+	}
+
+	// The peers of unwanted edges are removed from the added nodes on both sides before they are disconnected.
+	// Otherwise the node which initiated the connection reconnects the peer if the connection has already been closed
+	// by the other side.
+	for i, nodeA := range c.nodes {
+		for _, nodeB := range c.nodes[i+1:] {
+			if _, found := wanted[normalize(Edge{A: nodeA.Name, B: nodeB.Name})]; found {
+				continue
+			}
+
+			err = removeAddedNode(nodeA, nodeB)
+			if err != nil {
+				return err
+			}
+			err = removeAddedNode(nodeB, nodeA)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, node := range c.nodes {
+		peers, err := node.Client.GetPeerInfo()
+		if err != nil {
+			return fmt.Errorf("failed to get peers of node %s: %w", node.Name, err)
+		}
+
+		for _, peer := range peers {
+			peerName, found := identify(peer.Addr)
+			if !found {
+				continue
+			}
+
+			if _, found = wanted[normalize(Edge{A: node.Name, B: peerName})]; found {
+				continue
+			}
+
+			err = node.Client.DisconnectNode(peer.Addr)
+			if err != nil {
+				return fmt.Errorf("failed to disconnect peer %s from node %s: %w", peerName, node.Name, err)
+			}
+
+			c.logger.Info("Peer disconnected", "node", node.Name, "peer", peerName)
+		}
+	}
+
+	for _, edge := range edges {
+		nodeA, err := c.node(edge.A)
+		if err != nil {
+			return err
+		}
+		nodeB, err := c.node(edge.B)
+		if err != nil {
+			return err
+		}
+
+		err = nodeA.Client.AddNode(nodeB.P2PAddr, "add")
+		if err != nil && !isAlreadyAdded(err) {
+			return fmt.Errorf("failed to add peer %s to node %s: %w", edge.B, edge.A, err)
+		}
+
+		// Adding a node which is already in the list does not connect it again e.g. after it has been disconnected
+		err = nodeA.Client.AddNode(nodeB.P2PAddr, "onetry")
+		if err != nil {
+			return fmt.Errorf("failed to connect peer %s to node %s: %w", edge.B, edge.A, err)
+		}
+	}
+
+	c.logger.Info("Topology applied", "edges", len(edges))
+
+	return nil
+}
+
+// removeAddedNode removes the peer from the added nodes of the node so that the node does not reconnect it
+func removeAddedNode(node Node, peer Node) error {
+	err := node.Client.AddNode(peer.P2PAddr, "remove")
+	if err != nil && !isNotAdded(err) {
+		return fmt.Errorf("failed to remove peer %s from node %s: %w", peer.Name, node.Name, err)
+	}
+
+	return nil
+}
+
+// connections returns the edges between the nodes as reported by getpeerinfo
+func (c *Controller) connections() (map[Edge]struct{}, error) {
+	identify, err := c.identifier()
+	if err != nil {
+		return nil, err
+	}
+
+	actual := map[Edge]struct{}{}
+	for _, node := range c.nodes {
+		peers, err := node.Client.GetPeerInfo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get peers of node %s: %w", node.Name, err)
+		}
+
+		for _, peer := range peers {
+			peerName, found := identify(peer.Addr)
+			if !found || peerName == node.Name {
+				continue
+			}
+
+			actual[normalize(Edge{A: node.Name, B: peerName})] = struct{}{}
+		}
+	}
+
+	return actual, nil
+}
+
+// identifier returns a function which finds the node of a peer address. Outbound peers are reported with the P2P
+// address of the node. Inbound peers are reported with an ephemeral port and are identified by their IP if the IP is
+// unique among the nodes.
+func (c *Controller) identifier() (func(addr string) (string, bool), error) {
+	byAddr := map[string]string{}
+	byIP := map[string][]string{}
+
+	for _, node := range c.nodes {
+		host, port, err := net.SplitHostPort(node.P2PAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid P2P address of node %s: %w", node.Name, err)
+		}
+
+		ips, err := c.lookup(host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve host of node %s: %w", node.Name, err)
+		}
+
+		for _, ip := range ips {
+			byAddr[net.JoinHostPort(ip, port)] = node.Name
+			byIP[ip] = append(byIP[ip], node.Name)
+		}
+	}
+
+	return func(addr string) (string, bool) {
+		name, found := byAddr[addr]
+		if found {
+			return name, true
+		}
+
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return "", false
+		}
+
+		names := byIP[host]
+		if len(names) != 1 {
+			return "", false
+		}
+
+		return names[0], true
+	}, nil
+}
+
+func (c *Controller) node(name string) (Node, error) {
+	for _, node := range c.nodes {
+		if node.Name == name {
+			return node, nil
+		}
+	}
+
+	return Node{}, fmt.Errorf("node %s not found", name)
+}
+
+func normalize(e Edge) Edge {
+	if e.A > e.B {
+		return Edge{A: e.B, B: e.A}
+	}
+
+	return e
+}
+
+func isAlreadyAdded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "already added")
+}
+
+func isNotAdded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not been added")
+}
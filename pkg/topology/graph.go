@@ -0,0 +1,144 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+const (
+	GraphFullMesh = "full-mesh"
+	GraphRing     = "ring"
+	GraphStar     = "star"
+	GraphRandom   = "random"
+
+	// maxRandomAttempts is the number of attempts to draw a random regular graph without loops and duplicate edges
+	maxRandomAttempts = 1000
+)
+
+// Edge is a connection between two nodes. Edges are undirected - the node A initiates the connection.
+type Edge struct {
+	A string
+	B string
+}
+
+func (e Edge) String() string {
+	return e.A + "-" + e.B
+}
+
+// Edges returns the edges of the graph between the nodes. The first node is the center of a star. For a random graph
+// each node is connected to degree other nodes.
+func Edges(graph string, nodes []string, degree int, rng *rand.Rand) ([]Edge, error) {
+	n := len(nodes)
+	edges := make([]Edge, 0)
+
+	switch graph {
+	case GraphFullMesh:
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				edges = append(edges, Edge{A: nodes[i], B: nodes[j]})
+			}
+		}
+	case GraphRing:
+		if n < 3 {
+			return Edges(GraphFullMesh, nodes, degree, rng)
+		}
+		for i := 0; i < n; i++ {
+			edges = append(edges, Edge{A: nodes[i], B: nodes[(i+1)%n]})
+		}
+	case GraphStar:
+		for i := 1; i < n; i++ {
+			edges = append(edges, Edge{A: nodes[0], B: nodes[i]})
+		}
+	case GraphRandom:
+		return randomRegular(nodes, degree, rng)
+	default:
+		return nil, fmt.Errorf("graph %q not valid - has to be one of %s, %s, %s or %s", graph, GraphFullMesh, GraphRing, GraphStar, GraphRandom)
+	}
+
+	return edges, nil
+}
+
+// randomRegular draws a random graph in which each node has exactly degree edges using the configuration model
+func randomRegular(nodes []string, degree int, rng *rand.Rand) ([]Edge, error) {
+	n := len(nodes)
+
+	if degree <= 0 || degree >= n {
+		return nil, fmt.Errorf("degree has to be between 1 and %d", n-1)
+	}
+
+	if n*degree%2 != 0 {
+		return nil, errors.New("number of nodes times degree has to be even")
+	}
+
+	for attempt := 0; attempt < maxRandomAttempts; attempt++ {
+		stubs := make([]int, 0, n*degree)
+		for i := 0; i < n; i++ {
+			for k := 0; k < degree; k++ {
+				stubs = append(stubs, i)
+			}
+		}
+		rng.Shuffle(len(stubs), func(i, j int) {
+			stubs[i], stubs[j] = stubs[j], stubs[i]
+		})
+
+		pairs := map[[2]int]struct{}{}
+		valid := true
+		for i := 0; i < len(stubs); i += 2 {
+			a, b := min(stubs[i], stubs[i+1]), max(stubs[i], stubs[i+1])
+			if _, found := pairs[[2]int{a, b}]; found || a == b {
+				valid = false
+				break
+			}
+			pairs[[2]int{a, b}] = struct{}{}
+		}
+
+		if !valid {
+			continue
+		}
+
+		edges := make([]Edge, 0, len(pairs))
+		for pair := range pairs {
+			edges = append(edges, Edge{A: nodes[pair[0]], B: nodes[pair[1]]})
+		}
+		sortEdges(edges)
+
+		return edges, nil
+	}
+
+	return nil, fmt.Errorf("failed to draw random graph with degree %d after %d attempts", degree, maxRandomAttempts)
+}
+
+// Partition returns the edges which connect nodes of the same group. Nodes which are not part of any group keep
+// their edges to all other nodes.
+func Partition(edges []Edge, groups [][]string) []Edge {
+	groupOf := map[string]int{}
+	for i, group := range groups {
+		for _, node := range group {
+			groupOf[node] = i
+		}
+	}
+
+	result := make([]Edge, 0, len(edges))
+	for _, edge := range edges {
+		groupA, foundA := groupOf[edge.A]
+		groupB, foundB := groupOf[edge.B]
+		if foundA && foundB && groupA != groupB {
+			continue
+		}
+
+		result = append(result, edge)
+	}
+
+	return result
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].A != edges[j].A {
+			return edges[i].A < edges[j].A
+		}
+		return edges[i].B < edges[j].B
+	})
+}
@@ -0,0 +1,252 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boecklim/node-analysis/pkg/node_client"
+)
+
+// network simulates the connections between nodes. Connections are stored with the initiating node first.
+type network struct {
+	addrs       map[string]string
+	connections map[Edge]struct{}
+	active      map[string]bool
+	clients     []*clientMock
+}
+
+// reconnect opens the connections to the added nodes which are not connected as the added node thread of a node does
+func (n *network) reconnect() {
+	for _, c := range n.clients {
+		if !n.active[c.name] {
+			continue
+		}
+
+		for addr := range c.added {
+			peer := c.nodeOf(addr)
+			_, outbound := n.connections[Edge{A: c.name, B: peer}]
+			_, inbound := n.connections[Edge{A: peer, B: c.name}]
+			if !outbound && !inbound {
+				n.connections[Edge{A: c.name, B: peer}] = struct{}{}
+			}
+		}
+	}
+}
+
+type clientMock struct {
+	name  string
+	net   *network
+	added map[string]struct{}
+}
+
+func (c *clientMock) nodeOf(addr string) string {
+	for name, a := range c.net.addrs {
+		if a == addr {
+			return name
+		}
+	}
+	return ""
+}
+
+func (c *clientMock) AddNode(addr string, command string) error {
+	switch command {
+	case "add":
+		if _, found := c.added[addr]; found {
+			return errors.New("Error: Node already added")
+		}
+		c.added[addr] = struct{}{}
+	case "remove":
+		if _, found := c.added[addr]; !found {
+			return errors.New("Error: Node has not been added")
+		}
+		delete(c.added, addr)
+	case "onetry":
+		peer := c.nodeOf(addr)
+		if _, found := c.net.connections[Edge{A: peer, B: c.name}]; found {
+			return nil
+		}
+		c.net.connections[Edge{A: c.name, B: peer}] = struct{}{}
+	}
+	return nil
+}
+
+func (c *clientMock) DisconnectNode(addr string) error {
+	host, _, _ := net.SplitHostPort(addr)
+	for edge := range c.net.connections {
+		if edge.A == c.name && c.net.addrs[edge.B] == addr {
+			delete(c.net.connections, edge)
+			return nil
+		}
+		peerHost, _, _ := net.SplitHostPort(c.net.addrs[edge.A])
+		if edge.B == c.name && peerHost == host {
+			delete(c.net.connections, edge)
+			return nil
+		}
+	}
+	return errors.New("Node not found in connected nodes")
+}
+
+func (c *clientMock) SetNetworkActive(state bool) error {
+	c.net.active[c.name] = state
+	return nil
+}
+
+func (c *clientMock) GetPeerInfo() ([]node_client.PeerInfo, error) {
+	peers := make([]node_client.PeerInfo, 0)
+	for edge := range c.net.connections {
+		if edge.A == c.name {
+			peers = append(peers, node_client.PeerInfo{Addr: c.net.addrs[edge.B]})
+		}
+		if edge.B == c.name {
+			host, _, _ := net.SplitHostPort(c.net.addrs[edge.A])
+			peers = append(peers, node_client.PeerInfo{Addr: host + ":51234", Inbound: true})
+		}
+	}
+	return peers, nil
+}
+
+func newNetwork(names []string) (*network, []Node) {
+	n := &network{addrs: map[string]string{}, connections: map[Edge]struct{}{}, active: map[string]bool{}}
+	nodes := make([]Node, len(names))
+	for i, name := range names {
+		n.addrs[name] = fmt.Sprintf("10.0.0.%d:18444", i+1)
+		client := &clientMock{name: name, net: n, added: map[string]struct{}{}}
+		n.clients = append(n.clients, client)
+		n.active[name] = true
+		nodes[i] = Node{Name: name, P2PAddr: n.addrs[name], Client: client}
+	}
+	return n, nodes
+}
+
+func lookupIP(host string) ([]string, error) {
+	return []string{host}, nil
+}
+
+func TestEdges(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3", "node4", "node5", "node6"}
+
+	tt := []struct {
+		name   string
+		graph  string
+		degree int
+
+		expectedEdges  int
+		expectedDegree map[string]int
+		expectedErr    string
+	}{
+		{
+			name:           "full mesh",
+			graph:          GraphFullMesh,
+			expectedEdges:  15,
+			expectedDegree: map[string]int{"node1": 5, "node6": 5},
+		},
+		{
+			name:           "ring",
+			graph:          GraphRing,
+			expectedEdges:  6,
+			expectedDegree: map[string]int{"node1": 2, "node4": 2},
+		},
+		{
+			name:           "star",
+			graph:          GraphStar,
+			expectedEdges:  5,
+			expectedDegree: map[string]int{"node1": 5, "node2": 1},
+		},
+		{
+			name:           "random 3-regular",
+			graph:          GraphRandom,
+			degree:         3,
+			expectedEdges:  9,
+			expectedDegree: map[string]int{"node1": 3, "node2": 3, "node3": 3, "node4": 3, "node5": 3, "node6": 3},
+		},
+		{
+			name:        "random degree too high",
+			graph:       GraphRandom,
+			degree:      6,
+			expectedErr: "degree has to be between 1 and 5",
+		},
+		{
+			name:        "unknown graph",
+			graph:       "tree",
+			expectedErr: `graph "tree" not valid`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			edges, err := Edges(tc.graph, nodes, tc.degree, rand.New(rand.NewSource(1)))
+
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, edges, tc.expectedEdges)
+
+			degrees := map[string]int{}
+			for _, edge := range edges {
+				require.NotEqual(t, edge.A, edge.B)
+				degrees[edge.A]++
+				degrees[edge.B]++
+			}
+			for node, degree := range tc.expectedDegree {
+				require.Equal(t, degree, degrees[node], node)
+			}
+		})
+	}
+}
+
+func TestController(t *testing.T) {
+	names := []string{"node1", "node2", "node3", "node4"}
+	n, nodes := newNetwork(names)
+
+	// Initially all nodes are connected to each other as with -addnode
+	fullMesh, err := Edges(GraphFullMesh, names, 0, nil)
+	require.NoError(t, err)
+	for _, edge := range fullMesh {
+		n.connections[edge] = struct{}{}
+		n.clients[slices.Index(names, edge.A)].added[n.addrs[edge.B]] = struct{}{}
+	}
+
+	ring, err := Edges(GraphRing, names, 0, nil)
+	require.NoError(t, err)
+
+	c := New(nodes, ring, slog.Default(), WithLookup(lookupIP))
+
+	v, err := c.Verify(ring)
+	require.NoError(t, err)
+	require.False(t, v.OK())
+	require.ElementsMatch(t, []Edge{{A: "node1", B: "node3"}, {A: "node2", B: "node4"}}, v.Unexpected)
+
+	require.NoError(t, c.Apply())
+	n.reconnect()
+	v, err = c.Verify(ring)
+	require.NoError(t, err)
+	require.True(t, v.OK(), v.String())
+
+	groups := [][]string{{"node1", "node2"}, {"node3", "node4"}}
+	require.NoError(t, c.Partition(groups))
+	// The partition holds after the nodes have tried to reconnect their added nodes
+	n.reconnect()
+	v, err = c.Verify(Partition(ring, groups))
+	require.NoError(t, err)
+	require.True(t, v.OK(), v.String())
+	require.Len(t, n.connections, 2)
+
+	require.NoError(t, c.Isolate("node3"))
+	require.False(t, n.active["node3"])
+
+	require.NoError(t, c.Heal())
+	require.True(t, n.active["node3"])
+	n.reconnect()
+	v, err = c.Verify(ring)
+	require.NoError(t, err)
+	require.True(t, v.OK(), v.String())
+}
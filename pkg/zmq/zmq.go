@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/go-zeromq/zmq4"
+
+	"github.com/boecklim/node-analysis/pkg/metrics"
 )
 
 type subscriptionRequest struct {
@@ -28,15 +30,25 @@ type ZMQ struct {
 	addSubscription    chan subscriptionRequest
 	removeSubscription chan subscriptionRequest
 	logger             *slog.Logger
+	metrics            *metrics.Metrics
 }
 
-func NewZMQ(host string, port int, logger *slog.Logger) (*ZMQ, error) {
+type Option func(zmq *ZMQ)
+
+// WithMetrics counts the attempts to re-establish the connection
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(zmq *ZMQ) {
+		zmq.metrics = m
+	}
+}
+
+func NewZMQ(host string, port int, logger *slog.Logger, opts ...Option) (*ZMQ, error) {
 	ctx := context.Background()
 
-	return New(ctx, host, port, logger)
+	return New(ctx, host, port, logger, opts...)
 }
 
-func New(ctx context.Context, host string, port int, logger *slog.Logger) (*ZMQ, error) {
+func New(ctx context.Context, host string, port int, logger *slog.Logger, opts ...Option) (*ZMQ, error) {
 	zmq := &ZMQ{
 		address:            fmt.Sprintf("tcp://%s:%d", host, port),
 		subscriptions:      make(map[string][]chan []string),
@@ -46,6 +58,10 @@ func New(ctx context.Context, host string, port int, logger *slog.Logger) (*ZMQ,
 		socket:             zmq4.NewSub(ctx, zmq4.WithID(zmq4.SocketIdentity("sub"))),
 	}
 
+	for _, opt := range opts {
+		opt(zmq)
+	}
+
 	err := zmq.dial(ctx)
 	if err != nil {
 		return nil, err
@@ -197,6 +213,7 @@ func (zmq *ZMQ) Start(ctx context.Context) error {
 				zmq.connected = false
 			}
 			zmq.logger.Info("Attempting to re-establish ZMQ connection in 10 seconds...")
+			zmq.metrics.ZMQReconnect()
 			time.Sleep(10 * time.Second)
 		}
 	}()